@@ -0,0 +1,76 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/helm/chart-testing/v3/pkg/chart"
+	"github.com/helm/chart-testing/v3/pkg/config"
+	"github.com/helm/chart-testing/v3/pkg/tool"
+	"github.com/spf13/cobra"
+)
+
+func newCleanupCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cleanup",
+		Short: "Prune namespaces left behind by previous runs",
+		Long: heredoc.Docf(`
+			Delete the namespaces ct created for installing charts which were left behind,
+			e.g. because a previous run was killed, along with the Helm releases left in
+			them. Such namespaces are identified by the label '%s'.
+			Namespaces created less than '--prune-min-age' ago are kept, as they may still
+			be in use by a concurrent run.`, tool.NamespaceLabel),
+		RunE: cleanup,
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&cfgFile, "config", "", "Config file")
+	flags.Duration("prune-min-age", time.Hour, heredoc.Doc(`
+		The minimum age of namespaces to prune`))
+	flags.String("kube-context", "", heredoc.Doc(`
+		The kube context to prune namespaces in. If not specified, the current context is used`))
+	flags.Duration("namespace-delete-timeout", 180*time.Second, heredoc.Doc(`
+		The time to wait for a namespace to terminate. If the namespace still exists after
+		this time, its resources are force-deleted and, as a last resort, its finalizers
+		are removed`))
+	flags.String("helm-extra-args", "", heredoc.Doc(`
+		Additional arguments for Helm. Must be passed as a single quoted string
+		(e.g. "--kubeconfig ~/.kube/ci-config")`))
+	flags.String("kubectl-extra-args", "", heredoc.Doc(`
+		Additional arguments for kubectl. Must be passed as a single quoted string
+		(e.g. "--kubeconfig ~/.kube/ci-config")`))
+	return cmd
+}
+
+func cleanup(cmd *cobra.Command, args []string) error {
+	configuration, err := config.LoadConfiguration(cfgFile, cmd, true)
+	if err != nil {
+		return fmt.Errorf("Error loading configuration: %s", err)
+	}
+
+	testing, err := chart.NewTesting(*configuration)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Pruning namespaces...")
+	if err := testing.PruneNamespaces(); err != nil {
+		return fmt.Errorf("Error pruning namespaces: %s", err)
+	}
+	return nil
+}
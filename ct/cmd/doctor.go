@@ -0,0 +1,112 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/helm/chart-testing/v3/pkg/config"
+	"github.com/helm/chart-testing/v3/pkg/exec"
+	"github.com/helm/chart-testing/v3/pkg/tool"
+	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
+)
+
+func newDoctorCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the external tools ct depends on",
+		Long: heredoc.Doc(`
+			Check that the external tools ct runs, i.e. git, helm, kubectl, yamllint,
+			and yamale, are installed. A warning is printed for tools older than
+			their known-good minimum versions. Fails if any tool is missing.`),
+		RunE: doctor,
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&cfgFile, "config", "", "Config file")
+	addPreflightFlags(flags)
+	return cmd
+}
+
+func addPreflightFlags(flags *flag.FlagSet) {
+	flags.StringSlice("min-tool-versions", []string{}, heredoc.Doc(`
+		Minimum versions of external tools overriding the known-good defaults, each
+		formatted as 'tool=version' (e.g. 'helm=3.5.0'). May be specified multiple times
+		or separate values with commas`))
+}
+
+func doctor(cmd *cobra.Command, args []string) error {
+	configuration, err := config.LoadConfiguration(cfgFile, cmd, false)
+	if err != nil {
+		return fmt.Errorf("Error loading configuration: %s", err)
+	}
+
+	return checkTools(configuration, "git", "helm", "kubectl", "yamllint", "yamale")
+}
+
+// runPreflightChecks checks the tools required for linting and/or installing charts if preflight checks are enabled.
+func runPreflightChecks(configuration *config.Configuration, lint bool, install bool) error {
+	if !configuration.PreflightChecks {
+		return nil
+	}
+
+	tools := []string{"git", "helm"}
+	if lint && configuration.ValidateYaml {
+		tools = append(tools, "yamllint")
+	}
+	if lint && configuration.ValidateChartSchema {
+		tools = append(tools, "yamale")
+	}
+	if lint && len(configuration.ConftestPolicies) > 0 {
+		tools = append(tools, "conftest")
+	}
+	if install {
+		tools = append(tools, "kubectl")
+	}
+	if install && configuration.CreateKindCluster {
+		tools = append(tools, "kind")
+	}
+	return checkTools(configuration, tools...)
+}
+
+// checkTools prints the results of checking the tools and returns an error if any of them is missing.
+func checkTools(configuration *config.Configuration, tools ...string) error {
+	minVersions := map[string]string{}
+	for _, entry := range configuration.MinToolVersions {
+		entrySlice := strings.SplitN(entry, "=", 2)
+		minVersions[entrySlice[0]] = entrySlice[1]
+	}
+
+	fmt.Println("Checking tools...")
+	var missing []string
+	for _, check := range tool.NewDoctor(exec.NewProcessExecutor(configuration.Debug), minVersions).Check(tools...) {
+		if check.Missing || check.Outdated || check.Error != nil {
+			fmt.Println("WARNING:", check)
+		} else {
+			fmt.Println(check)
+		}
+		if check.Missing {
+			missing = append(missing, check.Name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("Required tools not found: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -16,10 +16,14 @@ package cmd
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/helm/chart-testing/v3/pkg/chart"
 	"github.com/helm/chart-testing/v3/pkg/config"
+	"github.com/helm/chart-testing/v3/pkg/exec"
+	"github.com/helm/chart-testing/v3/pkg/tool"
+	"github.com/helm/chart-testing/v3/pkg/util"
 
 	"github.com/spf13/cobra"
 	flag "github.com/spf13/pflag"
@@ -42,11 +46,50 @@ func newInstallCmd() *cobra.Command {
 			* previous chart revision => current chart version (if non-breaking SemVer change)
 			* current chart version => current chart version
 
+			Upgrades are tested in place: the previous revision is installed and then
+			upgraded within the same namespace and release. Like installs, upgrade tests
+			use a fixed namespace if one is specified with '--namespace' or the
+			'ct.helm.sh/namespace' annotation, and a newly created namespace otherwise.
+			With '--upgrade-separate-namespace', they always use a newly created namespace,
+			isolating them from other releases, e.g. for charts with immutable fields.
+
 			Charts may have multiple custom values files matching the glob pattern
 			'*-values.yaml' in a directory named 'ci' in the root of the chart's
 			directory. The chart is installed and tested for each of these files.
 			If no custom values file is present, the chart is installed and
-			tested with defaults.`),
+			tested with defaults.
+
+			Helm always applies the chart's 'values.yaml' as defaults, which each CI values
+			file overrides. With '--include-default-values', 'values.yaml' is additionally
+			passed explicitly before each CI values file. Either way, the precedence from
+			lowest to highest is: 'values.yaml', the CI values file, and values set with
+			'--helm-extra-args' (e.g. '--set'). On upgrades, the values of the previous
+			release are reused.
+
+			Values files may also be Go templates matching '*-values.yaml.tpl'. They
+			are rendered before each install with the fields .BuildId, .Namespace,
+			.Release, and .Token, a random string, e.g. for unique host names.
+
+			A CI values file 'ci/<name>-values.yaml' may be accompanied by an assertions
+			file 'ci/<name>-assertions.yaml' listing resources expected to exist after
+			installing and testing the chart with it under 'resources', each with a 'kind'
+			and a 'name', which may reference .BuildId, .Namespace, and .Release. The chart
+			fails if any of them is missing.
+
+			Charts may provide the scripts 'ci/pre-install.sh' and 'ci/post-install.sh'
+			which are run before installing and after testing a release, respectively.
+			The namespace, release name, and chart directory are exported to them as
+			NAMESPACE, RELEASE, and CHART_DIR. A failing pre-install script fails the
+			chart, whereas failures of the post-install script are only reported.
+
+			Charts which must be installed into a specific namespace, e.g. cluster
+			add-ons, may set the annotation 'ct.helm.sh/namespace' in 'Chart.yaml'.
+			Such a namespace takes precedence over '--namespace' and, just like the
+			latter, is neither created nor deleted.
+
+			Charts rendering no resources, neither with their default values nor with
+			any CI values file, e.g. charts without templates or umbrella charts whose
+			dependencies render nothing, are skipped since there is nothing to install.`),
 		RunE: install,
 	}
 
@@ -58,15 +101,80 @@ func newInstallCmd() *cobra.Command {
 
 func addInstallFlags(flags *flag.FlagSet) {
 	flags.String("build-id", "", heredoc.Doc(`
-		An optional, arbitrary identifier that is added to the name of the namespace a
-		chart is installed into. In a CI environment, this could be the build number or
-		the ID of a pull request. If not specified, the name of the chart is used`))
+		An optional, arbitrary identifier that is added to the names of the release and
+		the namespace a chart is installed into. In a CI environment, this could be the
+		build number or the ID of a pull request. If not specified, a short random ID is
+		generated and printed, so that concurrent runs do not collide`))
 	flags.String("helm-extra-args", "", heredoc.Doc(`
 		Additional arguments for Helm. Must be passed as a single quoted string
 		(e.g. "--timeout 500"`))
+	flags.String("kubectl-extra-args", "", heredoc.Doc(`
+		Additional arguments for kubectl, added to every kubectl call. Must be passed
+		as a single quoted string (e.g. "--insecure-skip-tls-verify --request-timeout 30s")`))
+	flags.Bool("helm-wait", false, heredoc.Doc(`
+		Only rely on Helm's '--wait' for resources of a release to become ready, instead of
+		additionally waiting for deployments matching the release label using kubectl.
+		Helm waits for all resources of the release, also those without the release label,
+		while kubectl waits for all deployments in the namespace (or those matching the
+		release label if '--namespace' is specified), also those not created by Helm`))
+	flags.String("helm-wait-timeout", "", heredoc.Doc(`
+		The time to wait for resources to become ready when '--helm-wait' is specified,
+		passed to Helm as '--timeout' (e.g. '10m0s'). If not specified, Helm's default is used`))
+	flags.Bool("wait-for-jobs", false, heredoc.Doc(`
+		When '--helm-wait' is specified, additionally wait for jobs of a release, e.g.
+		database migrations, to complete on install and upgrade before testing it, passing
+		'--wait-for-jobs' to Helm. Requires Helm v3.5.0 or later`))
+	flags.StringSlice("helm-test-filter", []string{}, heredoc.Doc(`
+		Filters passed to 'helm test' in order to select the tests to run, e.g.
+		'name=smoke-test' to only run the test named 'smoke-test' or '!name=slow-test'
+		to run all tests except 'slow-test'. May be specified multiple times or
+		separate values with commas. If not specified, all tests are run`))
+	flags.Int("test-retries", 0, heredoc.Doc(`
+		The number of times to retry 'helm test' if tests fail, e.g. because of flaky
+		tests. Retries wait a little longer each time`))
+	flags.StringSlice("remote-charts", []string{}, heredoc.Doc(`
+		Published charts to install and test, formatted as 'repo/chart[:version]'
+		(e.g. 'stable/nginx-ingress:1.41.0'), after adding the repositories specified
+		with '--chart-repos'. The charts are pulled into a temporary directory. Changed
+		charts are not identified when remote charts are specified, but charts specified
+		with '--charts' or '--all' are tested as well. May be specified multiple times
+		or separate values with commas`))
+	flags.Bool("only-changed-values-files", false, heredoc.Doc(`
+		When identifying changed charts, install charts of which only CI values files
+		changed with the changed values files only, instead of with all values files.
+		Charts with any other changes are still installed with all values files`))
 	flags.Bool("upgrade", false, heredoc.Doc(`
 		Whether to test an in-place upgrade of each chart from its previous revision if the
 		current version should not introduce a breaking change according to the SemVer spec`))
+	flags.String("upgrade-skip-version-bump-rule", "major", heredoc.Doc(`
+		The version bump which is considered to introduce a breaking change, skipping
+		the upgrade test when passing --upgrade: 'major' for major version bumps, or
+		minor version bumps below 1.0.0, according to the SemVer spec, 'minor' for
+		major or minor version bumps, or 'none' to always test upgrades`))
+	flags.Bool("upgrade-only", false, heredoc.Doc(`
+		When --upgrade has been passed, this flag will only test upgrades and skip the
+		fresh install of the current chart version`))
+	flags.Bool("upgrade-separate-namespace", false, heredoc.Doc(`
+		When --upgrade has been passed, install the previous revision of each chart into
+		a newly created namespace, even if charts are otherwise installed into a fixed
+		namespace specified with '--namespace' or the 'ct.helm.sh/namespace' annotation.
+		The upgrade is then tested in place in that namespace. By default, upgrade tests
+		use the same namespace as installs`))
+	flags.Int("dependency-build-parallelism", 1, heredoc.Doc(`
+		When --upgrade has been passed, the number of charts for which dependencies of
+		their previous revision are built in parallel`))
+	flags.Int("values-files-parallelism", 1, heredoc.Doc(`
+		The number of CI values files of a chart to install and test in parallel, each in
+		its own namespace and release. Failures of all installs are reported. Charts with
+		a fixed namespace are always installed with one values file at a time. Output of
+		parallel installs is interleaved`))
+	flags.Bool("install-dependency-update", false, heredoc.Doc(`
+		Update dependencies of charts on install and upgrade using Helm's '--dependency-update'.
+		Dependencies of charts without 'Chart.lock' are then not built beforehand, which fails
+		for such charts otherwise`))
+	flags.Bool("include-default-values", false, heredoc.Doc(`
+		Explicitly pass the chart's 'values.yaml' to Helm before each CI values file on install,
+		so that CI values files are layered on top of it as user-supplied values`))
 	flags.Bool("skip-missing-values", false, heredoc.Doc(`
 		When --upgrade has been passed, this flag will skip testing CI values files from the
 		previous chart revision if they have been deleted or renamed at the current chart
@@ -76,23 +184,149 @@ func addInstallFlags(flags *flag.FlagSet) {
 		installed in its own randomly generated namespace`))
 	flags.String("release-label", "app.kubernetes.io/instance", heredoc.Doc(`
 		The label to be used as a selector when inspecting resources created by charts.
-		This is only used if namespace is specified`))
+		This is only used if namespace is specified. Charts labeling their resources
+		differently may specify their label with the 'ct.helm.sh/release-label' annotation
+		in 'Chart.yaml'`))
+	flags.Duration("namespace-delete-timeout", 180*time.Second, heredoc.Doc(`
+		The time to wait for a namespace to terminate after testing. If the namespace
+		still exists after this time, its resources are force-deleted and, as a last
+		resort, its finalizers are removed`))
+	flags.Duration("kubectl-wait-timeout", 180*time.Second, heredoc.Doc(`
+		The time to wait using kubectl for resources of a release to be deleted before
+		testing a reinstall, for autoscaling resources to become ready when
+		'--wait-for-autoscaling' is specified, and for CRDs to become established when
+		'--validate-crds' is specified`))
+	flags.String("wait-exclude-selector", "", heredoc.Doc(`
+		A label selector for deployments not to wait for to become ready before running
+		'helm test', e.g. deployments only used by tests which share the release label
+		(e.g. 'app.kubernetes.io/component=test'). Note that Helm hooks are marked with
+		annotations, not labels. If not specified, all deployments of the release are
+		waited for`))
+	flags.Bool("wait-for-autoscaling", false, heredoc.Doc(`
+		Before running 'helm test', wait for the horizontal pod autoscalers of each release
+		to run their desired number of replicas and for its pod disruption budgets to be
+		satisfied, failing with the resources which are not ready after
+		'--kubectl-wait-timeout'`))
+	flags.String("image-pull-secret", "", heredoc.Doc(`
+		An image pull secret to create in each namespace a chart is installed into,
+		formatted as 'name=path/to/config.json' where the file contains Docker config
+		JSON (e.g. '~/.docker/config.json'). Charts can reference the secret, e.g. with
+		'--helm-extra-args "--set imagePullSecrets[0].name=name"'. Not created if
+		'--namespace' is specified`))
+	flags.String("service-account", "", heredoc.Doc(`
+		A service account to create in each namespace a chart is installed into, e.g. for
+		charts installed with 'serviceAccount.create=false'. Not created if '--namespace'
+		is specified`))
+	flags.String("service-account-cluster-role", "", heredoc.Doc(`
+		A cluster role to bind to the service account specified with '--service-account'
+		within the namespace`))
+	flags.String("test-existing-release", "", heredoc.Doc(`
+		An already deployed release of the chart specified with '--charts' to run tests
+		against, formatted as 'namespace/release'. The chart is neither installed nor
+		uninstalled. Deployments are selected using '--release-label'`))
+	flags.Bool("validate-crds", false, heredoc.Doc(`
+		Apply the CRDs in the 'crds' directory of each chart using kubectl and wait for them
+		to become established before installing the chart, failing the chart otherwise.
+		Like Helm, CRDs are not deleted after testing`))
+	flags.String("cleanup-policy", "always", heredoc.Doc(`
+		When to uninstall releases and delete their namespaces after installing and
+		testing them: 'always', 'on-success' to keep failed releases for debugging,
+		'on-failure' to keep successful releases, e.g. for promotion, or 'never'`))
+	flags.Bool("delete-cluster-resources", false, heredoc.Doc(`
+		Delete cluster-scoped resources matching the release label, e.g. cluster roles or
+		CRDs, after uninstalling a release. Such resources are not deleted along with the
+		namespace, and neither by Helm if they are installed from the chart's 'crds'
+		directory or annotated with 'helm.sh/resource-policy: keep', which makes
+		subsequent installs fail`))
+	flags.Bool("verify-cleanup", false, heredoc.Doc(`
+		After uninstalling a release and deleting its namespace, check that no resources
+		matching the release label remain in the cluster, namespaced or cluster-scoped, e.g.
+		persistent volumes or resources blocked by finalizers. Leaked resources are reported
+		as a warning`))
+	flags.Bool("fail-on-leaked-resources", false, heredoc.Doc(`
+		When '--verify-cleanup' has been passed, fail charts leaking resources instead of
+		only printing a warning`))
+	flags.Bool("debug-on-failure", false, heredoc.Doc(`
+		Print all resources, descriptions of non-ready pods, and events of the namespace
+		when installing or testing a chart fails, before the namespace is deleted`))
+	flags.Bool("logs-failed-containers-only", false, heredoc.Doc(`
+		When printing the logs of pods after a failed install or test, only print the logs
+		of init containers and containers which failed, i.e. which are neither ready nor
+		completed successfully, or which have been restarted`))
+	flags.Int("logs-tail", 0, heredoc.Doc(`
+		When printing the logs of pods after a failed install or test, only print the last
+		lines of the logs of each container, passed to kubectl as '--tail'. If not
+		specified, the complete logs are printed`))
+	flags.Bool("test-reinstall", false, heredoc.Doc(`
+		Whether to uninstall each release after a successful install and test, wait for its
+		resources to be deleted, and then install and test it again. This catches leftover
+		resources which block reinstalling a chart`))
+	flags.String("kube-context", "", heredoc.Doc(`
+		The kube context to install charts into. If not specified, the current context is used`))
+	flags.Bool("create-kind-cluster", false, heredoc.Doc(`
+		Create a throwaway kind cluster to install charts into, which is deleted after
+		testing. Requires kind to be installed`))
+	flags.String("kind-image", "", heredoc.Doc(`
+		The node image of the kind cluster created with --create-kind-cluster
+		(e.g. 'kindest/node:v1.21.1'). If not specified, kind's default image is used`))
+	flags.String("kind-config", "", heredoc.Doc(`
+		The kind config file to create the kind cluster with when passing
+		--create-kind-cluster`))
+	flags.String("matrix", "", heredoc.Doc(`
+		A matrix file, e.g. 'ct-matrix.yaml', listing under 'entries' the charts to install
+		and test in order, each with the fields 'chart', the path to the chart directory,
+		and optionally 'valuesFiles', a list of values files to install the chart with
+		instead of its CI values files, 'namespace', an existing namespace to install the
+		chart into, and 'helmArgs', additional arguments for Helm. Changed charts are
+		not identified when a matrix file is specified. Only supported by 'ct install'`))
+	flags.StringSlice("kube-versions-matrix", []string{}, heredoc.Doc(`
+		Kubernetes versions to test charts against, each formatted as 'version=context'
+		(e.g. '1.18=kind-1-18'). Charts are installed and tested once per entry using the
+		entry's kube context, and results are grouped by version. May be specified multiple
+		times or separate values with commas`))
 }
 
 func install(cmd *cobra.Command, args []string) error {
-	fmt.Println("Installing charts...")
-
 	configuration, err := config.LoadConfiguration(cfgFile, cmd, true)
 	if err != nil {
 		return fmt.Errorf("Error loading configuration: %s", err)
 	}
 
+	if configuration.PrintConfig {
+		return printEffectiveConfig(configuration)
+	}
+
+	if err := runPreflightChecks(configuration, false, true); err != nil {
+		return err
+	}
+
+	deleteKindCluster, err := createKindCluster(configuration)
+	if err != nil {
+		return err
+	}
+	defer deleteKindCluster()
+
 	testing, err := chart.NewTesting(*configuration)
 	if err != nil {
 		fmt.Println(err)
 	}
-	results, err := testing.InstallCharts()
+
+	if configuration.ListCharts {
+		return testing.PrintChartsToBeProcessed()
+	}
+
+	fmt.Println("Installing charts...")
+	var results []chart.TestResult
+	if configuration.Matrix != "" {
+		results, err = testing.InstallMatrix()
+	} else {
+		results, err = testing.InstallCharts()
+	}
 	testing.PrintResults(results)
+	testing.NotifyWebhook(results, err)
+	if resultsErr := testing.WriteResultsFile(results, err); resultsErr != nil {
+		return resultsErr
+	}
 
 	if err != nil {
 		return fmt.Errorf("Error installing charts: %s", err)
@@ -101,3 +335,28 @@ func install(cmd *cobra.Command, args []string) error {
 	fmt.Println("All charts installed successfully")
 	return nil
 }
+
+// createKindCluster creates a throwaway kind cluster if configured and points the kube context at it. The returned
+// function deletes the cluster. It is meant to be deferred, so the cluster is also deleted if testing panics. No
+// cluster is needed for listing charts.
+func createKindCluster(configuration *config.Configuration) (func(), error) {
+	if !configuration.CreateKindCluster || configuration.ListCharts {
+		return func() {}, nil
+	}
+
+	kind := tool.NewKind(exec.NewProcessExecutor(configuration.Debug))
+	name := "ct-" + util.RandomString(6)
+	deleteCluster := func() {
+		if err := kind.DeleteCluster(name); err != nil {
+			fmt.Printf("Error deleting kind cluster '%s': %s\n", name, err)
+		}
+	}
+	if err := kind.CreateCluster(name, configuration.KindImage, configuration.KindConfig); err != nil {
+		// Creating the cluster may fail after its node containers were started
+		deleteCluster()
+		return nil, fmt.Errorf("Error creating kind cluster: %s", err)
+	}
+
+	configuration.KubeContext = kind.KubeContext(name)
+	return deleteCluster, nil
+}
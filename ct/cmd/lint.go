@@ -16,6 +16,7 @@ package cmd
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/helm/chart-testing/v3/pkg/chart"
@@ -42,7 +43,13 @@ func newLintCmd() *cobra.Command {
 			Charts may have multiple custom values files matching the glob pattern
 			'*-values.yaml' in a directory named 'ci' in the root of the chart's
 			directory. The chart is linted for each of these files. If no custom
-			values file is present, the chart is linted with defaults.`),
+			values file is present, the chart is linted with defaults. Values
+			templates matching '*-values.yaml.tpl' are rendered before linting.
+
+			Intentionally broken charts, e.g. fixtures verifying lint rules, may set
+			the annotation 'ct.helm.sh/expect-lint-failure: "true"' in 'Chart.yaml'.
+			Lint failures of such charts are reported as passed, and linting them
+			successfully fails. Version checks are not affected.`),
 		RunE: lint,
 	}
 
@@ -54,37 +61,153 @@ func newLintCmd() *cobra.Command {
 
 func addLintFlags(flags *flag.FlagSet) {
 	flags.String("lint-conf", "", heredoc.Doc(`
-			The config file for YAML linting. If not specified, 'lintconf.yaml'
+			The config file for YAML linting. May also be specified per file name
+			pattern as comma-separated 'pattern=path' pairs (e.g. '*-values.yaml=strict.yaml')
+			or as a map in the config file, in which case the longest matching pattern
+			wins. If not specified, or for files matching no pattern, 'lintconf.yaml'
 			is searched in the current directory, '$HOME/.ct', and '/etc/ct', in
 			that order`))
 	flags.String("chart-yaml-schema", "", heredoc.Doc(`
-			The schema for chart.yml validation. If not specified, 'chart_schema.yaml'
-			is searched in the current directory, '$HOME/.ct', and '/etc/ct', in
-			that order.`))
+			The schema for chart.yml validation. May also be specified per apiVersion
+			of charts as comma-separated 'apiVersion=path' pairs (e.g. 'v1=v1.yaml,v2=v2.yaml')
+			or as a map in the config file. If not specified, or for charts with other
+			apiVersions, 'chart_schema.yaml' is searched in the current directory,
+			'$HOME/.ct', and '/etc/ct', in that order.`))
 	flags.Bool("validate-maintainers", true, heredoc.Doc(`
 			Enable validation of maintainer account names in chart.yml (default: true).
 			Works for GitHub, GitLab, and Bitbucket`))
-	flags.Bool("check-version-increment", true, "Activates a check for chart version increments (default: true)")
+	flags.Duration("account-validation-timeout", 30*time.Second, heredoc.Doc(`
+			The time to wait for the Git provider to respond when validating a maintainer
+			account name. Validation fails if the provider does not respond in time`))
+	flags.Bool("warn-on-account-validation-timeout", false, heredoc.Doc(`
+			Only print a warning and skip validating the remaining maintainers of a chart if
+			the Git provider does not respond within '--account-validation-timeout', so that
+			provider outages do not fail linting`))
+	flags.Bool("validate-deprecation", false, heredoc.Doc(`
+			Enable validation that deprecated charts have a description or a
+			'ct.helm.sh/replacement' annotation in 'Chart.yaml' pointing to a replacement`))
+	flags.StringSlice("allowed-image-registries", []string{}, heredoc.Doc(`
+			Registries charts may pull container images from (e.g. 'docker.io,quay.io'). If
+			specified, charts are rendered with their default values and each CI values file,
+			and linting fails for containers using images from other registries. Images
+			without explicit registry are pulled from 'docker.io'. May be specified multiple
+			times or separate values with commas`))
+	flags.Int("max-rendered-resources", 0, heredoc.Doc(`
+			The maximum number of resources a chart may render. If specified, charts are
+			rendered with their default values and each CI values file, and linting fails
+			for charts rendering more resources, which often indicates that a chart should
+			be split into subcharts. Not checked if 0`))
+	flags.Int("max-manifest-bytes", 0, heredoc.Doc(`
+			The maximum size in bytes of the manifests a chart may render, checked like
+			'--max-rendered-resources'. Not checked if 0`))
+	flags.StringSlice("conftest-policies", []string{}, heredoc.Doc(`
+			Directories with OPA policies to enforce using conftest. If specified, charts are
+			rendered with their default values and each CI values file, and linting fails for
+			charts violating policies of any of the directories. May be specified multiple
+			times or separate values with commas`))
+	flags.Bool("run-unit-tests", false, heredoc.Doc(`
+			Run the unit tests of each chart using the helm-unittest plugin after 'helm lint',
+			failing charts with failing test suites. The plugin must be installed`))
+	flags.String("unit-tests-path", "", heredoc.Doc(`
+			The glob pattern, relative to the chart directory, of the unit test files to run
+			with '--run-unit-tests'. If not specified, the plugin's default 'tests/*_test.yaml'
+			is used`))
+	flags.StringSlice("lint-plugins", []string{}, heredoc.Doc(`
+			Helm plugin subcommands to run for each chart after 'helm lint', optionally
+			followed by arguments (e.g. 'schema' or 'unittest --strict'). The chart path is
+			passed as the last argument, and linting fails for charts for which a plugin
+			exits with a non-zero code. May be specified multiple times or separate values
+			with commas`))
+	flags.Bool("check-app-version-increment", false, heredoc.Doc(`
+			Activates a check that the 'appVersion' of a chart changed if any of its templates
+			changed compared to the target branch, for charts whose 'appVersion' tracks the
+			packaged application. Only applies to changed charts`))
+	flags.Bool("check-version-increment", true, heredoc.Doc(`
+			Activates a check for chart version increments (default: true). Charts whose
+			only changes are in their 'ci' or 'docs' directories or in Markdown files do
+			not need a version increment`))
+	flags.Bool("allow-prerelease-bumps", true, heredoc.Doc(`
+			Accept later prereleases of the same version as version increments (default:
+			true), e.g. '1.2.0-rc.2' after '1.2.0-rc.1'. Versions are compared by SemVer
+			precedence, so a release is always an increment over its prereleases (e.g.
+			'1.2.0' after '1.2.0-rc.2'), but a prerelease is not one over its release. If
+			disabled, only releases and prereleases of later versions are accepted`))
+	flags.String("new-chart-min-version", "", heredoc.Doc(`
+			The minimum version new charts must start at (e.g. '0.1.0'). Only checked if
+			version increment checking is enabled. If not specified, versions of new charts
+			are not checked`))
 	flags.Bool("validate-chart-schema", true, heredoc.Doc(`
 			Enable schema validation of 'Chart.yaml' using Yamale (default: true)`))
 	flags.Bool("validate-yaml", true, heredoc.Doc(`
 			Enable linting of 'Chart.yaml' and values files (default: true)`))
+	flags.Bool("yaml-lint-all-files", false, heredoc.Doc(`
+			Enable linting of all '.yaml' and '.yml' files in the chart directory in addition
+			to 'Chart.yaml' and values files. Templates and vendored dependencies in 'charts'
+			are skipped`))
+	flags.Bool("yaml-lint-templates", false, heredoc.Doc(`
+			When '--yaml-lint-all-files' is enabled, also lint files in 'templates'. Only useful
+			if templates contain plain YAML`))
+	flags.Bool("validate-chart-yaml", true, heredoc.Doc(`
+			Enable validation of required fields ('apiVersion', 'name', 'version') in
+			'Chart.yaml' and of 'dependencies' only being used with 'apiVersion: v2' (default: true)`))
+	flags.StringSlice("required-annotations", []string{}, heredoc.Doc(`
+			Annotations which must be present and not empty in the 'Chart.yaml' of each chart
+			(e.g. 'artifacthub.io/changes'). May be specified multiple times or separate
+			values with commas`))
+	flags.Bool("validate-dependency-versions", false, heredoc.Doc(`
+			Enable validation that dependencies vendored in the chart's 'charts' directory
+			match the versions declared in 'Chart.yaml'`))
+	flags.Bool("require-pinned-dependencies", false, heredoc.Doc(`
+			Fail charts whose dependencies in 'Chart.yaml' are not pinned to exact versions,
+			e.g. '1.2.3' rather than '~1.2.0' or '>=1.2.0', for reproducible installs.
+			Dependencies with a 'file://' repository are exempt`))
+	flags.Bool("validate-ci-values-keys", false, heredoc.Doc(`
+			Enable validation that the top-level keys set in CI values files exist in the chart's
+			values, i.e. in 'values.yaml', in the properties of 'values.schema.json', or as the
+			alias or, lacking one, the name of a dependency, in order to catch values files left
+			stale by renamed values. 'global' is always allowed`))
+	flags.Bool("validate-defaults-against-schema", false, heredoc.Doc(`
+			Enable validation of the chart's default values in 'values.yaml' against its
+			'values.schema.json' by linting the chart without CI values files, in order to
+			catch schemas which reject the chart's own defaults even if CI values files
+			override them`))
+	flags.Bool("quiet-lint", false, heredoc.Doc(`
+			Only print the output of 'helm lint' for charts which fail linting`))
+	flags.Bool("lint-info-as-error", false, heredoc.Doc(`
+			Fail linting of charts for which 'helm lint' reports '[INFO]' recommendations,
+			e.g. a missing icon`))
 }
 
 func lint(cmd *cobra.Command, args []string) error {
-	fmt.Println("Linting charts...")
-
 	configuration, err := config.LoadConfiguration(cfgFile, cmd, true)
 	if err != nil {
 		return fmt.Errorf("Error loading configuration: %s", err)
 	}
 
+	if configuration.PrintConfig {
+		return printEffectiveConfig(configuration)
+	}
+
+	if err := runPreflightChecks(configuration, true, false); err != nil {
+		return err
+	}
+
 	testing, err := chart.NewTesting(*configuration)
 	if err != nil {
 		return err
 	}
+
+	if configuration.ListCharts {
+		return testing.PrintChartsToBeProcessed()
+	}
+
+	fmt.Println("Linting charts...")
 	results, err := testing.LintCharts()
 	testing.PrintResults(results)
+	testing.NotifyWebhook(results, err)
+	if resultsErr := testing.WriteResultsFile(results, err); resultsErr != nil {
+		return resultsErr
+	}
 
 	if err != nil {
 		return fmt.Errorf("Error linting charts: %s", err)
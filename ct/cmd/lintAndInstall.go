@@ -40,19 +40,45 @@ func newLintAndInstallCmd() *cobra.Command {
 }
 
 func lintAndInstall(cmd *cobra.Command, args []string) error {
-	fmt.Println("Linting and installing charts...")
-
 	configuration, err := config.LoadConfiguration(cfgFile, cmd, true)
 	if err != nil {
 		return fmt.Errorf("Error loading configuration: %s", err)
 	}
 
+	if configuration.PrintConfig {
+		return printEffectiveConfig(configuration)
+	}
+
+	if configuration.Matrix != "" {
+		return fmt.Errorf("'--matrix' is only supported by 'ct install'")
+	}
+
+	if err := runPreflightChecks(configuration, true, true); err != nil {
+		return err
+	}
+
+	deleteKindCluster, err := createKindCluster(configuration)
+	if err != nil {
+		return err
+	}
+	defer deleteKindCluster()
+
 	testing, err := chart.NewTesting(*configuration)
 	if err != nil {
 		return err
 	}
+
+	if configuration.ListCharts {
+		return testing.PrintChartsToBeProcessed()
+	}
+
+	fmt.Println("Linting and installing charts...")
 	results, err := testing.LintAndInstallCharts()
 	testing.PrintResults(results)
+	testing.NotifyWebhook(results, err)
+	if resultsErr := testing.WriteResultsFile(results, err); resultsErr != nil {
+		return resultsErr
+	}
 
 	if err != nil {
 		return fmt.Errorf("Error linting and installing charts: %s", err)
@@ -31,12 +31,20 @@ func newListChangedCmd() *cobra.Command {
 		Short:   "List changed charts",
 		Long: heredoc.Doc(`
 			"List changed charts based on configured charts directories,
-			"remote, and target branch`),
+			"remote, and target branch.
+
+			With '--dependents-of', list the charts which depend on the specified chart,
+			directly or transitively, via 'file://' dependencies instead, i.e. the
+			charts affected by changes to it. Changes are not identified then.`),
 		RunE: listChanged,
 	}
 
 	flags := cmd.Flags()
 	addCommonFlags(flags)
+	flags.String("dependents-of", "", heredoc.Doc(`
+		A chart, specified as a path to its directory or by its name, of which to list
+		the charts in the configured chart directories depending on it via 'file://'
+		dependencies, directly or transitively`))
 	return cmd
 }
 
@@ -46,17 +54,33 @@ func listChanged(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("Error loading configuration: %s", err)
 	}
 
+	if configuration.PrintConfig {
+		return printEffectiveConfig(configuration)
+	}
+
 	testing, err := chart.NewTesting(*configuration)
 	if err != nil {
 		return err
 	}
+
+	if configuration.DependentsOf != "" {
+		chartDirs, err := testing.FindDependentChartDirectories(configuration.DependentsOf)
+		if err != nil {
+			return err
+		}
+		for _, dir := range chartDirs {
+			fmt.Println(dir)
+		}
+		return nil
+	}
+
 	chartDirs, err := testing.ComputeChangedChartDirectories()
 	if err != nil {
 		return err
 	}
 
 	for _, dir := range chartDirs {
-		fmt.Println(dir)
+		fmt.Printf("%s%s\n", dir, testing.ExplainChanges(dir))
 	}
 	return nil
 }
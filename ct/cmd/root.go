@@ -19,6 +19,7 @@ import (
 	"os"
 
 	"github.com/MakeNowJust/heredoc"
+	"github.com/helm/chart-testing/v3/pkg/config"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 )
@@ -48,6 +49,8 @@ func NewRootCmd() *cobra.Command {
 	cmd.AddCommand(newListChangedCmd())
 	cmd.AddCommand(newVersionCmd())
 	cmd.AddCommand(newGenerateDocsCmd())
+	cmd.AddCommand(newDoctorCmd())
+	cmd.AddCommand(newCleanupCmd())
 
 	return cmd
 }
@@ -60,16 +63,66 @@ func Execute() {
 	}
 }
 
+func printEffectiveConfig(configuration *config.Configuration) error {
+	effectiveConfig, err := configuration.EffectiveYaml()
+	if err != nil {
+		return err
+	}
+	fmt.Print(effectiveConfig)
+	return nil
+}
+
 func addCommonFlags(flags *pflag.FlagSet) {
 	flags.StringVar(&cfgFile, "config", "", "Config file")
-	flags.String("remote", "origin", "The name of the Git remote used to identify changed charts")
-	flags.String("target-branch", "master", "The name of the target branch used to identify changed charts")
+	flags.StringP("directory", "C", "", heredoc.Doc(`
+		The root of the git repository to operate on, e.g. a checkout at an arbitrary
+		path, like 'git -C'. The config file in the repository is used, and chart
+		directories, charts, and relative paths in the config file are resolved against
+		it. Other relative paths specified as flags are still relative to the current
+		working directory. Cannot be set in the config file`))
+	flags.String("remote", "origin", heredoc.Doc(`
+		The name of the Git remote used to identify changed charts. If the target
+		branch does not exist on this remote, other remotes having it are used instead`))
+	flags.String("target-branch", "master", heredoc.Doc(`
+		The name of the target branch used to identify changed charts. If not set explicitly,
+		the target branch of the pull request being built is used if the CI system provides
+		it, e.g. with GITHUB_BASE_REF or CI_MERGE_REQUEST_TARGET_BRANCH_NAME`))
+	flags.String("merge-base", "", heredoc.Doc(`
+		The merge base commit of HEAD and the target branch, e.g. as provided by the CI
+		system. If specified, it is used to identify changed charts and previous chart
+		revisions instead of computing it with 'git merge-base', which is slow or fails
+		in shallow clones. The commit must exist in the repository`))
 	flags.StringSlice("chart-dirs", []string{"charts"}, heredoc.Doc(`
 		Directories containing Helm charts. May be specified multiple times
 		or separate values with commas`))
 	flags.StringSlice("excluded-charts", []string{}, heredoc.Doc(`
 		Charts that should be skipped. May be specified multiple times
 		or separate values with commas`))
+	flags.StringSlice("excluded-chart-paths", []string{}, heredoc.Doc(`
+		Paths of charts that should be skipped, e.g. 'stable/common' in order to skip
+		this chart but not 'incubator/common'. Charts located in a specified path are
+		skipped as well. May be specified multiple times or separate values with commas`))
+	flags.String("changed-files-from", "", heredoc.Doc(`
+		A file containing a newline-separated list of changed files, or '-' to read
+		the list from stdin. If specified, changed charts are identified from this
+		list instead of diffing against the target branch with Git`))
+	flags.StringSlice("changed-paths", []string{}, heredoc.Doc(`
+		Paths within the chart directories to identify changed charts in, e.g. the
+		directory of a team in a monorepo. Changes outside these paths are ignored.
+		If not specified, changes in all chart directories are considered. May be
+		specified multiple times or separate values with commas`))
+	flags.Bool("strict-change-detection", false, heredoc.Doc(`
+		Fail when changed files are located in a directory within the chart directories
+		which cannot be mapped to a chart, e.g. because its 'Chart.yaml' is missing or it is
+		nested too deeply, instead of skipping the directory. Deleted charts and files
+		directly in the chart directories are still skipped`))
+	flags.Bool("explain-changes", false, heredoc.Doc(`
+		Print the changed files due to which charts are identified as changed next to each
+		chart, e.g. 'charts/foo (changed: templates/deployment.yaml, values.yaml)'. With
+		'--list-charts-format json', the files are listed in the 'changedFiles' field`))
+	flags.Bool("print-config", false, heredoc.Doc(`
+		Only print the effective configuration resulting from flags, environment variables,
+		and the config file as YAML and exit. Passwords and tokens are redacted`))
 }
 
 func addCommonLintAndInstallFlags(flags *pflag.FlagSet) {
@@ -81,16 +134,93 @@ func addCommonLintAndInstallFlags(flags *pflag.FlagSet) {
 		Specific charts to test. Disables changed charts detection and
 		version increment checking. May be specified multiple times
 		or separate values with commas`))
+	flags.String("failed-charts-from", "", heredoc.Doc(`
+		A JSON results file of a previous run, as written with '--results-file', to only
+		process the charts which failed in that run. If the file holds no results, changed
+		charts are processed`))
+	flags.String("results-file", "", heredoc.Doc(`
+		A file to write the results to as JSON, with the same payload as posted to
+		'--notify-webhook', whether processing charts succeeds or fails. Pass it to
+		'--failed-charts-from' in a subsequent run to only re-run the failed charts`))
+	flags.String("notify-webhook", "", heredoc.Doc(`
+		A URL to post a JSON summary of the results to if processing charts fails, e.g. a
+		Slack incoming webhook. Failing to notify the webhook does not fail the command`))
+	flags.Bool("notify-webhook-always", false, heredoc.Doc(`
+		Notify the webhook specified with '--notify-webhook' also if processing charts succeeds`))
 	flags.StringSlice("chart-repos", []string{}, heredoc.Doc(`
 		Additional chart repositories for dependency resolutions.
 		Repositories should be formatted as 'name=url' (ex: local=http://127.0.0.1:8879/charts).
 		May be specified multiple times or separate values with commas`))
+	flags.Bool("skip-repo-add", false, heredoc.Doc(`
+		Skip adding the repositories specified with '--chart-repos' and rely on the
+		repositories already configured for Helm, e.g. in a persisted '--repository-config'.
+		This avoids fetching the repository indexes again on every run`))
 	flags.StringSlice("helm-repo-extra-args", []string{}, heredoc.Doc(`
 		Additional arguments for the 'helm repo add' command to be
 		specified on a per-repo basis with an equals sign as delimiter
 		(e.g. 'myrepo=--username test --password secret'). May be specified
 		multiple times or separate values with commas`))
+	flags.String("repository-cache", "", heredoc.Doc(`
+		The path to Helm's repository cache. Persisting this directory between
+		runs avoids downloading repository indexes and dependencies again`))
+	flags.String("repository-config", "", heredoc.Doc(`
+		The path to Helm's repository config file. Should be persisted together
+		with '--repository-cache'`))
+	flags.Bool("verify-provenance", false, heredoc.Doc(`
+		Verify the provenance of chart dependencies when building them and of remote
+		charts when pulling them, passing '--verify' to Helm. Fails if a signature is
+		missing or invalid`))
+	flags.String("keyring", "", heredoc.Doc(`
+		The public keyring used for '--verify-provenance'. If not specified, Helm's
+		default keyring is used`))
+	flags.Bool("values-files-numeric-order", false, heredoc.Doc(`
+		Order CI values files by their numeric prefix (e.g. '00-base-values.yaml' before
+		'10-override-values.yaml') instead of lexically. Files without numeric prefix are
+		used after those with one, in lexical order`))
+	flags.String("values-base-dir", "", heredoc.Doc(`
+		A directory containing CI values files maintained outside of the charts, e.g. for
+		third-party charts, in a subdirectory named like each chart's directory (e.g.
+		'ci-values/<chart>/*-values.yaml'). They are used in addition to the values files
+		in the charts' 'ci' directories. If both contain a file with the same name, the
+		one in this directory is used`))
+	flags.StringSlice("remote-values-files", []string{}, heredoc.Doc(`
+		URLs of values files which are downloaded and used in addition to the values
+		files in the charts' 'ci' directories, e.g. for shared baseline configurations.
+		May be specified multiple times or separate values with commas`))
+	flags.Bool("fail-on-no-charts", false, heredoc.Doc(`
+		Fail if no charts are found to be processed, e.g. for scheduled jobs which are
+		expected to always process charts. By default, such runs succeed`))
+	flags.Bool("fail-fast", false, heredoc.Doc(`
+		Stop processing charts as soon as a chart fails, after cleaning up its releases,
+		and report the results of the charts processed so far. By default, all charts are
+		processed`))
+	flags.Int("max-charts", 0, heredoc.Doc(`
+		The maximum number of charts, including remote charts, to process in a single run,
+		guarding against runs which unexpectedly process far more charts than intended,
+		e.g. due to a misconfigured '--all' or target branch. The run fails if more charts
+		are found. Set to 0 for no limit (default), e.g. on the command line in order to
+		override a limit set in a config file`))
+	flags.Bool("list-charts", false, heredoc.Doc(`
+		Only print the charts which would be processed (respecting changed chart
+		detection, '--all', '--charts', and excluded charts) and exit`))
+	flags.String("list-charts-format", "text", heredoc.Doc(`
+		The format used by '--list-charts'. Either 'text' for one chart path per
+		line or 'json' for a JSON array of charts`))
+	flags.Int("delimiter-width", 0, heredoc.Doc(`
+		The width of delimiter lines in the output. If not specified, lines are 120
+		characters wide if stdout is a terminal and 80 characters wide otherwise`))
+	flags.Bool("ascii-results", false, heredoc.Doc(`
+		Mark passed and failed charts in the results with '[PASS]' and '[FAIL]'
+		instead of Unicode check marks`))
+	flags.Bool("preflight-checks", false, heredoc.Doc(`
+		Check that the required external tools are installed before processing charts,
+		as the 'doctor' command does`))
+	flags.Bool("preflight-dependencies", false, heredoc.Doc(`
+		Check that the dependencies of all charts to be processed resolve before processing
+		any chart, i.e. that local dependencies exist and that remote ones are available
+		in their repositories in a matching version, and report all problems at once`))
+	addPreflightFlags(flags)
 	flags.Bool("debug", false, heredoc.Doc(`
-		Print CLI calls of external tools to stdout (Note: depending on helm-extra-args
-		passed, this may reveal sensitive data)`))
+		Print CLI calls of external tools to stdout (Note: depending on helm-extra-args and
+		kubectl-extra-args passed, this may reveal sensitive data)`))
 }
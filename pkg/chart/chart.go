@@ -15,12 +15,27 @@
 package chart
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"text/template"
+	"time"
 
 	"github.com/Masterminds/semver"
+	"github.com/hashicorp/go-multierror"
+	"gopkg.in/yaml.v2"
 
 	"github.com/helm/chart-testing/v3/pkg/config"
 	"github.com/helm/chart-testing/v3/pkg/exec"
@@ -31,6 +46,35 @@ import (
 
 const maxNameLength = 63
 
+// valuesTemplateSuffix is the suffix of CI values files which are Go templates rendered before they are used.
+const valuesTemplateSuffix = ".tpl"
+
+// testRetryBackoff is the time to wait before the first retry of failed tests. It grows linearly with each retry.
+var testRetryBackoff = 10 * time.Second
+
+// webhookTimeout is the time to wait for the webhook notified about results to respond.
+var webhookTimeout = 10 * time.Second
+
+// waitForJobsMinVersion is the first Helm version supporting '--wait-for-jobs'.
+var waitForJobsMinVersion = semver.MustParse("3.5.0")
+
+// podDetailsParallelism is the maximum number of pods whose descriptions and logs are fetched concurrently.
+var podDetailsParallelism = 4
+
+// namespaceAnnotation is the Chart.yaml annotation for charts which must be installed into a specific namespace.
+const namespaceAnnotation = "ct.helm.sh/namespace"
+
+// releaseLabelAnnotation is the Chart.yaml annotation for charts which label their resources with the release name
+// using another label than the configured release label.
+const releaseLabelAnnotation = "ct.helm.sh/release-label"
+
+// replacementAnnotation is the Chart.yaml annotation pointing deprecated charts to their replacement.
+const replacementAnnotation = "ct.helm.sh/replacement"
+
+// expectLintFailureAnnotation is the Chart.yaml annotation for intentionally broken charts, e.g. fixtures verifying
+// lint rules, which are expected to fail linting.
+const expectLintFailureAnnotation = "ct.helm.sh/expect-lint-failure"
+
 // Git is the Interface that wraps Git operations.
 //
 // FileExistsOnBranch checks whether file exists on the specified remote/branch.
@@ -47,8 +91,14 @@ const maxNameLength = 63
 //
 // GetUrlForRemote returns the repo URL for the specified remote.
 //
-// ValidateRepository checks that the current working directory is a valid git repository,
-// and returns nil if valid.
+// ListRemotes returns the names of all remotes.
+//
+// BranchExists checks whether the specified branch exists on the specified remote.
+//
+// CommitExists checks whether the specified commit exists.
+//
+// ValidateRepository checks that the repository root if configured, or else the current working directory, is a valid
+// git repository, and returns nil if valid.
 type Git interface {
 	FileExistsOnBranch(file string, remote string, branch string) bool
 	Show(file string, remote string, branch string) (string, error)
@@ -57,6 +107,9 @@ type Git interface {
 	MergeBase(commit1 string, commit2 string) (string, error)
 	ListChangedFilesInDirs(commit string, dirs ...string) ([]string, error)
 	GetUrlForRemote(remote string) (string, error)
+	ListRemotes() ([]string, error)
+	BranchExists(remote string, branch string) bool
+	CommitExists(commit string) bool
 	ValidateRepository() error
 }
 
@@ -66,8 +119,23 @@ type Git interface {
 //
 // BuildDependencies builds the chart's dependencies
 //
-// LintWithValues runs `helm lint` for the given chart using the specified values file.
-// Pass a zero value for valuesFile in order to run lint without specifying a values file.
+// LintWithValuesAndCaptureOutput runs `helm lint` for the given chart using the specified values file and returns
+// the output, also if linting fails. Pass a zero value for valuesFile in order to run lint without specifying a values
+// file.
+//
+// UnitTest runs `helm unittest` for the given chart using the helm-unittest plugin.
+//
+// RunPlugin runs the given Helm plugin subcommand, optionally followed by arguments (e.g. 'unittest --strict'), for
+// the given chart.
+//
+// Pull runs `helm pull` for the given chart reference (e.g. 'repo/chart') and extracts the chart into the destination
+// directory. Pass a zero value for version in order to pull the latest version.
+//
+// ResolveChart checks that the given chart reference resolves, optionally in the repository with the specified URL
+// and with a version matching the specified version or constraint.
+//
+// TemplateWithValues runs `helm template` for the given chart using the specified values file and the same arguments
+// as InstallWithValues, including CRDs, and returns the rendered manifests. Pass a zero value for valuesFile in order to render the chart with its default values.
 //
 // InstallWithValues runs `helm install` for the given chart using the specified values file.
 // Pass a zero value for valuesFile in order to run install without specifying a values file.
@@ -75,50 +143,107 @@ type Git interface {
 // Upgrade runs `helm upgrade` against an existing release, and re-uses the previously computed values.
 //
 // Test runs `helm test` against an existing release. Set the cleanup argument to true in order
-// to clean up test pods created by helm after the test command completes.
+// to clean up test pods created by helm after the test command completes. If filters are specified,
+// they are passed to helm in order to select the tests to run (e.g. 'name=smoke-test').
 //
 // DeleteRelease purges the specified Helm release.
+//
+// Validate checks that Helm works and that post-renderers passed with the extra arguments are available.
 type Helm interface {
 	AddRepo(name string, url string, extraArgs []string) error
 	BuildDependencies(chart string) error
-	LintWithValues(chart string, valuesFile string) error
+	LintWithValuesAndCaptureOutput(chart string, valuesFile string) (string, error)
+	UnitTest(chart string) error
+	RunPlugin(plugin string, chart string) error
+	Pull(chart string, version string, destination string) error
+	ResolveChart(chart string, repository string, version string) error
+	TemplateWithValues(chart string, valuesFile string) (string, error)
 	InstallWithValues(chart string, valuesFile string, namespace string, release string) error
 	Upgrade(chart string, namespace string, release string) error
-	Test(namespace string, release string) error
+	Test(namespace string, release string, filters []string) error
 	DeleteRelease(namespace string, release string)
 	Version() (string, error)
+	Validate() error
 }
 
 // Kubectl is the interface that wraps kubectl operations
 //
 // DeleteNamespace deletes a namespace
 //
+// CreateDockerSecret creates a Docker registry secret from a Docker config JSON file
+//
+// CreateServiceAccount creates a service account
+//
+// BindClusterRole binds a cluster role to a service account in a namespace
+//
 // WaitForDeployments waits for a deployment to become ready
 //
+// WaitForResourcesDeleted waits for all resources matching selector in namespace, except ignored, to be deleted
+//
+// ListNamespacedResources lists all resources matching selector in namespace
+//
 // GetPodsforDeployment gets all pods for a deployment
 //
 // GetPods gets pods for the given args
 //
-// GetEvents prints all events for namespace
+// GetEvents returns all events for namespace
+//
+// GetAll returns all resources in namespace
 //
-// DescribePod prints the pod's description
+// GetNonReadyPods gets all pods in namespace which are not ready
 //
-// Logs prints the logs of container
+// DescribePod returns the pod's description
+//
+// Logs returns the logs of container, limited to the last tail lines if tail is greater than 0
 //
 // GetInitContainers gets all init containers of pod
 //
 // GetContainers gets all containers of pod
+//
+// GetFailedContainers gets all init containers and containers of pod which failed
+//
+// DeleteClusterResources deletes all cluster-scoped resources matching selector
+//
+// ListResourcesBySelector lists all resources matching selector, namespaced or cluster-scoped
+//
+// ApplyManifests applies the manifests in the specified files
+//
+// WaitForCondition waits for the resources in the specified files to meet condition
+//
+// GetNamespaces returns the namespaces matching selector, mapped to their creation time
+//
+// WaitForAutoscaling waits for the HPAs and PDBs matching selector in namespace to become ready
+//
+// CheckResourceExists checks whether resource, formatted as 'kind/name', exists in namespace
+//
+// GetReleases returns the names of the Helm releases in namespace
 type Kubectl interface {
 	CreateNamespace(namespace string) error
 	DeleteNamespace(namespace string)
+	CreateDockerSecret(namespace string, name string, dockerConfig string) error
+	CreateServiceAccount(namespace string, name string) error
+	BindClusterRole(namespace string, serviceAccount string, clusterRole string) error
 	WaitForDeployments(namespace string, selector string) error
+	WaitForResourcesDeleted(namespace string, selector string, ignored []string) error
+	ListNamespacedResources(namespace string, selector string) ([]string, error)
 	GetPodsforDeployment(namespace string, deployment string) ([]string, error)
 	GetPods(args ...string) ([]string, error)
-	GetEvents(namespace string) error
-	DescribePod(namespace string, pod string) error
-	Logs(namespace string, pod string, container string) error
+	GetEvents(namespace string) (string, error)
+	GetAll(namespace string) (string, error)
+	GetNonReadyPods(namespace string) ([]string, error)
+	DescribePod(namespace string, pod string) (string, error)
+	Logs(namespace string, pod string, container string, tail int) (string, error)
 	GetInitContainers(namespace string, pod string) ([]string, error)
 	GetContainers(namespace string, pod string) ([]string, error)
+	GetFailedContainers(namespace string, pod string) ([]string, error)
+	DeleteClusterResources(selector string) error
+	ListResourcesBySelector(selector string) ([]string, error)
+	ApplyManifests(files []string) error
+	WaitForCondition(condition string, files []string) error
+	GetNamespaces(selector string) (map[string]time.Time, error)
+	GetReleases(namespace string) ([]string, error)
+	WaitForAutoscaling(namespace string, selector string) error
+	CheckResourceExists(namespace string, resource string) (bool, error)
 }
 
 // Linter is the interface that wrap linting operations
@@ -126,9 +251,12 @@ type Kubectl interface {
 // YamlLint runs `yamllint` on the specified file with the specified configuration
 //
 // Yamale runs `yamale` on the specified file with the specified schema file
+//
+// Conftest runs `conftest` on the specified rendered manifests with the policies in the specified directory
 type Linter interface {
 	YamlLint(yamlFile string, configFile string) error
 	Yamale(yamlFile string, schemaFile string) error
+	Conftest(manifest string, policyDir string) error
 }
 
 // DirectoryLister is the interface
@@ -152,6 +280,13 @@ type AccountValidator interface {
 	Validate(repoDomain string, account string) error
 }
 
+// ScriptRunner is the interface that wraps running shell scripts
+//
+// Run runs the specified script with the specified additional environment variables in the form 'key=value'
+type ScriptRunner interface {
+	Run(script string, env []string) error
+}
+
 // Chart represents a Helm chart, and can be initalized with the NewChart method.
 type Chart struct {
 	path          string
@@ -173,7 +308,8 @@ func (c *Chart) String() string {
 	return fmt.Sprintf(`%s => (version: "%s", path: "%s")`, c.yaml.Name, c.yaml.Version, c.Path())
 }
 
-// ValuesFilePathsForCI returns all file paths in the 'ci' subfolder of the chart directory matching the pattern '*-values.yaml'
+// ValuesFilePathsForCI returns all file paths in the 'ci' subfolder of the chart directory matching the pattern
+// '*-values.yaml', or '*-values.yaml.tpl' for values templates, in lexical order
 func (c *Chart) ValuesFilePathsForCI() []string {
 	return c.ciValuesPaths
 }
@@ -189,18 +325,24 @@ func (c *Chart) HasCIValuesFile(path string) bool {
 	return false
 }
 
+// HasLockFile checks whether the chart has a 'Chart.lock' file, or a 'requirements.lock' file for charts with
+// apiVersion v1, pinning the versions of its dependencies.
+func (c *Chart) HasLockFile() bool {
+	return util.FileExists(filepath.Join(c.path, "Chart.lock")) || util.FileExists(filepath.Join(c.path, "requirements.lock"))
+}
+
 // CreateInstallParams generates a randomized release name and namespace based on the chart path
-// and optional buildID. If a buildID is specified, it will be part of the generated namespace.
+// and optional buildID. If a buildID is specified, it will be part of the generated release name and namespace.
 func (c *Chart) CreateInstallParams(buildID string) (release string, namespace string) {
 	release = filepath.Base(c.Path())
 	if release == "." || release == "/" {
 		yaml := c.Yaml()
 		release = yaml.Name
 	}
-	namespace = release
 	if buildID != "" {
-		namespace = fmt.Sprintf("%s-%s", namespace, buildID)
+		release = fmt.Sprintf("%s-%s", release, buildID)
 	}
+	namespace = release
 	randomSuffix := util.RandomString(10)
 	release = util.SanitizeName(fmt.Sprintf("%s-%s", release, randomSuffix), maxNameLength)
 	namespace = util.SanitizeName(fmt.Sprintf("%s-%s", namespace, randomSuffix), maxNameLength)
@@ -215,10 +357,48 @@ func NewChart(chartPath string) (*Chart, error) {
 		return nil, err
 	}
 	matches, _ := filepath.Glob(filepath.Join(chartPath, "ci", "*-values.yaml"))
+	templateMatches, _ := filepath.Glob(filepath.Join(chartPath, "ci", "*-values.yaml"+valuesTemplateSuffix))
+	matches = append(matches, templateMatches...)
+	sort.Strings(matches)
 	return &Chart{chartPath, yaml, matches}, nil
 }
 
+// loadChart returns the chart in the specified directory like NewChart, but parses its 'Chart.yaml' only once per
+// run, so that all checks see the same metadata.
+func (t *Testing) loadChart(chartPath string) (*Chart, error) {
+	key := util.NormalizePath(chartPath)
+	if chart, ok := t.charts[key]; ok {
+		return chart, nil
+	}
+
+	chart, err := NewChart(chartPath)
+	if err != nil {
+		return nil, err
+	}
+	if t.charts == nil {
+		t.charts = map[string]*Chart{}
+	}
+	t.charts[key] = chart
+	return chart, nil
+}
+
+// forgetCharts removes the charts within the specified directory from the charts loaded during the run, e.g. those of
+// the previous revision worktree once it has been removed.
+func (t *Testing) forgetCharts(dir string) {
+	dir = util.NormalizePath(dir)
+	for key := range t.charts {
+		if key == dir || strings.HasPrefix(key, dir+"/") {
+			delete(t.charts, key)
+		}
+	}
+}
+
+// Testing processes charts according to its configuration. ResultCallback, if set, is invoked with the result
+// of each chart as soon as it is available, which allows integrations to report results incrementally. Output, if
+// set, receives the results, events, resources, pod details, and logs printed by Testing instead of stdout.
 type Testing struct {
+	ResultCallback           func(result TestResult)
+	Output                   io.Writer
 	config                   config.Configuration
 	helm                     Helm
 	kubectl                  Kubectl
@@ -227,7 +407,13 @@ type Testing struct {
 	accountValidator         AccountValidator
 	directoryLister          DirectoryLister
 	chartUtils               ChartUtils
+	scriptRunner             ScriptRunner
 	previousRevisionWorktree string
+	resolvedRemote           string
+	changedChartFiles        map[string][]string
+	remoteValuesFiles        []string
+	matrixValuesFiles        []string
+	charts                   map[string]*Chart
 }
 
 // TestResults holds results and overall status
@@ -236,26 +422,185 @@ type TestResults struct {
 	TestResults    []TestResult
 }
 
-// TestResult holds test results for a specific chart
+// Status is the outcome of processing a chart.
+type Status string
+
+const (
+	StatusPassed  Status = "Passed"
+	StatusFailed  Status = "Failed"
+	StatusSkipped Status = "Skipped"
+)
+
+// TestResult holds test results for a specific chart. SkipReason is set if the chart has been skipped. KubeVersion
+// is set when testing against a Kubernetes versions matrix. ExpectedError is set if the chart failed as expected.
+// LintFindings holds the findings of linting the chart, which are included in the JSON results file along with the
+// type of the error.
 type TestResult struct {
-	Chart *Chart
-	Error error
+	Chart         *Chart
+	Status        Status
+	Error         error
+	ExpectedError error
+	SkipReason    string
+	KubeVersion   string
+	MatrixEntry   string
+	LintFindings  []LintFinding
+}
+
+// LintFinding is a finding reported by `helm lint`, e.g. '[WARNING] templates/: directory not found'. Code is the
+// lint rule reporting the finding, as far as it can be inferred from the path: 'chartfile' for 'Chart.yaml', 'values'
+// for the values file and schema, 'templates' for templates, and 'other' otherwise.
+type LintFinding struct {
+	Code       string `json:"code"`
+	Severity   string `json:"severity"`
+	Message    string `json:"message"`
+	Path       string `json:"path,omitempty"`
+	ValuesFile string `json:"valuesFile,omitempty"`
+}
+
+// MarshalJSON encodes the result with the chart's path and, if the result has an error, its message and type as
+// returned by ErrorType.
+func (r TestResult) MarshalJSON() ([]byte, error) {
+	result := struct {
+		Chart         string        `json:"chart"`
+		Status        Status        `json:"status"`
+		Error         string        `json:"error,omitempty"`
+		ErrorType     string        `json:"errorType,omitempty"`
+		ExpectedError string        `json:"expectedError,omitempty"`
+		SkipReason    string        `json:"skipReason,omitempty"`
+		KubeVersion   string        `json:"kubeVersion,omitempty"`
+		MatrixEntry   string        `json:"matrixEntry,omitempty"`
+		LintFindings  []LintFinding `json:"lintFindings,omitempty"`
+	}{
+		Status:       r.withStatus().Status,
+		ErrorType:    ErrorType(r.Error),
+		SkipReason:   r.SkipReason,
+		KubeVersion:  r.KubeVersion,
+		MatrixEntry:  r.MatrixEntry,
+		LintFindings: r.LintFindings,
+	}
+	if r.Chart != nil {
+		result.Chart = r.Chart.Path()
+	}
+	if r.Error != nil {
+		result.Error = r.Error.Error()
+	}
+	if r.ExpectedError != nil {
+		result.ExpectedError = r.ExpectedError.Error()
+	}
+	return json.Marshal(result)
+}
+
+// LintError is the error of a chart failing linting or validation.
+type LintError struct {
+	Err error
+}
+
+func (e *LintError) Error() string { return e.Err.Error() }
+func (e *LintError) Unwrap() error { return e.Err }
+
+// InstallError is the error of a chart failing to install or its tests failing.
+type InstallError struct {
+	Err error
+}
+
+func (e *InstallError) Error() string { return e.Err.Error() }
+func (e *InstallError) Unwrap() error { return e.Err }
+
+// UpgradeError is the error of a chart failing to upgrade from its previous revision or its tests failing afterwards.
+type UpgradeError struct {
+	Err error
+}
+
+func (e *UpgradeError) Error() string { return e.Err.Error() }
+func (e *UpgradeError) Unwrap() error { return e.Err }
+
+// VersionError is the error of a chart's version not being incremented or being invalid.
+type VersionError struct {
+	Err error
+}
+
+func (e *VersionError) Error() string { return e.Err.Error() }
+func (e *VersionError) Unwrap() error { return e.Err }
+
+// ErrorType classifies the error of a result as 'lint', 'install', 'upgrade', or 'version'. Other errors are classified
+// as 'other' and a nil error as an empty string.
+func ErrorType(err error) string {
+	var (
+		lintError    *LintError
+		installError *InstallError
+		upgradeError *UpgradeError
+		versionError *VersionError
+	)
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &versionError):
+		return "version"
+	case errors.As(err, &lintError):
+		return "lint"
+	case errors.As(err, &upgradeError):
+		return "upgrade"
+	case errors.As(err, &installError):
+		return "install"
+	default:
+		return "other"
+	}
+}
+
+// skippedResult returns a skipped result for the specified chart.
+func skippedResult(chart *Chart, reason string) TestResult {
+	return TestResult{Chart: chart, Status: StatusSkipped, SkipReason: reason}
+}
+
+// withStatus returns the result with its status set according to its error, unless the status is already set.
+func (r TestResult) withStatus() TestResult {
+	if r.Status != "" {
+		return r
+	}
+	if r.Error != nil {
+		r.Status = StatusFailed
+	} else {
+		r.Status = StatusPassed
+	}
+	return r
 }
 
 // NewTesting creates a new Testing struct with the given config.
 func NewTesting(config config.Configuration) (Testing, error) {
 	procExec := exec.NewProcessExecutor(config.Debug)
 	extraArgs := strings.Fields(config.HelmExtraArgs)
+	if config.HelmWaitTimeout != "" {
+		extraArgs = append(extraArgs, "--timeout", config.HelmWaitTimeout)
+	}
+	kubectlExtraArgs := strings.Fields(config.KubectlExtraArgs)
+	if config.KubeContext != "" {
+		extraArgs = append(extraArgs, "--kube-context", config.KubeContext)
+		kubectlExtraArgs = append(kubectlExtraArgs, "--context", config.KubeContext)
+	}
+
+	helmOptions := tool.HelmOptions{
+		ExtraArgs:            extraArgs,
+		RepositoryCache:      config.RepositoryCache,
+		RepositoryConfig:     config.RepositoryConfig,
+		DependencyUpdate:     config.InstallDependencyUpdate,
+		WaitForJobs:          config.WaitForJobs,
+		IncludeDefaultValues: config.IncludeDefaultValues,
+		UnitTestsPath:        config.UnitTestsPath,
+		VerifyProvenance:     config.VerifyProvenance,
+		Keyring:              config.Keyring,
+	}
 
 	testing := Testing{
+		Output:           os.Stdout,
 		config:           config,
-		helm:             tool.NewHelm(procExec, extraArgs),
-		git:              tool.NewGit(procExec),
-		kubectl:          tool.NewKubectl(procExec),
+		helm:             tool.NewHelm(procExec, helmOptions),
+		git:              tool.NewGit(procExec, config.RepositoryRoot),
+		kubectl:          tool.NewKubectl(procExec, kubectlExtraArgs, config.NamespaceDeleteTimeout, config.KubectlWaitTimeout, config.WaitExcludeSelector),
 		linter:           tool.NewLinter(procExec),
-		accountValidator: tool.AccountValidator{},
+		accountValidator: tool.NewAccountValidator(config.AccountValidationTimeout),
 		directoryLister:  util.DirectoryLister{},
 		chartUtils:       util.ChartUtils{},
+		scriptRunner:     tool.NewScriptRunner(procExec),
 	}
 
 	versionString, err := testing.helm.Version()
@@ -271,27 +616,78 @@ func NewTesting(config config.Configuration) (Testing, error) {
 	if version.Major() < 3 {
 		return testing, fmt.Errorf("minimum required Helm version is v3.0.0; found: %s", version)
 	}
+	if config.WaitForJobs && version.LessThan(waitForJobsMinVersion) {
+		return testing, fmt.Errorf("'--wait-for-jobs' requires Helm v%s or later; found: %s", waitForJobsMinVersion, version)
+	}
 	return testing, nil
 }
 
 // computePreviousRevisionPath converts any file or directory path to the same path in the
 // previous revision's working tree.
 func (t *Testing) computePreviousRevisionPath(fileOrDirPath string) string {
+	if root := t.config.RepositoryRoot; root != "" {
+		if rel, err := filepath.Rel(root, fileOrDirPath); err == nil {
+			fileOrDirPath = rel
+		}
+	}
 	return filepath.Join(t.previousRevisionWorktree, fileOrDirPath)
 }
 
+// addRepos adds the chart repositories, unless adding them is skipped in favor of those already configured for Helm.
+func (t *Testing) addRepos() error {
+	if t.config.SkipRepoAdd {
+		if len(t.config.ChartRepos) > 0 {
+			fmt.Println("Skipping adding chart repositories. Using the repositories configured for Helm.")
+		}
+		return nil
+	}
+
+	repoArgs := map[string][]string{}
+
+	for _, repo := range t.config.HelmRepoExtraArgs {
+		repoSlice := strings.SplitN(repo, "=", 2)
+		name := repoSlice[0]
+		repoExtraArgs := strings.Fields(repoSlice[1])
+		repoArgs[name] = repoExtraArgs
+	}
+
+	for _, repo := range t.config.ChartRepos {
+		repoSlice := strings.SplitN(repo, "=", 2)
+		name := repoSlice[0]
+		url := repoSlice[1]
+
+		repoExtraArgs := repoArgs[name]
+		if err := t.helm.AddRepo(name, url, repoExtraArgs); err != nil {
+			return errors.Wrapf(err, "Error adding repo: %s=%s", name, url)
+		}
+	}
+	return nil
+}
+
 func (t *Testing) processCharts(action func(chart *Chart) TestResult) ([]TestResult, error) {
 	var results []TestResult
-	chartDirs, err := t.FindChartDirsToBeProcessed()
-	if err != nil {
-		return nil, errors.Wrap(err, "Error identifying charts to process")
-	} else if len(chartDirs) == 0 {
+	var chartDirs []string
+	// Remote charts are tested instead of changed charts, but in addition to specific or all charts
+	if len(t.config.RemoteCharts) == 0 || len(t.config.Charts) > 0 || t.config.ProcessAllCharts {
+		var err error
+		if chartDirs, err = t.FindChartDirsToBeProcessed(); err != nil {
+			return nil, errors.Wrap(err, "Error identifying charts to process")
+		}
+	}
+	if len(chartDirs) == 0 && len(t.config.RemoteCharts) == 0 {
+		if t.config.FailOnNoCharts {
+			return results, errors.New("No charts found to process")
+		}
 		return results, nil
 	}
 
+	if count := len(chartDirs) + len(t.config.RemoteCharts); t.config.MaxCharts > 0 && count > t.config.MaxCharts {
+		return nil, fmt.Errorf("Found %d charts to process, which exceeds the limit of %d charts set by '--max-charts'", count, t.config.MaxCharts)
+	}
+
 	var charts []*Chart
 	for _, dir := range chartDirs {
-		chart, err := NewChart(dir)
+		chart, err := t.loadChart(dir)
 		if err != nil {
 			return nil, err
 		}
@@ -303,29 +699,34 @@ func (t *Testing) processCharts(action func(chart *Chart) TestResult) ([]TestRes
 	fmt.Println(" Charts to be processed:")
 	util.PrintDelimiterLine("-")
 	for _, chart := range charts {
-		fmt.Printf(" %s\n", chart)
+		fmt.Printf(" %s%s\n", chart, t.ExplainChanges(chart.Path()))
+	}
+	for _, remoteChart := range t.config.RemoteCharts {
+		fmt.Printf(" %s (remote)\n", remoteChart)
 	}
 	util.PrintDelimiterLine("-")
 	fmt.Println()
 
-	repoArgs := map[string][]string{}
+	if err := t.helm.Validate(); err != nil {
+		return nil, errors.Wrap(err, "Invalid Helm environment")
+	}
 
-	for _, repo := range t.config.HelmRepoExtraArgs {
-		repoSlice := strings.SplitN(repo, "=", 2)
-		name := repoSlice[0]
-		repoExtraArgs := strings.Fields(repoSlice[1])
-		repoArgs[name] = repoExtraArgs
+	if err := t.addRepos(); err != nil {
+		return nil, err
 	}
 
-	for _, repo := range t.config.ChartRepos {
-		repoSlice := strings.SplitN(repo, "=", 2)
-		name := repoSlice[0]
-		url := repoSlice[1]
+	if t.config.PreflightDependencies {
+		if err := t.ResolveDependencies(charts); err != nil {
+			return nil, errors.Wrap(err, "Error resolving dependencies")
+		}
+	}
 
-		repoExtraArgs := repoArgs[name]
-		if err := t.helm.AddRepo(name, url, repoExtraArgs); err != nil {
-			return nil, errors.Wrapf(err, "Error adding repo: %s=%s", name, url)
+	if len(t.config.RemoteValuesFiles) > 0 {
+		cleanup, err := t.downloadRemoteValuesFiles()
+		if err != nil {
+			return nil, errors.Wrap(err, "Error downloading remote values files")
 		}
+		defer cleanup()
 	}
 
 	testResults := TestResults{
@@ -339,36 +740,50 @@ func (t *Testing) processCharts(action func(chart *Chart) TestResult) ([]TestRes
 		if err != nil {
 			return results, errors.Wrap(err, "Error identifying merge base")
 		}
-		// Add worktree for the target revision
-		worktreePath, err := ioutil.TempDir("./", "ct_previous_revision")
+		removeWorktree, err := t.addPreviousRevisionWorktree(mergeBase)
 		if err != nil {
-			return results, errors.Wrap(err, "Could not create previous revision directory")
+			return results, err
 		}
-		t.previousRevisionWorktree = worktreePath
-		err = t.git.AddWorktree(worktreePath, mergeBase)
+		defer removeWorktree()
+
+		t.buildPreviousRevisionDependencies(charts)
+	}
+
+	pulledCharts := map[*Chart]bool{}
+	if len(t.config.RemoteCharts) > 0 {
+		remoteCharts, cleanup, err := t.pullRemoteCharts()
 		if err != nil {
-			return results, errors.Wrap(err, "Could not create worktree for previous revision")
+			return results, errors.Wrap(err, "Error pulling remote charts")
 		}
-		defer t.git.RemoveWorktree(worktreePath)
-
-		for _, chart := range charts {
-			if err := t.helm.BuildDependencies(t.computePreviousRevisionPath(chart.Path())); err != nil {
-				// Only print error (don't exit) if building dependencies for previous revision fails.
-				fmt.Println(errors.Wrapf(err, "Error building dependencies for previous revision of chart '%s'\n", chart))
-			}
+		defer cleanup()
+		for _, chart := range remoteCharts {
+			pulledCharts[chart] = true
 		}
+		charts = append(charts, remoteCharts...)
 	}
 
-	for _, chart := range charts {
-		if err := t.helm.BuildDependencies(chart.Path()); err != nil {
-			return nil, errors.Wrapf(err, "Error building dependencies for chart '%s'", chart)
+	for i, chart := range charts {
+		if pulledCharts[chart] {
+			// Packaged charts already contain their dependencies
+		} else if t.config.InstallDependencyUpdate && !chart.HasLockFile() {
+			fmt.Printf("Chart '%s' has no lock file. Dependencies are updated on install.\n", chart)
+		} else if err := t.helm.BuildDependencies(chart.Path()); err != nil {
+			err = errors.Wrapf(err, "Error building dependencies for chart '%s'", chart)
+			t.notifyResult(TestResult{Chart: chart, Status: StatusFailed, Error: err})
+			return nil, err
 		}
 
-		result := action(chart)
+		result := action(chart).withStatus()
+		t.notifyResult(result)
 		if result.Error != nil {
 			testResults.OverallSuccess = false
 		}
 		results = append(results, result)
+
+		if result.Error != nil && t.config.FailFast && i < len(charts)-1 {
+			fmt.Printf("Chart '%s' failed. Not processing the remaining %d chart(s).\n", chart, len(charts)-i-1)
+			break
+		}
 	}
 	if testResults.OverallSuccess {
 		return results, nil
@@ -377,6 +792,40 @@ func (t *Testing) processCharts(action func(chart *Chart) TestResult) ([]TestRes
 	return results, errors.New("Error processing charts")
 }
 
+// addPreviousRevisionWorktree checks out the revision in a new worktree within the repository root, which previous
+// revisions of charts are read from, and returns a function removing it again. The worktree path is absolute, so that
+// Git, which runs in the repository root, and ct resolve it to the same directory.
+func (t *Testing) addPreviousRevisionWorktree(revision string) (func(), error) {
+	root := t.config.RepositoryRoot
+	if root == "" {
+		root = "."
+	}
+	worktreePath, err := ioutil.TempDir(root, "ct_previous_revision")
+	if err == nil {
+		worktreePath, err = filepath.Abs(worktreePath)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not create previous revision directory")
+	}
+	if err := t.git.AddWorktree(worktreePath, revision); err != nil {
+		os.RemoveAll(worktreePath)
+		return nil, errors.Wrap(err, "Could not create worktree for previous revision")
+	}
+	t.previousRevisionWorktree = worktreePath
+	return func() {
+		t.git.RemoveWorktree(worktreePath)
+		os.RemoveAll(worktreePath)
+		t.forgetCharts(worktreePath)
+	}, nil
+}
+
+// notifyResult invokes the result callback, if set, with the specified result.
+func (t *Testing) notifyResult(result TestResult) {
+	if t.ResultCallback != nil {
+		t.ResultCallback(result)
+	}
+}
+
 // LintCharts lints charts (changed, all, specific) depending on the configuration.
 func (t *Testing) LintCharts() ([]TestResult, error) {
 	return t.processCharts(t.LintChart)
@@ -384,121 +833,1252 @@ func (t *Testing) LintCharts() ([]TestResult, error) {
 
 // InstallCharts install charts (changed, all, specific) depending on the configuration.
 func (t *Testing) InstallCharts() ([]TestResult, error) {
-	return t.processCharts(t.InstallChart)
+	t.ensureBuildID()
+	return t.processKubeVersionsMatrix(func(t *Testing) ([]TestResult, error) {
+		return t.processCharts(t.InstallChart)
+	})
 }
 
 // LintAndInstallCharts first lints and then installs charts (changed, all, specific) depending on the configuration.
 func (t *Testing) LintAndInstallCharts() ([]TestResult, error) {
-	return t.processCharts(t.LintAndInstallChart)
+	t.ensureBuildID()
+	return t.processKubeVersionsMatrix(func(t *Testing) ([]TestResult, error) {
+		return t.processCharts(t.LintAndInstallChart)
+	})
 }
 
-// PrintResults writes test results to stdout.
-func (t *Testing) PrintResults(results []TestResult) {
-	util.PrintDelimiterLine("-")
-	if results != nil {
-		for _, result := range results {
-			err := result.Error
-			if err != nil {
-				fmt.Printf(" %s %s > %s\n", "✖︎", result.Chart, err)
-			} else {
-				fmt.Printf(" %s %s\n", "✔︎", result.Chart)
-			}
-		}
-	} else {
-		fmt.Println("No chart changes detected.")
+// ensureBuildID generates a short random build ID if none is configured, so that release names and namespaces of
+// concurrent runs, e.g. on a developer's machine, do not collide.
+func (t *Testing) ensureBuildID() {
+	if t.config.BuildId != "" {
+		return
 	}
-	util.PrintDelimiterLine("-")
+	t.config.BuildId = util.RandomString(6)
+	fmt.Printf("Using generated build ID '%s'\n", t.config.BuildId)
 }
 
-// LintChart lints the specified chart.
-func (t *Testing) LintChart(chart *Chart) TestResult {
-	fmt.Printf("Linting chart '%s'\n", chart)
+// processKubeVersionsMatrix runs process once for each entry of the Kubernetes versions matrix against the
+// entry's kube context and tags the results with the entry's version. Without a matrix, process runs once
+// against the configured kube context.
+func (t *Testing) processKubeVersionsMatrix(process func(t *Testing) ([]TestResult, error)) ([]TestResult, error) {
+	if len(t.config.KubeVersionsMatrix) == 0 {
+		return process(t)
+	}
 
-	result := TestResult{Chart: chart}
+	var results []TestResult
+	overallSuccess := true
+	for _, entry := range t.config.KubeVersionsMatrix {
+		entrySlice := strings.SplitN(entry, "=", 2)
+		kubeVersion := entrySlice[0]
+		kubeContext := entrySlice[1]
+
+		fmt.Printf("Testing charts against Kubernetes %s (context '%s')...\n", kubeVersion, kubeContext)
+
+		cfg := t.config
+		cfg.KubeVersionsMatrix = nil
+		cfg.KubeContext = kubeContext
+		versionTesting, err := NewTesting(cfg)
+		if err != nil {
+			return results, errors.Wrapf(err, "Error setting up testing for Kubernetes %s", kubeVersion)
+		}
+		versionTesting.Output = t.Output
+		if t.ResultCallback != nil {
+			versionTesting.ResultCallback = func(result TestResult) {
+				result.KubeVersion = kubeVersion
+				t.ResultCallback(result)
+			}
+		}
 
-	if t.config.CheckVersionIncrement {
-		if err := t.CheckVersionIncrement(chart); err != nil {
-			result.Error = err
-			return result
+		versionResults, err := process(&versionTesting)
+		if err != nil {
+			if versionResults == nil {
+				return results, err
+			}
+			overallSuccess = false
+		}
+		for _, result := range versionResults {
+			result.KubeVersion = kubeVersion
+			results = append(results, result)
+		}
+		if !overallSuccess && t.config.FailFast {
+			break
 		}
 	}
 
-	chartYaml := filepath.Join(chart.Path(), "Chart.yaml")
-	valuesYaml := filepath.Join(chart.Path(), "values.yaml")
-	valuesFiles := chart.ValuesFilePathsForCI()
-
-	if t.config.ValidateChartSchema {
-		if err := t.linter.Yamale(chartYaml, t.config.ChartYamlSchema); err != nil {
-			result.Error = err
-			return result
-		}
+	if overallSuccess {
+		return results, nil
 	}
 
-	if t.config.ValidateYaml {
-		yamlFiles := append([]string{chartYaml, valuesYaml}, valuesFiles...)
-		for _, yamlFile := range yamlFiles {
-			if err := t.linter.YamlLint(yamlFile, t.config.LintConf); err != nil {
-				result.Error = err
-				return result
-			}
-		}
+	return results, errors.New("Error processing charts")
+}
+
+// PruneNamespaces deletes the namespaces created by ct, which are left behind if ct is killed while testing charts,
+// along with the releases left in them. Namespaces younger than the configured minimum age are not deleted, as they
+// may still be in use by a concurrent run.
+func (t *Testing) PruneNamespaces() error {
+	namespaces, err := t.kubectl.GetNamespaces(tool.NamespaceLabel)
+	if err != nil {
+		return errors.Wrap(err, "Error listing namespaces created by ct")
 	}
 
-	if t.config.ValidateMaintainers {
-		if err := t.ValidateMaintainers(chart); err != nil {
-			result.Error = err
-			return result
+	var names []string
+	for namespace, created := range namespaces {
+		if age := time.Since(created); age < t.config.PruneMinAge {
+			fmt.Printf("Keeping namespace '%s', which was created %s ago\n", namespace, age.Round(time.Second))
+			continue
 		}
+		names = append(names, namespace)
 	}
+	sort.Strings(names)
 
-	// Lint with defaults if no values files are specified.
-	if len(valuesFiles) == 0 {
-		valuesFiles = append(valuesFiles, "")
+	if len(names) == 0 {
+		fmt.Println("No namespaces to prune")
+		return nil
 	}
 
-	for _, valuesFile := range valuesFiles {
-		if valuesFile != "" {
-			fmt.Printf("\nLinting chart with values file '%s'...\n\n", valuesFile)
+	var result error
+	for _, namespace := range names {
+		fmt.Printf("Pruning namespace '%s'...\n", namespace)
+		releases, err := t.kubectl.GetReleases(namespace)
+		if err != nil {
+			result = multierror.Append(result, errors.Wrapf(err, "Error listing releases in namespace '%s'", namespace))
+			continue
 		}
-		if err := t.helm.LintWithValues(chart.Path(), valuesFile); err != nil {
-			result.Error = err
-			break
+		for _, release := range releases {
+			t.helm.DeleteRelease(namespace, release)
 		}
+		t.kubectl.DeleteNamespace(namespace)
 	}
-
 	return result
 }
 
-// InstallChart installs the specified chart into a new namespace, waits for resources to become ready, and eventually
-// uninstalls it and deletes the namespace again.
-func (t *Testing) InstallChart(chart *Chart) TestResult {
-	var result TestResult
+// MatrixEntry is an entry of a matrix file, specifying a chart to install and test, and how to install it.
+type MatrixEntry struct {
+	Chart       string   `yaml:"chart"`
+	ValuesFiles []string `yaml:"valuesFiles"`
+	Namespace   string   `yaml:"namespace"`
+	HelmArgs    string   `yaml:"helmArgs"`
+}
 
-	if t.config.Upgrade {
-		// Test upgrade from previous version
-		result = t.UpgradeChart(chart)
-		if result.Error != nil {
-			return result
-		}
-		// Test upgrade of current version (related: https://github.com/helm/chart-testing/issues/19)
-		if err := t.doUpgrade(chart, chart, true); err != nil {
-			result.Error = err
-			return result
-		}
-	}
+// newMatrixEntryTesting creates the Testing for a matrix entry. It is a variable, so that it can be replaced in tests.
+var newMatrixEntryTesting = NewTesting
 
-	result = TestResult{Chart: chart}
-	if err := t.doInstall(chart); err != nil {
-		result.Error = err
+// ReadMatrix reads the entries of the matrix file, e.g. 'ct-matrix.yaml', which lists them under 'entries'.
+func ReadMatrix(matrixFile string) ([]MatrixEntry, error) {
+	content, err := ioutil.ReadFile(matrixFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error reading matrix file")
 	}
 
-	return result
+	var matrix struct {
+		Entries []MatrixEntry `yaml:"entries"`
+	}
+	if err := yaml.UnmarshalStrict(content, &matrix); err != nil {
+		return nil, errors.Wrap(err, "Error parsing matrix file")
+	}
+	if len(matrix.Entries) == 0 {
+		return nil, fmt.Errorf("Matrix file '%s' has no entries", matrixFile)
+	}
+	for i, entry := range matrix.Entries {
+		if entry.Chart == "" {
+			return nil, fmt.Errorf("Entry %d of matrix file '%s' does not specify a chart", i+1, matrixFile)
+		}
+	}
+	return matrix.Entries, nil
 }
 
-// UpgradeChart tests in-place upgrades of the specified chart relative to its previous revisions. If the
-// initial install or helm test of a previous revision of the chart fails, that release is ignored and no
-// error will be returned. If the latest revision of the chart introduces a potentially breaking change
-// according to the SemVer specification, upgrade testing will be skipped.
+// InstallMatrix installs and tests the entries of the configured matrix file in order, each like a chart specified
+// with '--charts', but installed with the entry's values files instead of the chart's CI values files, into the
+// entry's namespace, and with the entry's additional Helm arguments, if specified. Results are labeled with their
+// entry.
+func (t *Testing) InstallMatrix() ([]TestResult, error) {
+	entries, err := ReadMatrix(t.config.Matrix)
+	if err != nil {
+		return nil, err
+	}
+	t.ensureBuildID()
+
+	var results []TestResult
+	overallSuccess := true
+	for i, entry := range entries {
+		label := fmt.Sprintf("matrix entry %d", i+1)
+		fmt.Printf("Testing %s, chart '%s'...\n", label, entry.Chart)
+
+		cfg := t.config
+		cfg.Matrix = ""
+		cfg.Charts = []string{entry.Chart}
+		cfg.ProcessAllCharts = false
+		if entry.Namespace != "" {
+			cfg.Namespace = entry.Namespace
+		}
+		if entry.HelmArgs != "" {
+			cfg.HelmExtraArgs = strings.TrimSpace(cfg.HelmExtraArgs + " " + entry.HelmArgs)
+		}
+		entryTesting, err := newMatrixEntryTesting(cfg)
+		if err != nil {
+			return results, errors.Wrapf(err, "Error setting up testing for %s", label)
+		}
+		if entry.ValuesFiles != nil {
+			entryTesting.matrixValuesFiles = entry.ValuesFiles
+		}
+		entryTesting.Output = t.Output
+		if t.ResultCallback != nil {
+			entryTesting.ResultCallback = func(result TestResult) {
+				result.MatrixEntry = label
+				t.ResultCallback(result)
+			}
+		}
+
+		entryResults, err := entryTesting.InstallCharts()
+		if err != nil {
+			if entryResults == nil {
+				return results, err
+			}
+			overallSuccess = false
+		}
+		for _, result := range entryResults {
+			result.MatrixEntry = label
+			results = append(results, result)
+		}
+		if !overallSuccess && t.config.FailFast {
+			break
+		}
+	}
+
+	if overallSuccess {
+		return results, nil
+	}
+
+	return results, errors.New("Error processing charts")
+}
+
+// output returns the writer Testing prints to, which is stdout unless Output is set.
+func (t *Testing) output() io.Writer {
+	if t.Output == nil {
+		return os.Stdout
+	}
+	return t.Output
+}
+
+// infoOutput returns the writer for informational messages. When only listing charts, it is stderr, so that the list
+// printed to stdout can be piped.
+func (t *Testing) infoOutput() io.Writer {
+	if t.config.ListCharts {
+		return os.Stderr
+	}
+	return t.output()
+}
+
+// printDelimiterLine prints a delimiter line like util.PrintDelimiterLine, but to the output of Testing.
+func (t *Testing) printDelimiterLine(delimiterChar string) {
+	fmt.Fprintln(t.output(), util.DelimiterLine(delimiterChar))
+}
+
+// PrintResults writes test results to the output of Testing, stdout by default.
+func (t *Testing) PrintResults(results []TestResult) {
+	t.printDelimiterLine("-")
+	if results != nil {
+		if groups := t.groupResults(results); len(groups) > 1 {
+			for i, group := range groups {
+				if i > 0 {
+					t.printDelimiterLine("-")
+				}
+				fmt.Fprintf(t.output(), " %s:\n", group.name())
+				t.printResultLines(group.Results)
+				fmt.Fprintf(t.output(), " %s\n", group.Summary)
+			}
+		} else {
+			t.printResultLines(results)
+		}
+		t.printDelimiterLine("-")
+		fmt.Fprintf(t.output(), " %s\n", summarizeResults(results))
+	} else {
+		fmt.Fprintln(t.output(), "No chart changes detected.")
+	}
+	t.printDelimiterLine("-")
+}
+
+// printResultLines prints a line for each of the results.
+func (t *Testing) printResultLines(results []TestResult) {
+	for i, result := range results {
+		if result.KubeVersion != "" && (i == 0 || result.KubeVersion != results[i-1].KubeVersion) {
+			fmt.Fprintf(t.output(), " Kubernetes %s:\n", result.KubeVersion)
+		}
+		status := result.withStatus().Status
+		chart := result.Chart.String()
+		if result.MatrixEntry != "" {
+			chart = fmt.Sprintf("%s (%s)", chart, result.MatrixEntry)
+		}
+		switch status {
+		case StatusFailed:
+			fmt.Fprintf(t.output(), " %s %s > %s\n", t.resultSymbol(status), chart, result.Error)
+		case StatusSkipped:
+			fmt.Fprintf(t.output(), " %s %s > skipped: %s\n", t.resultSymbol(status), chart, result.SkipReason)
+		case StatusPassed:
+			if result.ExpectedError != nil {
+				fmt.Fprintf(t.output(), " %s %s > failed as expected: %s\n", t.resultSymbol(status), chart, result.ExpectedError)
+			} else {
+				fmt.Fprintf(t.output(), " %s %s\n", t.resultSymbol(status), chart)
+			}
+		default:
+			fmt.Fprintf(t.output(), " %s %s\n", t.resultSymbol(status), chart)
+		}
+	}
+}
+
+// ResultGroup holds the results of the charts within one of the configured chart directories. Results of charts
+// outside of them, e.g. remote charts, are grouped with an empty ChartDir.
+type ResultGroup struct {
+	ChartDir string        `json:"chartDir"`
+	Summary  ResultSummary `json:"summary"`
+	Results  []TestResult  `json:"results"`
+}
+
+func (g ResultGroup) name() string {
+	if g.ChartDir == "" {
+		return "Other charts"
+	}
+	return g.ChartDir
+}
+
+// groupResults groups the results by the configured chart directory each chart is in, in the order of the chart
+// directories, followed by the group of results of charts outside of them, if any.
+func (t *Testing) groupResults(results []TestResult) []ResultGroup {
+	groups := map[string]*ResultGroup{}
+	for _, result := range results {
+		chartDir := t.chartDirOf(result.Chart)
+		group, ok := groups[chartDir]
+		if !ok {
+			group = &ResultGroup{ChartDir: chartDir}
+			groups[chartDir] = group
+		}
+		group.Results = append(group.Results, result)
+	}
+
+	var grouped []ResultGroup
+	for _, chartDir := range append(t.config.ChartDirs, "") {
+		if group, ok := groups[chartDir]; ok {
+			group.Summary = summarizeResults(group.Results)
+			grouped = append(grouped, *group)
+			delete(groups, chartDir)
+		}
+	}
+	return grouped
+}
+
+// chartDirOf returns the configured chart directory the chart is in, the most specific one if they are nested, or an
+// empty string if it is in none of them.
+func (t *Testing) chartDirOf(chart *Chart) string {
+	if chart == nil {
+		return ""
+	}
+	path := util.NormalizePath(chart.Path())
+	var match string
+	for _, chartDir := range t.config.ChartDirs {
+		dir := util.NormalizePath(chartDir)
+		if (dir == "." || path == dir || strings.HasPrefix(path, dir+"/")) && len(chartDir) > len(match) {
+			match = chartDir
+		}
+	}
+	return match
+}
+
+// ResultsPayload is the JSON summary of the results posted to the webhook and written to the results file. Text is a
+// human-readable summary, so the payload can be posted to Slack incoming webhooks. Groups is only set if the results
+// span more than one chart directory.
+type ResultsPayload struct {
+	Text         string        `json:"text"`
+	Success      bool          `json:"success"`
+	Summary      ResultSummary `json:"summary"`
+	FailedCharts []string      `json:"failedCharts"`
+	Error        string        `json:"error,omitempty"`
+	Results      []TestResult  `json:"results"`
+	Groups       []ResultGroup `json:"groups,omitempty"`
+}
+
+// resultsPayload returns the JSON summary of the results, given the error processing charts returned, if any.
+func (t *Testing) resultsPayload(results []TestResult, err error) ResultsPayload {
+	payload := ResultsPayload{
+		Success:      err == nil,
+		Summary:      summarizeResults(results),
+		FailedCharts: []string{},
+		Results:      results,
+	}
+	if payload.Results == nil {
+		payload.Results = []TestResult{}
+	}
+	if groups := t.groupResults(results); len(groups) > 1 {
+		payload.Groups = groups
+	}
+	for _, result := range results {
+		if result.withStatus().Status == StatusFailed {
+			payload.FailedCharts = append(payload.FailedCharts, result.Chart.Yaml().Name)
+		}
+	}
+	if err != nil {
+		payload.Error = err.Error()
+		payload.Text = fmt.Sprintf("chart-testing failed. %s", payload.Summary)
+		if len(payload.FailedCharts) > 0 {
+			payload.Text += fmt.Sprintf(". Failed charts: %s", strings.Join(payload.FailedCharts, ", "))
+		}
+	} else {
+		payload.Text = fmt.Sprintf("chart-testing succeeded. %s", payload.Summary)
+	}
+	return payload
+}
+
+// NotifyWebhook posts a JSON summary of the results to the configured webhook, by default only if processing charts
+// failed, i.e. err is not nil. Failing to notify the webhook is only logged.
+func (t *Testing) NotifyWebhook(results []TestResult, err error) {
+	if t.config.NotifyWebhook == "" || (err == nil && !t.config.NotifyWebhookAlways) {
+		return
+	}
+
+	if err := postWebhook(t.config.NotifyWebhook, t.resultsPayload(results, err)); err != nil {
+		fmt.Println("Error notifying webhook:", err)
+	}
+}
+
+// WriteResultsFile writes the same JSON summary of the results as posted to the webhook to the configured results
+// file, if any, regardless of whether processing charts failed. The file can be passed to '--failed-charts-from' in
+// order to re-run the failed charts.
+func (t *Testing) WriteResultsFile(results []TestResult, err error) error {
+	if t.config.ResultsFile == "" {
+		return nil
+	}
+
+	content, marshalErr := json.MarshalIndent(t.resultsPayload(results, err), "", "  ")
+	if marshalErr != nil {
+		return errors.Wrap(marshalErr, "Error marshaling results")
+	}
+	if writeErr := ioutil.WriteFile(t.config.ResultsFile, append(content, '\n'), 0644); writeErr != nil {
+		return errors.Wrapf(writeErr, "Error writing results file '%s'", t.config.ResultsFile)
+	}
+	return nil
+}
+
+// postWebhook posts the payload as JSON to the webhook.
+func postWebhook(url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrap(err, "Error marshaling payload")
+	}
+
+	client := http.Client{Timeout: webhookTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with '%s'", resp.Status)
+	}
+	return nil
+}
+
+// ResultSummary holds the number of passed, failed, and skipped charts and their total.
+type ResultSummary struct {
+	Passed  int `json:"passed"`
+	Failed  int `json:"failed"`
+	Skipped int `json:"skipped"`
+	Total   int `json:"total"`
+}
+
+// String returns the summary as a single line.
+func (s ResultSummary) String() string {
+	return fmt.Sprintf("Passed: %d, Failed: %d, Skipped: %d (total %d)", s.Passed, s.Failed, s.Skipped, s.Total)
+}
+
+// summarizeResults counts the passed, failed, and skipped charts.
+func summarizeResults(results []TestResult) ResultSummary {
+	summary := ResultSummary{Total: len(results)}
+	for _, result := range results {
+		switch result.withStatus().Status {
+		case StatusPassed:
+			summary.Passed++
+		case StatusFailed:
+			summary.Failed++
+		case StatusSkipped:
+			summary.Skipped++
+		}
+	}
+	return summary
+}
+
+// PrintChartsToBeProcessed writes the charts to be processed to stdout without processing them, either one chart
+// path per line or, if configured, as a JSON array.
+func (t *Testing) PrintChartsToBeProcessed() error {
+	chartDirs, err := t.FindChartDirsToBeProcessed()
+	if err != nil {
+		return errors.Wrap(err, "Error identifying charts to process")
+	}
+
+	if t.config.ListChartsFormat != "json" {
+		for _, dir := range chartDirs {
+			fmt.Printf("%s%s\n", dir, t.ExplainChanges(dir))
+		}
+		return nil
+	}
+
+	type chartListEntry struct {
+		Name         string   `json:"name"`
+		Version      string   `json:"version"`
+		Path         string   `json:"path"`
+		ChangedFiles []string `json:"changedFiles,omitempty"`
+	}
+	entries := []chartListEntry{}
+	for _, dir := range chartDirs {
+		chart, err := t.loadChart(dir)
+		if err != nil {
+			return err
+		}
+		var changedFiles []string
+		if t.config.ExplainChanges {
+			changedFiles = t.ChangedFiles(dir)
+		}
+		entries = append(entries, chartListEntry{chart.Yaml().Name, chart.Yaml().Version, chart.Path(), changedFiles})
+	}
+
+	output, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "Error marshaling chart list")
+	}
+	fmt.Println(string(output))
+	return nil
+}
+
+// resultSymbol returns the symbol PrintResults uses for a passed, failed, or skipped chart. If configured, ASCII
+// symbols are used for log systems which mangle UTF-8.
+func (t *Testing) resultSymbol(status Status) string {
+	switch {
+	case t.config.ASCIIResults && status == StatusPassed:
+		return "[PASS]"
+	case t.config.ASCIIResults && status == StatusSkipped:
+		return "[SKIP]"
+	case t.config.ASCIIResults:
+		return "[FAIL]"
+	case status == StatusPassed:
+		return "✔︎"
+	case status == StatusSkipped:
+		return "➖"
+	default:
+		return "✖︎"
+	}
+}
+
+// chartYamlSchema returns the schema for validating the chart's Chart.yaml, which is the schema configured for the
+// chart's apiVersion, if any, or the default schema otherwise.
+func (t *Testing) chartYamlSchema(chart *Chart) string {
+	if schema, ok := t.config.ChartYamlSchemas[chart.Yaml().ApiVersion]; ok {
+		return schema
+	}
+	return t.config.ChartYamlSchema
+}
+
+// lintConf returns the yamllint config for the file. If lint configs are specified per file name pattern, the config
+// of the longest pattern matching the file name is used. Otherwise, or if no pattern matches, the default applies.
+func (t *Testing) lintConf(yamlFile string) string {
+	lintConf := t.config.LintConf
+	longestPattern := ""
+	for pattern, conf := range t.config.LintConfs {
+		if matched, _ := filepath.Match(pattern, filepath.Base(yamlFile)); !matched {
+			continue
+		}
+		if len(pattern) > len(longestPattern) || (len(pattern) == len(longestPattern) && pattern < longestPattern) {
+			lintConf = conf
+			longestPattern = pattern
+		}
+	}
+	return lintConf
+}
+
+// valuesFilesForCI returns the chart's CI values files in the order they are used. By default, this is lexical order.
+// If numeric ordering is configured, files with a numeric prefix (e.g. '00-base-values.yaml', '10-override-values.yaml')
+// come first, ordered by the prefix's numeric value, followed by all other files in lexical order. Downloaded remote
+// values files are used after the chart's own values files, in the configured order.
+func (t *Testing) valuesFilesForCI(chart *Chart) []string {
+	externalValuesFiles := t.externalValuesFiles(chart)
+	var valuesFiles []string
+	for _, valuesFile := range chart.ValuesFilePathsForCI() {
+		if !containsFileName(externalValuesFiles, valuesFile) {
+			valuesFiles = append(valuesFiles, valuesFile)
+		}
+	}
+	if len(externalValuesFiles) > 0 {
+		valuesFiles = append(valuesFiles, externalValuesFiles...)
+		sort.SliceStable(valuesFiles, func(i, j int) bool {
+			return filepath.Base(valuesFiles[i]) < filepath.Base(valuesFiles[j])
+		})
+	}
+	if t.config.ValuesFilesNumericOrder {
+		sort.SliceStable(valuesFiles, func(i, j int) bool {
+			left, leftOk := numericPrefix(valuesFiles[i])
+			right, rightOk := numericPrefix(valuesFiles[j])
+			if leftOk && rightOk {
+				return left < right
+			}
+			return leftOk && !rightOk
+		})
+	}
+
+	return append(valuesFiles, t.remoteValuesFiles...)
+}
+
+// externalValuesFiles returns the CI values files of the chart maintained outside of it, i.e. the files in the
+// directory named like the chart's directory within '--values-base-dir' matching the same patterns as those in the
+// chart's 'ci' directory, in lexical order.
+func (t *Testing) externalValuesFiles(chart *Chart) []string {
+	if t.config.ValuesBaseDir == "" {
+		return nil
+	}
+
+	name := filepath.Base(chart.Path())
+	if name == "." || name == "/" {
+		name = chart.Yaml().Name
+	}
+	dir := filepath.Join(t.config.ValuesBaseDir, name)
+	matches, _ := filepath.Glob(filepath.Join(dir, "*-values.yaml"))
+	templateMatches, _ := filepath.Glob(filepath.Join(dir, "*-values.yaml"+valuesTemplateSuffix))
+	matches = append(matches, templateMatches...)
+	sort.Strings(matches)
+	return matches
+}
+
+// containsFileName checks whether files contain a file with the same name as file, regardless of its directory.
+func containsFileName(files []string, file string) bool {
+	for _, f := range files {
+		if filepath.Base(f) == filepath.Base(file) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyCRDs applies the CRDs in the chart's 'crds' directory and waits for them to become established, so that
+// resources of the chart referencing them can be installed. Like Helm, it does not delete CRDs again.
+func (t *Testing) applyCRDs(chart *Chart) error {
+	var crdFiles []string
+	for _, pattern := range []string{"*.yaml", "*.yml", "*.json"} {
+		files, err := filepath.Glob(filepath.Join(chart.Path(), "crds", pattern))
+		if err != nil {
+			return errors.Wrap(err, "Error finding CRDs")
+		}
+		crdFiles = append(crdFiles, files...)
+	}
+	if len(crdFiles) == 0 {
+		return nil
+	}
+
+	fmt.Printf("Applying CRDs of chart '%s'...\n", chart)
+	if err := t.kubectl.ApplyManifests(crdFiles); err != nil {
+		return errors.Wrapf(err, "Error applying CRDs of chart '%s'", chart)
+	}
+	if err := t.kubectl.WaitForCondition("Established", crdFiles); err != nil {
+		return errors.Wrapf(err, "CRDs of chart '%s' did not become established", chart)
+	}
+	return nil
+}
+
+// installValuesFilesForCI returns the values files to install the chart with. If only changed values files are to be
+// installed and nothing but CI values files of the chart changed, only those are returned, with changed files
+// overridden by a file of the same name in '--values-base-dir' replaced by the latter. Otherwise, all values files are
+// returned.
+func (t *Testing) installValuesFilesForCI(chart *Chart) []string {
+	if t.matrixValuesFiles != nil {
+		return t.matrixValuesFiles
+	}
+	valuesFiles := t.valuesFilesForCI(chart)
+	changedFiles := t.changedChartFiles[util.NormalizePath(chart.Path())]
+	if !t.config.OnlyChangedValuesFiles || len(changedFiles) == 0 {
+		return valuesFiles
+	}
+
+	var ciValuesFiles []string
+	for _, valuesFile := range chart.ValuesFilePathsForCI() {
+		ciValuesFiles = append(ciValuesFiles, util.NormalizePath(valuesFile))
+	}
+	for _, file := range changedFiles {
+		if !util.StringSliceContains(ciValuesFiles, file) {
+			return valuesFiles
+		}
+	}
+
+	externalFiles := t.externalValuesFiles(chart)
+	var changedValuesFiles []string
+	for _, valuesFile := range valuesFiles {
+		changed := util.StringSliceContains(changedFiles, util.NormalizePath(valuesFile))
+		overridden := util.StringSliceContains(externalFiles, valuesFile) && containsFileName(changedFiles, valuesFile)
+		if changed || overridden {
+			changedValuesFiles = append(changedValuesFiles, valuesFile)
+		}
+	}
+	fmt.Printf("Only CI values files of chart '%s' changed. Installing with changed values files only.\n", chart)
+	return changedValuesFiles
+}
+
+// pullRemoteCharts pulls the configured remote charts, formatted as 'repo/chart[:version]', into a temporary directory
+// and returns them along with a function removing the directory.
+func (t *Testing) pullRemoteCharts() ([]*Chart, func(), error) {
+	dir, err := ioutil.TempDir("", "ct_remote_charts")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		if err := os.RemoveAll(dir); err != nil {
+			fmt.Printf("Error removing remote charts directory '%s': %s\n", dir, err)
+		}
+	}
+
+	var charts []*Chart
+	for i, remoteChart := range t.config.RemoteCharts {
+		chartRef, version := util.SplitRemoteChart(remoteChart)
+		// Pull each chart into its own directory because charts from different repositories may have the same name
+		destination := filepath.Join(dir, strconv.Itoa(i))
+		if err := t.helm.Pull(chartRef, version, destination); err != nil {
+			cleanup()
+			return nil, nil, errors.Wrapf(err, "Error pulling chart '%s'", remoteChart)
+		}
+		chart, err := NewChart(filepath.Join(destination, path.Base(chartRef)))
+		if err != nil {
+			cleanup()
+			return nil, nil, errors.Wrapf(err, "Error reading pulled chart '%s'", remoteChart)
+		}
+		charts = append(charts, chart)
+	}
+	return charts, cleanup, nil
+}
+
+// buildPreviousRevisionDependencies builds the dependencies of the previous revisions of the charts, running as many
+// builds in parallel as configured. Errors are only printed because the previous revision of a chart may not even
+// exist.
+func (t *Testing) buildPreviousRevisionDependencies(charts []*Chart) {
+	parallelism := t.config.DependencyBuildParallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, parallelism)
+	for _, chart := range charts {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(chart *Chart) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			if err := t.helm.BuildDependencies(t.computePreviousRevisionPath(chart.Path())); err != nil {
+				// Only print error (don't exit) if building dependencies for previous revision fails.
+				fmt.Println(errors.Wrapf(err, "Error building dependencies for previous revision of chart '%s'\n", chart))
+			}
+		}(chart)
+	}
+	wg.Wait()
+}
+
+// valuesTemplateContext is the data values templates are rendered with.
+type valuesTemplateContext struct {
+	BuildId   string
+	Namespace string
+	Release   string
+	Token     string
+}
+
+// renderValuesFile renders valuesFile into a temporary file if it is a values template, and returns the path of the
+// rendered file along with a function removing it again. Other values files are returned unchanged.
+func (t *Testing) renderValuesFile(valuesFile string, namespace string, release string) (string, func(), error) {
+	if !strings.HasSuffix(valuesFile, valuesTemplateSuffix) {
+		return valuesFile, func() {}, nil
+	}
+
+	tpl, err := template.New(filepath.Base(valuesFile)).Option("missingkey=error").ParseFiles(valuesFile)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "Error parsing values template '%s'", valuesFile)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(valuesFile), "-values.yaml"+valuesTemplateSuffix)
+	file, err := ioutil.TempFile("", name+"-*-values.yaml")
+	if err != nil {
+		return "", nil, errors.Wrap(err, "Could not create file for rendered values template")
+	}
+	remove := func() {
+		os.Remove(file.Name())
+	}
+
+	context := valuesTemplateContext{
+		BuildId:   t.config.BuildId,
+		Namespace: namespace,
+		Release:   release,
+		Token:     util.RandomString(10),
+	}
+	err = tpl.Execute(file, context)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		remove()
+		return "", nil, errors.Wrapf(err, "Error rendering values template '%s'", valuesFile)
+	}
+
+	fmt.Printf("Rendered values template '%s' to '%s'\n", valuesFile, file.Name())
+	return file.Name(), remove, nil
+}
+
+// downloadRemoteValuesFiles downloads the configured remote values files to a temporary directory. The returned
+// function removes the directory again.
+func (t *Testing) downloadRemoteValuesFiles() (func(), error) {
+	dir, err := ioutil.TempDir("", "ct_remote_values")
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not create directory for remote values files")
+	}
+	cleanup := func() {
+		t.remoteValuesFiles = nil
+		os.RemoveAll(dir)
+	}
+
+	for i, url := range t.config.RemoteValuesFiles {
+		file := filepath.Join(dir, fmt.Sprintf("%02d-%s", i, path.Base(url)))
+		if err := downloadValuesFile(url, file); err != nil {
+			cleanup()
+			return nil, err
+		}
+		fmt.Printf("Downloaded values file '%s' to '%s'\n", url, file)
+		t.remoteValuesFiles = append(t.remoteValuesFiles, file)
+	}
+
+	return cleanup, nil
+}
+
+// downloadValuesFile downloads the values file at url and writes it to file, making sure it is valid YAML.
+func downloadValuesFile(url string, file string) error {
+	client := http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return errors.Wrapf(err, "Error downloading values file '%s'", url)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Error downloading values file '%s': %s", url, resp.Status)
+	}
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrapf(err, "Error downloading values file '%s'", url)
+	}
+
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(content, &values); err != nil {
+		return errors.Wrapf(err, "Values file '%s' is not valid YAML", url)
+	}
+
+	return ioutil.WriteFile(file, content, 0644)
+}
+
+// numericPrefix returns the numeric value of the digits the file's base name starts with, and whether there are any.
+func numericPrefix(file string) (int, bool) {
+	name := filepath.Base(file)
+	end := strings.IndexFunc(name, func(r rune) bool { return r < '0' || r > '9' })
+	if end == 0 {
+		return 0, false
+	}
+	if end < 0 {
+		end = len(name)
+	}
+	prefix, err := strconv.Atoi(name[:end])
+	return prefix, err == nil
+}
+
+// LintChart lints the specified chart. For charts annotated with 'ct.helm.sh/expect-lint-failure: "true"', the result
+// is inverted, i.e. lint errors are expected and linting successfully is an error.
+func (t *Testing) LintChart(chart *Chart) TestResult {
+	result := t.lintChart(chart)
+	if chart.Yaml().Annotations[expectLintFailureAnnotation] != "true" {
+		return result
+	}
+
+	var lintError *LintError
+	if result.Error == nil {
+		fmt.Printf("Chart '%s' is expected to fail linting, but linted successfully\n", chart)
+		result.Error = &LintError{Err: fmt.Errorf("chart linted successfully, but is expected to fail linting due to the '%s' annotation", expectLintFailureAnnotation)}
+	} else if errors.As(result.Error, &lintError) {
+		fmt.Printf("Chart '%s' failed linting as expected\n", chart)
+		result.ExpectedError, result.Error = result.Error, nil
+	}
+	return result
+}
+
+func (t *Testing) lintChart(chart *Chart) TestResult {
+	fmt.Printf("Linting chart '%s'\n", chart)
+
+	result := TestResult{Chart: chart}
+
+	if t.config.CheckVersionIncrement {
+		if err := t.CheckVersionIncrement(chart); err != nil {
+			result.Error = &VersionError{Err: err}
+			return result
+		}
+	}
+
+	if t.config.CheckAppVersionIncrement {
+		if err := t.CheckAppVersionIncrement(chart); err != nil {
+			result.Error = &VersionError{Err: err}
+			return result
+		}
+	}
+
+	chartYaml := filepath.Join(chart.Path(), "Chart.yaml")
+	valuesYaml := filepath.Join(chart.Path(), "values.yaml")
+	valuesFiles := t.valuesFilesForCI(chart)
+
+	release, namespace := chart.CreateInstallParams(t.config.BuildId)
+	for i, valuesFile := range valuesFiles {
+		renderedValuesFile, removeRenderedValuesFile, err := t.renderValuesFile(valuesFile, namespace, release)
+		if err != nil {
+			result.Error = &LintError{Err: err}
+			return result
+		}
+		defer removeRenderedValuesFile()
+		valuesFiles[i] = renderedValuesFile
+	}
+
+	if t.config.ValidateChartSchema {
+		if err := t.linter.Yamale(chartYaml, t.chartYamlSchema(chart)); err != nil {
+			result.Error = &LintError{Err: err}
+			return result
+		}
+	}
+
+	if t.config.ValidateChartYaml {
+		if err := t.ValidateChartYaml(chart); err != nil {
+			result.Error = &LintError{Err: err}
+			return result
+		}
+	}
+
+	if len(t.config.RequiredAnnotations) > 0 {
+		if err := t.ValidateRequiredAnnotations(chart); err != nil {
+			result.Error = &LintError{Err: err}
+			return result
+		}
+	}
+
+	if t.config.ValidateDependencyVersions {
+		if err := t.ValidateDependencyVersions(chart); err != nil {
+			result.Error = &LintError{Err: err}
+			return result
+		}
+	}
+
+	if t.config.RequirePinnedDependencies {
+		if err := t.ValidatePinnedDependencies(chart); err != nil {
+			result.Error = &LintError{Err: err}
+			return result
+		}
+	}
+
+	if t.config.ValidateCIValuesKeys {
+		if err := t.ValidateCIValuesKeys(chart, valuesFiles); err != nil {
+			result.Error = &LintError{Err: err}
+			return result
+		}
+	}
+
+	if t.config.ValidateDefaultsAgainstSchema {
+		if err := t.ValidateDefaultsAgainstSchema(chart); err != nil {
+			result.Error = &LintError{Err: err}
+			return result
+		}
+	}
+
+	if t.config.ValidateYaml {
+		yamlFiles := append([]string{chartYaml, valuesYaml}, valuesFiles...)
+		if t.config.YamlLintAllFiles {
+			allYamlFiles, err := findYamlFiles(chart.Path(), t.config.YamlLintTemplates)
+			if err != nil {
+				result.Error = &LintError{Err: err}
+				return result
+			}
+			for _, yamlFile := range allYamlFiles {
+				if !util.StringSliceContains(yamlFiles, yamlFile) {
+					yamlFiles = append(yamlFiles, yamlFile)
+				}
+			}
+		}
+		for _, yamlFile := range yamlFiles {
+			if err := t.linter.YamlLint(yamlFile, t.lintConf(yamlFile)); err != nil {
+				result.Error = &LintError{Err: err}
+				return result
+			}
+		}
+	}
+
+	if t.config.ValidateMaintainers {
+		if err := t.ValidateMaintainers(chart); err != nil {
+			result.Error = &LintError{Err: err}
+			return result
+		}
+	}
+
+	if t.config.ValidateDeprecation {
+		if err := t.ValidateDeprecation(chart); err != nil {
+			result.Error = &LintError{Err: err}
+			return result
+		}
+	}
+
+	if len(t.config.AllowedImageRegistries) > 0 {
+		if err := t.ValidateImageRegistries(chart, valuesFiles); err != nil {
+			result.Error = &LintError{Err: err}
+			return result
+		}
+	}
+
+	if t.config.MaxRenderedResources > 0 || t.config.MaxManifestBytes > 0 {
+		if err := t.ValidateManifestLimits(chart, valuesFiles); err != nil {
+			result.Error = &LintError{Err: err}
+			return result
+		}
+	}
+
+	if len(t.config.ConftestPolicies) > 0 {
+		if err := t.ValidateConftestPolicies(chart, valuesFiles); err != nil {
+			result.Error = &LintError{Err: err}
+			return result
+		}
+	}
+
+	// Lint with defaults if no values files are specified.
+	if len(valuesFiles) == 0 {
+		valuesFiles = append(valuesFiles, "")
+	}
+
+	for _, valuesFile := range valuesFiles {
+		if valuesFile != "" {
+			fmt.Printf("\nLinting chart with values file '%s'...\n\n", valuesFile)
+		}
+		findings, err := t.lintWithValues(chart, valuesFile)
+		result.LintFindings = append(result.LintFindings, findings...)
+		if err != nil {
+			result.Error = &LintError{Err: err}
+			return result
+		}
+	}
+
+	if t.config.RunUnitTests {
+		fmt.Printf("\nRunning unit tests for chart '%s'...\n\n", chart)
+		if err := t.helm.UnitTest(chart.Path()); err != nil {
+			result.Error = &LintError{Err: err}
+			return result
+		}
+	}
+
+	for _, plugin := range t.config.LintPlugins {
+		fmt.Printf("\nRunning Helm plugin '%s'...\n\n", plugin)
+		if err := t.helm.RunPlugin(plugin, chart.Path()); err != nil {
+			result.Error = &LintError{Err: errors.Wrapf(err, "Helm plugin '%s' failed", plugin)}
+			return result
+		}
+	}
+
+	return result
+}
+
+// lintWithValues runs `helm lint` for the chart with the specified values file and returns the findings it reported.
+// In quiet mode, the output of `helm lint` is only printed if linting fails.
+func (t *Testing) lintWithValues(chart *Chart, valuesFile string) ([]LintFinding, error) {
+	output, err := t.helm.LintWithValuesAndCaptureOutput(chart.Path(), valuesFile)
+	if err == nil && t.config.LintInfoAsError {
+		if infos := lintInfoMessages(output); len(infos) > 0 {
+			err = fmt.Errorf("'helm lint' reported INFO recommendations, which are treated as errors: %s", strings.Join(infos, "; "))
+		}
+	}
+	if err != nil || !t.config.QuietLint {
+		fmt.Println(output)
+	}
+
+	findings := lintFindings(output)
+	for i := range findings {
+		findings[i].ValuesFile = valuesFile
+	}
+	return findings, err
+}
+
+var lintFindingPattern = regexp.MustCompile(`^\[(INFO|WARNING|ERROR)\]\s+(.*)$`)
+
+// lintFindings parses the findings in the output of 'helm lint', which are formatted as '[SEVERITY] path: message'.
+func lintFindings(output string) []LintFinding {
+	var findings []LintFinding
+	for _, line := range strings.Split(output, "\n") {
+		match := lintFindingPattern.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+
+		finding := LintFinding{Severity: match[1], Message: match[2]}
+		if parts := strings.SplitN(match[2], ": ", 2); len(parts) == 2 && !strings.ContainsAny(parts[0], " \t") {
+			finding.Path, finding.Message = parts[0], parts[1]
+		}
+		finding.Code = lintRuleCode(finding.Path)
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+// lintRuleCode returns the code of the 'helm lint' rule reporting findings for the specified path.
+func lintRuleCode(path string) string {
+	switch {
+	case path == "Chart.yaml":
+		return "chartfile"
+	case path == "values.yaml" || path == "values.schema.json":
+		return "values"
+	case strings.HasPrefix(path, "templates/"):
+		return "templates"
+	default:
+		return "other"
+	}
+}
+
+// lintInfoMessages returns the '[INFO]' recommendations in the output of 'helm lint'.
+func lintInfoMessages(output string) []string {
+	var infos []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, "[INFO]") {
+			infos = append(infos, strings.TrimSpace(strings.TrimPrefix(line, "[INFO]")))
+		}
+	}
+	return infos
+}
+
+// findYamlFiles returns all '.yaml' and '.yml' files in the chart directory. Vendored dependencies in the 'charts'
+// directory are skipped, and so are templates unless includeTemplates is true, because they are Go templates rather
+// than plain YAML.
+func findYamlFiles(chartPath string, includeTemplates bool) ([]string, error) {
+	var yamlFiles []string
+	err := filepath.Walk(chartPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != chartPath && (info.Name() == "charts" || (info.Name() == "templates" && !includeTemplates)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := filepath.Ext(path); ext == ".yaml" || ext == ".yml" {
+			yamlFiles = append(yamlFiles, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Error finding YAML files")
+	}
+	return yamlFiles, nil
+}
+
+// InstallChart installs the specified chart into a new namespace, waits for resources to become ready, and eventually
+// uninstalls it and deletes the namespace again.
+func (t *Testing) InstallChart(chart *Chart) TestResult {
+	var result TestResult
+
+	if t.config.TestExistingRelease != "" {
+		return t.testExistingRelease(chart)
+	}
+
+	if chart.Yaml().Type == "library" {
+		fmt.Printf("Skipping install of chart '%s' because library charts are not installable\n", chart)
+		return skippedResult(chart, "library charts are not installable")
+	}
+
+	if t.rendersNothing(chart) {
+		fmt.Printf("Skipping install of chart '%s' because it renders no resources\n", chart)
+		return skippedResult(chart, "nothing to install, the chart renders no resources")
+	}
+
+	if t.config.Upgrade {
+		// Test upgrade from previous version
+		result = t.UpgradeChart(chart)
+		if result.Error != nil {
+			return result
+		}
+		// Test upgrade of current version (related: https://github.com/helm/chart-testing/issues/19)
+		if err := t.doUpgrade(chart, chart, true); err != nil {
+			result.Error = &UpgradeError{Err: err}
+			return result
+		}
+		if t.config.UpgradeOnly {
+			fmt.Printf("Skipping fresh install of chart '%s' because only upgrades are tested\n", chart)
+			return result
+		}
+	}
+
+	result = TestResult{Chart: chart}
+	if err := t.doInstall(chart); err != nil {
+		result.Error = &InstallError{Err: err}
+	}
+
+	return result
+}
+
+// rendersNothing returns true if the chart renders no resources, neither with its default values nor with any of its
+// CI values files, e.g. charts without templates or umbrella charts whose dependencies render nothing. Charts with
+// templates of their own are not rendered and assumed to render resources. If rendering fails or the chart has values
+// templates, which are only rendered on install, it returns false.
+func (t *Testing) rendersNothing(chart *Chart) bool {
+	if hasTemplates(chart) {
+		return false
+	}
+	for _, valuesFile := range append([]string{""}, t.valuesFilesForCI(chart)...) {
+		if strings.HasSuffix(valuesFile, ".tpl") {
+			return false
+		}
+		manifests, err := t.helm.TemplateWithValues(chart.Path(), valuesFile)
+		if err != nil || countResources(manifests) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// hasTemplates checks whether the chart has templates of its own, i.e. files in its 'templates' directory other than
+// partials, whose names start with an underscore, and 'NOTES.txt'.
+func hasTemplates(chart *Chart) bool {
+	found := false
+	filepath.Walk(filepath.Join(chart.Path(), "templates"), func(path string, info os.FileInfo, err error) error {
+		if err != nil || found {
+			return nil
+		}
+		if !info.IsDir() && !strings.HasPrefix(info.Name(), "_") && info.Name() != "NOTES.txt" {
+			found = true
+		}
+		return nil
+	})
+	return found
+}
+
+// testExistingRelease runs the tests of the configured existing release of the specified chart without installing
+// or uninstalling anything.
+func (t *Testing) testExistingRelease(chart *Chart) TestResult {
+	result := TestResult{Chart: chart}
+
+	releaseSlice := strings.SplitN(t.config.TestExistingRelease, "/", 2)
+	namespace, release := releaseSlice[0], releaseSlice[1]
+	releaseSelector := t.releaseSelector(chart, release)
+	fmt.Printf("Testing existing release '%s' of chart '%s' in namespace '%s'...\n", release, chart, namespace)
+
+	var err error
+	defer t.printDebugInfoOnFailure(namespace, &err)
+	if err = t.testRelease(namespace, release, releaseSelector); err != nil {
+		result.Error = &InstallError{Err: err}
+	}
+	return result
+}
+
+// UpgradeChart tests in-place upgrades of the specified chart relative to its previous revisions. If the
+// initial install or helm test of a previous revision of the chart fails, that release is ignored and no
+// error will be returned. If the latest revision of the chart introduces a potentially breaking change
+// according to the configured version bump rule, upgrade testing will be skipped.
 func (t *Testing) UpgradeChart(chart *Chart) TestResult {
 	result := TestResult{Chart: chart}
 
@@ -507,69 +2087,238 @@ func (t *Testing) UpgradeChart(chart *Chart) TestResult {
 	if breakingChangeAllowed {
 		if err != nil {
 			fmt.Println(errors.Wrap(err, fmt.Sprintf("Skipping upgrade test of '%s' because", chart)))
+			return skippedResult(chart, err.Error())
 		}
 		return result
 	} else if err != nil {
 		fmt.Printf("Error comparing chart versions for '%s'\n", chart)
-		result.Error = err
+		result.Error = &VersionError{Err: err}
 		return result
 	}
 
-	if oldChart, err := NewChart(t.computePreviousRevisionPath(chart.Path())); err == nil {
-		result.Error = t.doUpgrade(oldChart, chart, false)
+	if oldChart, err := t.loadChart(t.computePreviousRevisionPath(chart.Path())); err == nil {
+		if err := t.doUpgrade(oldChart, chart, false); err != nil {
+			result.Error = &UpgradeError{Err: err}
+		}
 	}
 
 	return result
 }
 
 func (t *Testing) doInstall(chart *Chart) error {
+	if t.config.ValidateCRDs {
+		if err := t.applyCRDs(chart); err != nil {
+			return err
+		}
+	}
+
 	fmt.Printf("Installing chart '%s'...\n", chart)
-	valuesFiles := chart.ValuesFilePathsForCI()
+	valuesFiles := t.installValuesFilesForCI(chart)
 
 	// Test with defaults if no values files are specified.
 	if len(valuesFiles) == 0 {
 		valuesFiles = append(valuesFiles, "")
 	}
 
+	if t.config.ValuesFilesParallelism > 1 && len(valuesFiles) > 1 {
+		if t.fixedNamespace(chart) == "" {
+			return t.installValuesFilesInParallel(chart, valuesFiles)
+		}
+		fmt.Println("Chart is installed into a fixed namespace. Installing with values files sequentially...")
+	}
+
 	for _, valuesFile := range valuesFiles {
-		if valuesFile != "" {
-			fmt.Printf("\nInstalling chart with values file '%s'...\n\n", valuesFile)
+		if err := t.installWithValuesFile(chart, valuesFile); err != nil {
+			return err
 		}
+	}
 
-		// Use anonymous function. Otherwise deferred calls would pile up
-		// and be executed in reverse order after the loop.
-		fun := func() error {
-			namespace, release, releaseSelector, cleanup := t.generateInstallConfig(chart)
-			defer cleanup()
+	return nil
+}
 
-			if t.config.Namespace == "" {
-				if err := t.kubectl.CreateNamespace(namespace); err != nil {
-					return err
-				}
-			}
-			if err := t.helm.InstallWithValues(chart.Path(), valuesFile, namespace, release); err != nil {
-				return err
+// installValuesFilesInParallel installs and tests the chart with each of the values files into its own namespace,
+// running as many installs in parallel as configured. All installs are run, and their errors are aggregated.
+func (t *Testing) installValuesFilesInParallel(chart *Chart, valuesFiles []string) error {
+	var result error
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, t.config.ValuesFilesParallelism)
+	for _, valuesFile := range valuesFiles {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(valuesFile string) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			if err := t.installWithValuesFile(chart, valuesFile); err != nil {
+				mutex.Lock()
+				result = multierror.Append(result, errors.Wrapf(err, "Error installing chart with values file '%s'", valuesFile))
+				mutex.Unlock()
 			}
-			return t.testRelease(namespace, release, releaseSelector)
+		}(valuesFile)
+	}
+	wg.Wait()
+	return result
+}
+
+// installWithValuesFile installs and tests the chart with the values file in a newly generated release, cleaning up
+// afterwards. Pass a zero value for valuesFile in order to install the chart with its default values.
+func (t *Testing) installWithValuesFile(chart *Chart, valuesFile string) (err error) {
+	if valuesFile != "" {
+		fmt.Printf("\nInstalling chart with values file '%s'...\n\n", valuesFile)
+	}
+
+	namespace, release, releaseSelector, cleanup, err := t.generateInstallConfig(chart, t.fixedNamespace(chart))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cleanupErr := cleanup(err != nil); err == nil {
+			err = cleanupErr
 		}
+	}()
+	defer t.printDebugInfoOnFailure(namespace, &err)
 
-		if err := fun(); err != nil {
-			return err
+	renderedValuesFile, removeRenderedValuesFile, err := t.renderValuesFile(valuesFile, namespace, release)
+	if err != nil {
+		return err
+	}
+	defer removeRenderedValuesFile()
+	if err := t.runInstallHook(chart, "pre-install", namespace, release); err != nil {
+		return errors.Wrap(err, "pre-install script failed")
+	}
+	// Resources created by ct itself or the pre-install script are not deleted along with the release
+	var existingResources []string
+	if t.config.TestReinstall {
+		if existingResources, err = t.kubectl.ListNamespacedResources(namespace, releaseSelector); err != nil {
+			return errors.Wrapf(err, "Error listing resources in namespace '%s'", namespace)
+		}
+	}
+	if err := t.helm.InstallWithValues(chart.Path(), renderedValuesFile, namespace, release); err != nil {
+		return err
+	}
+	if err := t.testRelease(namespace, release, releaseSelector); err != nil {
+		return err
+	}
+	if err := t.checkResourceAssertions(valuesFile, namespace, release); err != nil {
+		return err
+	}
+	if err := t.runInstallHook(chart, "post-install", namespace, release); err != nil {
+		fmt.Println(errors.Wrap(err, "post-install script failed"))
+	}
+	if t.config.TestReinstall {
+		return t.testReinstall(chart, renderedValuesFile, namespace, release, releaseSelector, existingResources)
+	}
+	return nil
+}
+
+// ResourceAssertion is a resource expected to exist after installing a chart with a CI values file. Name may reference
+// the release, namespace, and build ID like values templates, e.g. '{{ .Release }}-ingress'.
+type ResourceAssertion struct {
+	Kind string `yaml:"kind"`
+	Name string `yaml:"name"`
+}
+
+// assertionsFile returns the path of the assertions file of the values file, e.g. 'ci/foo-assertions.yaml' for
+// 'ci/foo-values.yaml' or 'ci/foo-values.yaml.tpl', or an empty string if there is none.
+func assertionsFile(valuesFile string) string {
+	name := strings.TrimSuffix(valuesFile, valuesTemplateSuffix)
+	if !strings.HasSuffix(name, "-values.yaml") {
+		return ""
+	}
+	path := strings.TrimSuffix(name, "-values.yaml") + "-assertions.yaml"
+	if !util.FileExists(path) {
+		return ""
+	}
+	return path
+}
+
+// checkResourceAssertions checks that the resources listed under 'resources' in the assertions file of the values
+// file exist in the namespace the chart was installed into, if there is an assertions file.
+func (t *Testing) checkResourceAssertions(valuesFile string, namespace string, release string) error {
+	path := assertionsFile(valuesFile)
+	if path == "" {
+		return nil
+	}
+	fmt.Printf("Checking resources expected by '%s'...\n", path)
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "Error reading assertions file '%s'", path)
+	}
+	var assertions struct {
+		Resources []ResourceAssertion `yaml:"resources"`
+	}
+	if err := yaml.UnmarshalStrict(content, &assertions); err != nil {
+		return errors.Wrapf(err, "Error parsing assertions file '%s'", path)
+	}
+
+	context := valuesTemplateContext{
+		BuildId:   t.config.BuildId,
+		Namespace: namespace,
+		Release:   release,
+	}
+	var missing []string
+	for _, assertion := range assertions.Resources {
+		var name strings.Builder
+		tpl, err := template.New(path).Option("missingkey=error").Parse(assertion.Name)
+		if err == nil {
+			err = tpl.Execute(&name, context)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "Error rendering name of expected %s '%s'", assertion.Kind, assertion.Name)
+		}
+
+		resource := fmt.Sprintf("%s/%s", strings.ToLower(assertion.Kind), name.String())
+		exists, err := t.kubectl.CheckResourceExists(namespace, resource)
+		if err != nil {
+			return errors.Wrapf(err, "Error checking expected resource '%s'", resource)
+		}
+		if !exists {
+			missing = append(missing, resource)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("Expected resources missing after installing with values file '%s': %s", valuesFile, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// createNamespaceResources creates the image pull secret and the service account in the namespace a chart is installed
+// into, if configured.
+func (t *Testing) createNamespaceResources(namespace string) error {
+	if t.config.ImagePullSecret != "" {
+		secretSlice := strings.SplitN(t.config.ImagePullSecret, "=", 2)
+		if err := t.kubectl.CreateDockerSecret(namespace, secretSlice[0], secretSlice[1]); err != nil {
+			return errors.Wrapf(err, "Error creating image pull secret '%s'", secretSlice[0])
 		}
 	}
 
+	if serviceAccount := t.config.ServiceAccount; serviceAccount != "" {
+		if err := t.kubectl.CreateServiceAccount(namespace, serviceAccount); err != nil {
+			return errors.Wrapf(err, "Error creating service account '%s'", serviceAccount)
+		}
+		if clusterRole := t.config.ServiceAccountClusterRole; clusterRole != "" {
+			if err := t.kubectl.BindClusterRole(namespace, serviceAccount, clusterRole); err != nil {
+				return errors.Wrapf(err, "Error binding cluster role '%s' to service account '%s'", clusterRole, serviceAccount)
+			}
+		}
+	}
 	return nil
 }
 
 func (t *Testing) doUpgrade(oldChart, newChart *Chart, oldChartMustPass bool) error {
 	fmt.Printf("Testing upgrades of chart '%s' relative to previous revision '%s'...\n", newChart, oldChart)
-	valuesFiles := oldChart.ValuesFilePathsForCI()
+	valuesFiles := t.valuesFilesForCI(oldChart)
 	if len(valuesFiles) == 0 {
 		valuesFiles = append(valuesFiles, "")
 	}
 	for _, valuesFile := range valuesFiles {
 		if valuesFile != "" {
-			if t.config.SkipMissingValues && !newChart.HasCIValuesFile(valuesFile) {
+			isShared := util.StringSliceContains(t.remoteValuesFiles, valuesFile) || util.StringSliceContains(t.externalValuesFiles(newChart), valuesFile)
+			if t.config.SkipMissingValues && !isShared && !newChart.HasCIValuesFile(valuesFile) {
 				fmt.Printf("Upgrade testing for values file '%s' skipped because a corresponding values file was not found in %s/ci", valuesFile, newChart.Path())
 				continue
 			}
@@ -578,17 +2327,25 @@ func (t *Testing) doUpgrade(oldChart, newChart *Chart, oldChartMustPass bool) er
 
 		// Use anonymous function. Otherwise deferred calls would pile up
 		// and be executed in reverse order after the loop.
-		fun := func() error {
-			namespace, release, releaseSelector, cleanup := t.generateInstallConfig(oldChart)
-			defer cleanup()
-
-			if t.config.Namespace == "" {
-				if err := t.kubectl.CreateNamespace(namespace); err != nil {
-					return err
+		fun := func() (err error) {
+			namespace, release, releaseSelector, cleanup, err := t.generateInstallConfig(oldChart, t.fixedUpgradeNamespace(oldChart))
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if cleanupErr := cleanup(err != nil); err == nil {
+					err = cleanupErr
 				}
+			}()
+			defer t.printDebugInfoOnFailure(namespace, &err)
+
+			renderedValuesFile, removeRenderedValuesFile, err := t.renderValuesFile(valuesFile, namespace, release)
+			if err != nil {
+				return err
 			}
+			defer removeRenderedValuesFile()
 			// Install previous version of chart. If installation fails, ignore this release.
-			if err := t.helm.InstallWithValues(oldChart.Path(), valuesFile, namespace, release); err != nil {
+			if err := t.helm.InstallWithValues(oldChart.Path(), renderedValuesFile, namespace, release); err != nil {
 				if oldChartMustPass {
 					return err
 				}
@@ -603,56 +2360,228 @@ func (t *Testing) doUpgrade(oldChart, newChart *Chart, oldChartMustPass bool) er
 				return nil
 			}
 
-			if err := t.helm.Upgrade(oldChart.Path(), namespace, release); err != nil {
-				return err
-			}
+			if err := t.helm.Upgrade(oldChart.Path(), namespace, release); err != nil {
+				return err
+			}
+
+			return t.testRelease(namespace, release, releaseSelector)
+		}
+
+		if err := fun(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runInstallHook runs the chart's 'ci/<hook>.sh' script, if present. The namespace, release name, and chart
+// directory are exported to the script as NAMESPACE, RELEASE, and CHART_DIR.
+func (t *Testing) runInstallHook(chart *Chart, hook string, namespace string, release string) error {
+	script := filepath.Join(chart.Path(), "ci", hook+".sh")
+	if !util.FileExists(script) {
+		return nil
+	}
+
+	fmt.Printf("Running %s script '%s'...\n", hook, script)
+	env := []string{
+		fmt.Sprintf("NAMESPACE=%s", namespace),
+		fmt.Sprintf("RELEASE=%s", release),
+		fmt.Sprintf("CHART_DIR=%s", chart.Path()),
+	}
+	return t.scriptRunner.Run(script, env)
+}
+
+// testReinstall uninstalls the release, waits for its resources to be deleted, and installs and tests it again in
+// order to catch leftovers (e.g. PVCs, finalizers, or CRDs) which block reinstalling the chart. The existing resources,
+// which were in the namespace before the release was installed, are not waited for.
+func (t *Testing) testReinstall(chart *Chart, valuesFile, namespace, release, releaseSelector string, existingResources []string) error {
+	fmt.Printf("Testing reinstall of chart '%s'...\n", chart)
+	t.helm.DeleteRelease(namespace, release)
+	t.deleteClusterResources(chart, release)
+	if err := t.kubectl.WaitForResourcesDeleted(namespace, releaseSelector, existingResources); err != nil {
+		return errors.Wrap(err, "reinstall failed")
+	}
+	if err := t.helm.InstallWithValues(chart.Path(), valuesFile, namespace, release); err != nil {
+		return errors.Wrap(err, "reinstall failed")
+	}
+	if err := t.testRelease(namespace, release, releaseSelector); err != nil {
+		return errors.Wrap(err, "reinstall failed")
+	}
+	return nil
+}
 
-			return t.testRelease(namespace, release, releaseSelector)
-		}
+func (t *Testing) testRelease(namespace, release, releaseSelector string) error {
+	if releaseSelector != "" {
+		t.warnIfNoPodsMatch(namespace, releaseSelector)
+	}
 
-		if err := fun(); err != nil {
+	// Helm has already waited for all resources of the release to become ready
+	if !t.config.HelmWait {
+		if err := t.kubectl.WaitForDeployments(namespace, releaseSelector); err != nil {
+			return err
+		}
+	}
+	// Helm does not wait for autoscaling to settle, though
+	if t.config.WaitForAutoscaling {
+		if err := t.kubectl.WaitForAutoscaling(namespace, releaseSelector); err != nil {
 			return err
 		}
 	}
+	return t.runHelmTest(namespace, release)
+}
 
-	return nil
+// runHelmTest runs the tests of the release. Failed tests are retried as often as configured, waiting a little longer
+// before each retry, in order to cope with flaky tests.
+func (t *Testing) runHelmTest(namespace, release string) error {
+	var err error
+	for attempt := 0; attempt <= t.config.TestRetries; attempt++ {
+		if attempt > 0 {
+			backoff := time.Duration(attempt) * testRetryBackoff
+			fmt.Printf("Tests of release '%s' failed: %s. Retrying in %s (%d/%d)...\n", release, err, backoff, attempt, t.config.TestRetries)
+			time.Sleep(backoff)
+		}
+		if err = t.helm.Test(namespace, release, t.config.HelmTestFilter); err == nil {
+			return nil
+		}
+	}
+	return err
 }
 
-func (t *Testing) testRelease(namespace, release, releaseSelector string) error {
-	if err := t.kubectl.WaitForDeployments(namespace, releaseSelector); err != nil {
-		return err
+// fixedNamespace returns the namespace the chart must be installed into, or an empty string if the chart is installed
+// into a namespace of its own. The namespace set with the 'ct.helm.sh/namespace' annotation in the chart's Chart.yaml
+// takes precedence over the configured namespace. Fixed namespaces are neither created nor deleted.
+func (t *Testing) fixedNamespace(chart *Chart) string {
+	if namespace := chart.Yaml().Annotations[namespaceAnnotation]; namespace != "" {
+		return namespace
 	}
-	if err := t.helm.Test(namespace, release); err != nil {
-		return err
+	return t.config.Namespace
+}
+
+// fixedUpgradeNamespace returns the namespace upgrade tests of the chart are run in, which by default is the same as
+// for installs. If a separate namespace is configured for upgrade tests, it returns an empty string, so that a new
+// namespace is generated even if installs use a fixed one.
+func (t *Testing) fixedUpgradeNamespace(chart *Chart) string {
+	if t.config.UpgradeSeparateNamespace {
+		return ""
+	}
+	return t.fixedNamespace(chart)
+}
+
+// releaseSelector returns the label selector matching the resources of the release in a fixed namespace. The label
+// is taken from the chart's 'ct.helm.sh/release-label' annotation, if present, and from the configuration otherwise.
+func (t *Testing) releaseSelector(chart *Chart, release string) string {
+	releaseLabel := t.config.ReleaseLabel
+	if label := chart.Yaml().Annotations[releaseLabelAnnotation]; label != "" {
+		releaseLabel = label
+	}
+	return fmt.Sprintf("%s=%s", releaseLabel, release)
+}
+
+// warnIfNoPodsMatch prints a warning if no pods in the namespace match the selector, which usually means that the
+// chart does not label its resources with the configured release label, so that waiting for deployments and printing
+// logs does not cover them.
+func (t *Testing) warnIfNoPodsMatch(namespace, selector string) {
+	pods, err := t.kubectl.GetPods("--namespace", namespace, "--selector", selector, "--output", "jsonpath={.items[*].metadata.name}")
+	if err == nil && len(pods) == 0 {
+		fmt.Printf("WARNING: No pods in namespace '%s' match the selector '%s'. If the chart uses another release label, "+
+			"specify it with '--release-label' or the '%s' annotation.\n", namespace, selector, releaseLabelAnnotation)
 	}
-	return nil
 }
 
-func (t *Testing) generateInstallConfig(chart *Chart) (namespace, release, releaseSelector string, cleanup func()) {
-	if t.config.Namespace != "" {
-		namespace = t.config.Namespace
+// generateInstallConfig returns the namespace, release, and release selector for installing the chart, and a function
+// cleaning up afterwards. If fixedNamespace is empty, a new namespace is generated and created using kubectl, so that
+// Helm never needs to create it, and it is deleted on cleanup. A fixed namespace is neither created nor deleted. The
+// cleanup function returns an error if resources of the release are leaked and leaks are configured to fail.
+func (t *Testing) generateInstallConfig(chart *Chart, fixedNamespace string) (namespace, release, releaseSelector string, cleanup func(failed bool) error, err error) {
+	if namespace = fixedNamespace; namespace != "" {
 		release, _ = chart.CreateInstallParams(t.config.BuildId)
-		releaseSelector = fmt.Sprintf("%s=%s", t.config.ReleaseLabel, release)
-		cleanup = func() {
-			t.PrintEventsPodDetailsAndLogs(namespace, releaseSelector)
-			t.helm.DeleteRelease(namespace, release)
-		}
-	} else {
-		release, namespace = chart.CreateInstallParams(t.config.BuildId)
-		cleanup = func() {
+		releaseSelector = t.releaseSelector(chart, release)
+		cleanup = func(failed bool) error {
 			t.PrintEventsPodDetailsAndLogs(namespace, releaseSelector)
+			if !t.cleanupPolicyApplies(failed, namespace, release) {
+				return nil
+			}
 			t.helm.DeleteRelease(namespace, release)
-			t.kubectl.DeleteNamespace(namespace)
+			t.deleteClusterResources(chart, release)
+			return t.verifyCleanup(chart, release)
 		}
+		return
 	}
 
+	release, namespace = chart.CreateInstallParams(t.config.BuildId)
+	if err = t.kubectl.CreateNamespace(namespace); err != nil {
+		return
+	}
+	if err = t.createNamespaceResources(namespace); err != nil {
+		t.kubectl.DeleteNamespace(namespace)
+		return
+	}
+	cleanup = func(failed bool) error {
+		t.PrintEventsPodDetailsAndLogs(namespace, releaseSelector)
+		if !t.cleanupPolicyApplies(failed, namespace, release) {
+			return nil
+		}
+		t.helm.DeleteRelease(namespace, release)
+		t.deleteClusterResources(chart, release)
+		t.kubectl.DeleteNamespace(namespace)
+		return t.verifyCleanup(chart, release)
+	}
 	return
 }
 
+// cleanupPolicyApplies returns whether the release should be deleted according to the configured cleanup policy,
+// given whether installing or testing it failed. Otherwise, it prints that the release is kept.
+func (t *Testing) cleanupPolicyApplies(failed bool, namespace, release string) bool {
+	switch policy := t.config.CleanupPolicy; {
+	case policy == "never", policy == "on-success" && failed, policy == "on-failure" && !failed:
+		fmt.Printf("Keeping release '%s' in namespace '%s' according to cleanup policy '%s'\n", release, namespace, policy)
+		return false
+	default:
+		return true
+	}
+}
+
+// verifyCleanup checks that no resources of the release remain in the cluster after cleaning up, if configured.
+// Leaked resources are reported as a warning or, if configured, returned as an error.
+func (t *Testing) verifyCleanup(chart *Chart, release string) error {
+	if !t.config.VerifyCleanup {
+		return nil
+	}
+
+	resources, err := t.kubectl.ListResourcesBySelector(t.releaseSelector(chart, release))
+	if err != nil {
+		fmt.Printf("Error verifying cleanup of release '%s': %s\n", release, err)
+		return nil
+	}
+	if len(resources) == 0 {
+		return nil
+	}
+
+	err = fmt.Errorf("Resources of release '%s' remain after cleanup: %s", release, strings.Join(resources, ", "))
+	if t.config.FailOnLeakedResources {
+		return err
+	}
+	fmt.Printf("WARNING: %s\n", err)
+	return nil
+}
+
+// deleteClusterResources deletes the cluster-scoped resources of the release, which are deleted neither along with the
+// namespace nor, e.g. CRDs installed from the chart's 'crds' directory, by Helm, if configured. Resources are selected
+// using the release label.
+func (t *Testing) deleteClusterResources(chart *Chart, release string) {
+	if !t.config.DeleteClusterResources {
+		return
+	}
+	if err := t.kubectl.DeleteClusterResources(t.releaseSelector(chart, release)); err != nil {
+		fmt.Printf("Error deleting cluster-scoped resources of release '%s': %s\n", release, err)
+	}
+}
+
 // LintAndInstallChart first lints and then installs the specified chart.
 func (t *Testing) LintAndInstallChart(chart *Chart) TestResult {
 	result := t.LintChart(chart)
-	if result.Error != nil {
+	if result.Error != nil || result.ExpectedError != nil {
 		return result
 	}
 	return t.InstallChart(chart)
@@ -666,16 +2595,111 @@ func (t *Testing) FindChartDirsToBeProcessed() ([]string, error) {
 		return t.ReadAllChartDirectories()
 	} else if len(cfg.Charts) > 0 {
 		return t.config.Charts, nil
+	} else if cfg.FailedChartsFrom != "" {
+		failedCharts, err := ReadFailedCharts(cfg.FailedChartsFrom)
+		if err != nil {
+			return nil, err
+		}
+		if failedCharts != nil {
+			return failedCharts, nil
+		}
+		fmt.Fprintf(t.infoOutput(), "No results found in '%s'. Identifying changed charts instead.\n", cfg.FailedChartsFrom)
 	}
 	return t.ComputeChangedChartDirectories()
 }
 
+// ReadFailedCharts reads the directories of the charts which failed according to a JSON results file, which holds
+// either an array of results or an object with the results in its 'results' field, like the file written by
+// WriteResultsFile. Charts which no longer exist are skipped with a warning on stderr. If the file holds no
+// results, nil is returned.
+func ReadFailedCharts(resultsFile string) ([]string, error) {
+	content, err := ioutil.ReadFile(resultsFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error reading results file")
+	}
+	if len(bytes.TrimSpace(content)) == 0 {
+		return nil, nil
+	}
+
+	type result struct {
+		Chart  string `json:"chart"`
+		Status Status `json:"status"`
+		Error  string `json:"error"`
+	}
+	var results []result
+	if err := json.Unmarshal(content, &results); err != nil {
+		var payload struct {
+			Results []result `json:"results"`
+		}
+		if payloadErr := json.Unmarshal(content, &payload); payloadErr != nil {
+			return nil, errors.Wrapf(err, "Error parsing results file '%s'", resultsFile)
+		}
+		results = payload.Results
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+
+	failedCharts := []string{}
+	for _, result := range results {
+		if result.Status != StatusFailed && result.Error == "" {
+			continue
+		}
+		if util.StringSliceContains(failedCharts, result.Chart) {
+			continue
+		}
+		if !util.FileExists(filepath.Join(result.Chart, "Chart.yaml")) {
+			fmt.Fprintf(os.Stderr, "Skipping failed chart '%s', which no longer exists\n", result.Chart)
+			continue
+		}
+		failedCharts = append(failedCharts, result.Chart)
+	}
+	return failedCharts, nil
+}
+
 func (t *Testing) computeMergeBase() (string, error) {
 	err := t.git.ValidateRepository()
 	if err != nil {
+		if t.config.RepositoryRoot != "" {
+			return "", fmt.Errorf("Repository root '%s' must be a git repository", t.config.RepositoryRoot)
+		}
 		return "", errors.New("Must be in a git repository")
 	}
-	return t.git.MergeBase(fmt.Sprintf("%s/%s", t.config.Remote, t.config.TargetBranch), "HEAD")
+	if mergeBase := t.config.MergeBase; mergeBase != "" {
+		if !t.git.CommitExists(mergeBase) {
+			return "", fmt.Errorf("Merge base '%s' does not exist", mergeBase)
+		}
+		return mergeBase, nil
+	}
+	return t.git.MergeBase(fmt.Sprintf("%s/%s", t.remote(), t.config.TargetBranch), "HEAD")
+}
+
+// remote returns the remote the target branch is compared against. If the target branch does not exist on the
+// configured remote, e.g. because it is on 'upstream' in a fork-based workflow, the other remotes are searched
+// for it and the first one having it is used.
+func (t *Testing) remote() string {
+	if t.resolvedRemote != "" {
+		return t.resolvedRemote
+	}
+
+	cfg := t.config
+	t.resolvedRemote = cfg.Remote
+	if t.git.BranchExists(cfg.Remote, cfg.TargetBranch) {
+		return t.resolvedRemote
+	}
+
+	remotes, err := t.git.ListRemotes()
+	if err != nil {
+		return t.resolvedRemote
+	}
+	for _, remote := range remotes {
+		if remote != cfg.Remote && t.git.BranchExists(remote, cfg.TargetBranch) {
+			fmt.Fprintf(t.infoOutput(), "Target branch '%s' not found on remote '%s'. Using remote '%s'.\n", cfg.TargetBranch, cfg.Remote, remote)
+			t.resolvedRemote = remote
+			break
+		}
+	}
+	return t.resolvedRemote
 }
 
 // ComputeChangedChartDirectories takes the merge base of HEAD and the configured remote and target branch and computes a
@@ -683,38 +2707,155 @@ func (t *Testing) computeMergeBase() (string, error) {
 func (t *Testing) ComputeChangedChartDirectories() ([]string, error) {
 	cfg := t.config
 
-	mergeBase, err := t.computeMergeBase()
+	allChangedChartFiles, err := t.listChangedChartFiles()
 	if err != nil {
 		return nil, err
 	}
-
-	allChangedChartFiles, err := t.git.ListChangedFilesInDirs(mergeBase, cfg.ChartDirs...)
-	if err != nil {
-		return nil, errors.Wrap(err, "Error creating diff")
+	if len(cfg.ChangedPaths) > 0 {
+		// Only consider changes within the configured subtrees
+		allChangedChartFiles = filterFilesInDirs(allChangedChartFiles, cfg.ChangedPaths)
 	}
 
 	var changedChartDirs []string
+	var invalidChartDirs []string
+	changedChartFiles := map[string][]string{}
 	for _, file := range allChangedChartFiles {
-		pathElements := strings.SplitN(filepath.ToSlash(file), "/", 3)
-		if len(pathElements) < 2 || util.StringSliceContains(cfg.ExcludedCharts, pathElements[1]) {
+		file = util.NormalizePath(file)
+		if !strings.Contains(file, "/") {
 			continue
 		}
-		dir := filepath.Dir(file)
+		dir := path.Dir(file)
 		// Make sure directory is really a chart directory
 		chartDir, err := t.chartUtils.LookupChartDir(cfg.ChartDirs, dir)
 		if err == nil {
+			if util.StringSliceContains(cfg.ExcludedCharts, path.Base(chartDir)) || t.isExcludedChartPath(chartDir) {
+				continue
+			}
 			// Only add it if not already in the list
 			if !util.StringSliceContains(changedChartDirs, chartDir) {
 				changedChartDirs = append(changedChartDirs, chartDir)
 			}
+			changedChartFiles[chartDir] = append(changedChartFiles[chartDir], file)
+		} else if cfg.StrictChangeDetection && t.isInvalidChartDir(dir) {
+			if !util.StringSliceContains(invalidChartDirs, dir) {
+				invalidChartDirs = append(invalidChartDirs, dir)
+			}
 		} else {
-			fmt.Printf("Directory '%s' is not a valid chart directory. Skipping...\n", dir)
+			fmt.Fprintf(t.infoOutput(), "Directory '%s' is not a valid chart directory. Skipping...\n", dir)
 		}
 	}
 
+	if len(invalidChartDirs) > 0 {
+		return nil, fmt.Errorf("Changed files in directories which are not valid chart directories: %s",
+			strings.Join(invalidChartDirs, ", "))
+	}
+
+	t.changedChartFiles = changedChartFiles
 	return changedChartDirs, nil
 }
 
+// isInvalidChartDir returns true if dir, containing changed files, still exists but is located within, and not
+// directly in, one of the configured chart directories. Changed files directly in a chart directory, e.g. a README,
+// and directories of deleted charts are not considered invalid.
+func (t *Testing) isInvalidChartDir(dir string) bool {
+	for _, chartDir := range t.config.ChartDirs {
+		if util.NormalizePath(chartDir) == dir {
+			return false
+		}
+	}
+	info, err := os.Stat(dir)
+	return err == nil && info.IsDir()
+}
+
+// ChangedFiles returns the changed files, relative to the chart directory, due to which ComputeChangedChartDirectories
+// identified the chart as changed.
+func (t *Testing) ChangedFiles(chartDir string) []string {
+	var changedFiles []string
+	for _, file := range t.changedChartFiles[chartDir] {
+		changedFiles = append(changedFiles, strings.TrimPrefix(file, chartDir+"/"))
+	}
+	return changedFiles
+}
+
+// ExplainChanges returns a suffix listing the changed files of the chart, e.g. ' (changed: values.yaml)', if
+// explaining changes is configured and the chart was identified as changed. Otherwise, it returns an empty string.
+func (t *Testing) ExplainChanges(chartDir string) string {
+	changedFiles := t.ChangedFiles(chartDir)
+	if !t.config.ExplainChanges || len(changedFiles) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (changed: %s)", strings.Join(changedFiles, ", "))
+}
+
+// listChangedChartFiles returns the changed files in the configured chart directories. The files are read from
+// the configured source if one is set, otherwise they are computed by diffing HEAD against the merge base.
+func (t *Testing) listChangedChartFiles() ([]string, error) {
+	cfg := t.config
+
+	if cfg.ChangedFilesFrom != "" {
+		changedFiles, err := readChangedFiles(cfg.ChangedFilesFrom)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error reading changed files")
+		}
+		// Like those git reports, the changed files are relative to the repository root
+		if cfg.RepositoryRoot != "" {
+			for i, file := range changedFiles {
+				changedFiles[i] = filepath.Join(cfg.RepositoryRoot, file)
+			}
+		}
+		return filterFilesInDirs(changedFiles, cfg.ChartDirs), nil
+	}
+
+	mergeBase, err := t.computeMergeBase()
+	if err != nil {
+		return nil, err
+	}
+
+	changedFiles, err := t.git.ListChangedFilesInDirs(mergeBase, cfg.ChartDirs...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error creating diff")
+	}
+	return changedFiles, nil
+}
+
+// readChangedFiles reads a newline-separated list of changed files from the specified file, or from stdin if
+// source is '-'.
+func readChangedFiles(source string) ([]string, error) {
+	var reader io.Reader = os.Stdin
+	if source != "-" {
+		file, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	var files []string
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		if file := strings.TrimSpace(scanner.Text()); file != "" {
+			files = append(files, file)
+		}
+	}
+	return files, scanner.Err()
+}
+
+// filterFilesInDirs returns those files which are located in any of the specified directories.
+func filterFilesInDirs(files []string, dirs []string) []string {
+	var filtered []string
+	for _, file := range files {
+		for _, dir := range dirs {
+			dir = util.NormalizePath(dir)
+			if dir == "." || strings.HasPrefix(util.NormalizePath(file), dir+"/") {
+				filtered = append(filtered, file)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
 // ReadAllChartDirectories returns a slice of all charts in the configured chart directories except those
 // configured to be excluded.
 func (t *Testing) ReadAllChartDirectories() ([]string, error) {
@@ -725,7 +2866,8 @@ func (t *Testing) ReadAllChartDirectories() ([]string, error) {
 		dirs, err := t.directoryLister.ListChildDirs(chartParentDir,
 			func(dir string) bool {
 				_, err := t.chartUtils.LookupChartDir(cfg.ChartDirs, dir)
-				return err == nil && !util.StringSliceContains(cfg.ExcludedCharts, filepath.Base(dir))
+				return err == nil && !util.StringSliceContains(cfg.ExcludedCharts, filepath.Base(dir)) &&
+					!t.isExcludedChartPath(dir)
 			})
 		if err != nil {
 			return nil, errors.Wrap(err, "Error reading chart directories")
@@ -736,32 +2878,232 @@ func (t *Testing) ReadAllChartDirectories() ([]string, error) {
 	return chartDirs, nil
 }
 
+// FindDependentChartDirectories returns the charts in the configured chart directories which depend on the specified
+// chart, directly or transitively, via 'file://' dependencies. The chart may be specified as a path to its directory
+// or by its name.
+func (t *Testing) FindDependentChartDirectories(chart string) ([]string, error) {
+	chartDirs, err := t.ReadAllChartDirectories()
+	if err != nil {
+		return nil, err
+	}
+
+	localDependencies := map[string][]string{}
+	var target string
+	for _, dir := range chartDirs {
+		chartYaml, err := util.ReadChartYaml(dir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error reading 'Chart.yaml' of chart '%s'", dir)
+		}
+		if chartYaml.Name == chart || filepath.Base(dir) == chart {
+			target = absolutePath(dir)
+		}
+		for _, dependency := range chartYaml.Dependencies {
+			if !strings.HasPrefix(dependency.Repository, "file://") {
+				continue
+			}
+			dependencyDir := strings.TrimPrefix(dependency.Repository, "file://")
+			if !filepath.IsAbs(dependencyDir) {
+				dependencyDir = filepath.Join(dir, dependencyDir)
+			}
+			localDependencies[dir] = append(localDependencies[dir], absolutePath(dependencyDir))
+		}
+	}
+
+	if info, err := os.Stat(chart); err == nil && info.IsDir() {
+		target = absolutePath(chart)
+	}
+	if target == "" {
+		return nil, fmt.Errorf("Chart '%s' not found", chart)
+	}
+
+	// Iterate until no further dependents are found, as charts may depend on dependents of the target.
+	dependencies := map[string]bool{target: true}
+	dependents := map[string]bool{}
+	for found := true; found; {
+		found = false
+		for _, dir := range chartDirs {
+			if dependents[dir] {
+				continue
+			}
+			for _, dependencyDir := range localDependencies[dir] {
+				if dependencies[dependencyDir] {
+					dependents[dir] = true
+					dependencies[absolutePath(dir)] = true
+					found = true
+					break
+				}
+			}
+		}
+	}
+
+	var dependentDirs []string
+	for _, dir := range chartDirs {
+		if dependents[dir] && absolutePath(dir) != target {
+			dependentDirs = append(dependentDirs, dir)
+		}
+	}
+	return dependentDirs, nil
+}
+
+// absolutePath returns the cleaned absolute path of p, or the cleaned path itself if it cannot be made absolute.
+func absolutePath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return filepath.Clean(p)
+}
+
+// isExcludedChartPath returns true if chartDir is, or is located in, one of the configured excluded chart paths.
+func (t *Testing) isExcludedChartPath(chartDir string) bool {
+	chartDir = util.NormalizePath(chartDir)
+	for _, excludedPath := range t.config.ExcludedChartPaths {
+		excludedPath = util.NormalizePath(excludedPath)
+		if chartDir == excludedPath || strings.HasPrefix(chartDir, excludedPath+"/") {
+			return true
+		}
+	}
+	return false
+}
+
 // CheckVersionIncrement checks that the new chart version is greater than the old one using semantic version comparison.
 func (t *Testing) CheckVersionIncrement(chart *Chart) error {
 	fmt.Printf("Checking chart '%s' for a version bump...\n", chart)
 
+	if t.onlyCIOrDocsChanged(chart) {
+		fmt.Println("Only CI values files or docs changed. Skipping version increment check.")
+		return nil
+	}
+
 	oldVersion, err := t.GetOldChartVersion(chart.Path())
 	if err != nil {
 		return err
 	}
-	if oldVersion == "" {
-		// new chart, skip version check
+	if oldVersion == "" {
+		// new chart, skip version check unless a minimum version is configured
+		return t.checkNewChartMinVersion(chart)
+	}
+
+	fmt.Println("Old chart version:", oldVersion)
+
+	chartYaml := chart.Yaml()
+	newVersion := chartYaml.Version
+	fmt.Println("New chart version:", newVersion)
+
+	if err := validateChartVersions(oldVersion, newVersion); err != nil {
+		return err
+	}
+
+	result, err := util.CompareVersions(oldVersion, newVersion)
+	if err != nil {
+		return err
+	}
+
+	if result >= 0 {
+		return errors.New("Chart version not ok. Needs a version bump!")
+	}
+
+	if !t.config.AllowPrereleaseBumps {
+		prereleaseBump, err := util.IsPrereleaseBump(oldVersion, newVersion)
+		if err != nil {
+			return err
+		}
+		if prereleaseBump {
+			return errors.New("Chart version not ok. Needs a version bump! Only the prerelease changed, which is not a version bump unless '--allow-prerelease-bumps' is enabled")
+		}
+	}
+
+	fmt.Println("Chart version ok.")
+	return nil
+}
+
+// CheckAppVersionIncrement checks that the chart's appVersion changed if any of its templates changed. The check is
+// skipped for new charts and if changed files have not been computed for the chart.
+func (t *Testing) CheckAppVersionIncrement(chart *Chart) error {
+	fmt.Printf("Checking chart '%s' for an appVersion change...\n", chart)
+
+	changedFiles, ok := t.changedChartFiles[util.NormalizePath(chart.Path())]
+	if !ok {
+		fmt.Println("Changed files of the chart are unknown. Skipping appVersion check.")
+		return nil
+	}
+	if !templatesChanged(chart, changedFiles) {
+		fmt.Println("No templates changed. Skipping appVersion check.")
+		return nil
+	}
+
+	oldChartYaml, err := t.getOldChartYaml(chart.Path())
+	if err != nil {
+		return err
+	}
+	if oldChartYaml == nil {
+		// new chart, nothing to compare against
+		return nil
+	}
+
+	oldAppVersion, newAppVersion := oldChartYaml.AppVersion, chart.Yaml().AppVersion
+	fmt.Println("Old chart appVersion:", oldAppVersion)
+	fmt.Println("New chart appVersion:", newAppVersion)
+
+	if oldAppVersion == newAppVersion {
+		return errors.New("Chart appVersion not ok. Templates changed, but appVersion did not!")
+	}
+
+	fmt.Println("Chart appVersion ok.")
+	return nil
+}
+
+// templatesChanged returns true if any of the changed files is located in the chart's 'templates' directory.
+func templatesChanged(chart *Chart, changedFiles []string) bool {
+	templatesPath := util.NormalizePath(chart.Path()) + "/templates/"
+	for _, file := range changedFiles {
+		if strings.HasPrefix(file, templatesPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// onlyCIOrDocsChanged returns true if changed files have been computed for the chart and all of them are located
+// in its 'ci' or 'docs' directory or are Markdown files, i.e. the chart itself did not change.
+func (t *Testing) onlyCIOrDocsChanged(chart *Chart) bool {
+	changedFiles, ok := t.changedChartFiles[util.NormalizePath(chart.Path())]
+	if !ok || len(changedFiles) == 0 {
+		return false
+	}
+
+	chartPath := util.NormalizePath(chart.Path())
+	for _, file := range changedFiles {
+		relPath := strings.TrimPrefix(file, chartPath+"/")
+		isCIOrDocs := strings.HasPrefix(relPath, "ci/") || strings.HasPrefix(relPath, "docs/") ||
+			strings.EqualFold(path.Ext(relPath), ".md")
+		if !isCIOrDocs {
+			return false
+		}
+	}
+	return true
+}
+
+// checkNewChartMinVersion checks that the version of a new chart is not lower than the configured minimum version.
+func (t *Testing) checkNewChartMinVersion(chart *Chart) error {
+	minVersion := t.config.NewChartMinVersion
+	if minVersion == "" {
 		return nil
 	}
 
-	fmt.Println("Old chart version:", oldVersion)
-
-	chartYaml := chart.Yaml()
-	newVersion := chartYaml.Version
+	newVersion := chart.Yaml().Version
 	fmt.Println("New chart version:", newVersion)
 
-	result, err := util.CompareVersions(oldVersion, newVersion)
+	if err := util.ValidateChartVersion(newVersion); err != nil {
+		return err
+	}
+
+	result, err := util.CompareVersions(newVersion, minVersion)
 	if err != nil {
 		return err
 	}
 
-	if result >= 0 {
-		return errors.New("Chart version not ok. Needs a version bump!")
+	if result < 0 {
+		return fmt.Errorf("Chart version not ok. New charts must start at version '%s' or higher!", minVersion)
 	}
 
 	fmt.Println("Chart version ok.")
@@ -779,31 +3121,54 @@ func (t *Testing) checkBreakingChangeAllowed(chart *Chart) (allowed bool, err er
 	}
 
 	newVersion := chart.Yaml().Version
+	if err := validateChartVersions(oldVersion, newVersion); err != nil {
+		return false, err
+	}
+
+	return util.BreakingChangeAllowedByRule(oldVersion, newVersion, t.config.UpgradeSkipVersionBumpRule)
+}
 
-	return util.BreakingChangeAllowed(oldVersion, newVersion)
+// validateChartVersions makes sure the old and the new version of a chart are valid semantic versions, so they can be
+// compared.
+func validateChartVersions(oldVersion string, newVersion string) error {
+	if err := util.ValidateChartVersion(oldVersion); err != nil {
+		return errors.Wrap(err, "Invalid version of previous chart revision")
+	}
+	return util.ValidateChartVersion(newVersion)
 }
 
 // GetOldChartVersion gets the version of the old Chart.yaml file from the target branch.
 func (t *Testing) GetOldChartVersion(chartPath string) (string, error) {
+	chartYaml, err := t.getOldChartYaml(chartPath)
+	if err != nil || chartYaml == nil {
+		return "", err
+	}
+	return chartYaml.Version, nil
+}
+
+// getOldChartYaml reads the old Chart.yaml file from the target branch. It returns nil if the chart does not exist
+// on the target branch.
+func (t *Testing) getOldChartYaml(chartPath string) (*util.ChartYaml, error) {
 	cfg := t.config
 
+	remote := t.remote()
 	chartYamlFile := filepath.Join(chartPath, "Chart.yaml")
-	if !t.git.FileExistsOnBranch(chartYamlFile, cfg.Remote, cfg.TargetBranch) {
+	if !t.git.FileExistsOnBranch(chartYamlFile, remote, cfg.TargetBranch) {
 		fmt.Printf("Unable to find chart on %s. New chart detected.\n", cfg.TargetBranch)
-		return "", nil
+		return nil, nil
 	}
 
-	chartYamlContents, err := t.git.Show(chartYamlFile, cfg.Remote, cfg.TargetBranch)
+	chartYamlContents, err := t.git.Show(chartYamlFile, remote, cfg.TargetBranch)
 	if err != nil {
-		return "", errors.Wrap(err, "Error reading old Chart.yaml")
+		return nil, errors.Wrap(err, "Error reading old Chart.yaml")
 	}
 
 	chartYaml, err := util.UnmarshalChartYaml([]byte(chartYamlContents))
 	if err != nil {
-		return "", errors.Wrap(err, "Error reading old chart version")
+		return nil, errors.Wrap(err, "Error reading old chart version")
 	}
 
-	return chartYaml.Version, nil
+	return chartYaml, nil
 }
 
 // ValidateMaintainers validates maintainers in the Chart.yaml file. Maintainer names must be valid accounts
@@ -831,17 +3196,479 @@ func (t *Testing) ValidateMaintainers(chart *Chart) error {
 
 	for _, maintainer := range chartYaml.Maintainers {
 		if err := t.accountValidator.Validate(repoUrl, maintainer.Name); err != nil {
+			if t.config.WarnOnAccountTimeout && errors.Is(err, tool.ErrAccountValidationTimeout) {
+				fmt.Printf("WARNING: %s. Skipping validation of maintainers...\n", err)
+				return nil
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateDeprecation validates that deprecated charts tell their users where to go, either with a replacement set
+// with the 'ct.helm.sh/replacement' annotation or with a description.
+func (t *Testing) ValidateDeprecation(chart *Chart) error {
+	chartYaml := chart.Yaml()
+	if !chartYaml.Deprecated {
+		return nil
+	}
+
+	fmt.Println("Validating deprecation notice...")
+	if strings.TrimSpace(chartYaml.Annotations[replacementAnnotation]) == "" && strings.TrimSpace(chartYaml.Description) == "" {
+		return fmt.Errorf("Deprecated chart must have a description or a '%s' annotation pointing to a replacement", replacementAnnotation)
+	}
+	return nil
+}
+
+// ValidateImageRegistries renders the chart with its default values and each of the specified values files and
+// validates that all container images are pulled from one of the allowed registries.
+func (t *Testing) ValidateImageRegistries(chart *Chart, valuesFiles []string) error {
+	fmt.Println("Validating image registries...")
+
+	var result error
+	for _, valuesFile := range append([]string{""}, valuesFiles...) {
+		manifests, err := t.helm.TemplateWithValues(chart.Path(), valuesFile)
+		if err != nil {
+			return errors.Wrapf(err, "Error rendering chart with values file '%s'", valuesFile)
+		}
+
+		images, err := findContainerImages(manifests)
+		if err != nil {
+			return errors.Wrapf(err, "Error parsing chart rendered with values file '%s'", valuesFile)
+		}
+
+		for _, image := range images {
+			if !util.StringSliceContains(t.config.AllowedImageRegistries, util.ImageRegistry(image.name)) {
+				source := image.source
+				if valuesFile != "" {
+					source = fmt.Sprintf("%s with values file '%s'", source, valuesFile)
+				}
+				result = multierror.Append(result, fmt.Errorf("image '%s' in %s is not pulled from an allowed registry", image.name, source))
+			}
+		}
+	}
+	return result
+}
+
+// ValidateManifestLimits renders the chart with its default values and each of the specified values files and checks
+// that neither the number of rendered resources nor the size of the rendered manifests exceeds the configured maximum.
+func (t *Testing) ValidateManifestLimits(chart *Chart, valuesFiles []string) error {
+	fmt.Println("Validating manifest limits...")
+
+	var result error
+	for _, valuesFile := range append([]string{""}, valuesFiles...) {
+		manifests, err := t.helm.TemplateWithValues(chart.Path(), valuesFile)
+		if err != nil {
+			return errors.Wrapf(err, "Error rendering chart with values file '%s'", valuesFile)
+		}
+
+		source := renderedChartDescription(valuesFile)
+		if resources := countResources(manifests); t.config.MaxRenderedResources > 0 && resources > t.config.MaxRenderedResources {
+			result = multierror.Append(result, fmt.Errorf("%s has %d resources, exceeding the maximum of %d",
+				source, resources, t.config.MaxRenderedResources))
+		}
+		if size := len(manifests); t.config.MaxManifestBytes > 0 && size > t.config.MaxManifestBytes {
+			result = multierror.Append(result, fmt.Errorf("%s has %d bytes of manifests, exceeding the maximum of %d",
+				source, size, t.config.MaxManifestBytes))
+		}
+	}
+	return result
+}
+
+// ValidateConftestPolicies renders the chart with its default values and each of the specified values files and runs
+// conftest with each of the configured policy directories against the rendered manifests.
+func (t *Testing) ValidateConftestPolicies(chart *Chart, valuesFiles []string) error {
+	fmt.Println("Validating conftest policies...")
+
+	var result error
+	for _, valuesFile := range append([]string{""}, valuesFiles...) {
+		manifests, err := t.helm.TemplateWithValues(chart.Path(), valuesFile)
+		if err != nil {
+			return errors.Wrapf(err, "Error rendering chart with values file '%s'", valuesFile)
+		}
+
+		for _, policyDir := range t.config.ConftestPolicies {
+			if err := t.linter.Conftest(manifests, policyDir); err != nil {
+				result = multierror.Append(result, errors.Wrapf(err, "%s violates policies in '%s'",
+					renderedChartDescription(valuesFile), policyDir))
+			}
+		}
+	}
+	return result
+}
+
+// renderedChartDescription describes the chart rendered with the specified values file for error messages.
+func renderedChartDescription(valuesFile string) string {
+	if valuesFile == "" {
+		return "chart rendered with default values"
+	}
+	return fmt.Sprintf("chart rendered with values file '%s'", valuesFile)
+}
+
+// countResources returns the number of YAML documents in the rendered manifests which are not empty, i.e. not only
+// made of comments such as '# Source: ...'.
+func countResources(manifests string) int {
+	resources := 0
+	for _, document := range strings.Split(manifests, "\n---") {
+		for _, line := range strings.Split(document, "\n") {
+			if line = strings.TrimSpace(line); line != "" && line != "---" && !strings.HasPrefix(line, "#") {
+				resources++
+				break
+			}
+		}
+	}
+	return resources
+}
+
+// containerImage is a container image referenced in a rendered manifest.
+type containerImage struct {
+	name   string
+	source string
+}
+
+// findContainerImages returns the images of all containers, init containers, and ephemeral containers in the
+// rendered manifests along with the templates they originate from.
+func findContainerImages(manifests string) ([]containerImage, error) {
+	var images []containerImage
+	for _, document := range strings.Split(manifests, "\n---") {
+		source := "unknown template"
+		for _, line := range strings.Split(document, "\n") {
+			if strings.HasPrefix(line, "# Source: ") {
+				source = fmt.Sprintf("'%s'", strings.TrimPrefix(line, "# Source: "))
+				break
+			}
+		}
+
+		var manifest interface{}
+		if err := yaml.Unmarshal([]byte(document), &manifest); err != nil {
+			return nil, err
+		}
+		for _, image := range collectContainerImages(manifest) {
+			images = append(images, containerImage{name: image, source: source})
+		}
+	}
+	return images, nil
+}
+
+// collectContainerImages recursively collects the images of all containers in node.
+func collectContainerImages(node interface{}) []string {
+	var images []string
+	switch node := node.(type) {
+	case map[interface{}]interface{}:
+		for key, value := range node {
+			if key == "containers" || key == "initContainers" || key == "ephemeralContainers" {
+				if containers, ok := value.([]interface{}); ok {
+					for _, container := range containers {
+						if container, ok := container.(map[interface{}]interface{}); ok {
+							if image, ok := container["image"].(string); ok {
+								images = append(images, image)
+							}
+						}
+					}
+				}
+			}
+			images = append(images, collectContainerImages(value)...)
+		}
+	case []interface{}:
+		for _, value := range node {
+			images = append(images, collectContainerImages(value)...)
+		}
+	}
+	return images
+}
+
+// ValidateChartYaml validates that the required fields 'apiVersion', 'name', and 'version' are present in the
+// Chart.yaml file, and that 'apiVersion: v1' charts do not use the 'dependencies' field introduced with 'apiVersion: v2'.
+func (t *Testing) ValidateChartYaml(chart *Chart) error {
+	fmt.Println("Validating Chart.yaml...")
+
+	chartYaml := chart.Yaml()
+
+	var result error
+	if chartYaml.ApiVersion == "" {
+		result = multierror.Append(result, errors.New("Chart.yaml is missing required field 'apiVersion'"))
+	}
+	if chartYaml.Name == "" {
+		result = multierror.Append(result, errors.New("Chart.yaml is missing required field 'name'"))
+	}
+	if chartYaml.Version == "" {
+		result = multierror.Append(result, errors.New("Chart.yaml is missing required field 'version'"))
+	}
+	if chartYaml.ApiVersion == "v1" && len(chartYaml.Dependencies) > 0 {
+		result = multierror.Append(result, errors.New(
+			"Chart.yaml with 'apiVersion: v1' must not specify 'dependencies'; use 'requirements.yaml' or 'apiVersion: v2'"))
+	}
+
+	return result
+}
+
+// ValidateRequiredAnnotations validates that the configured required annotations are present in the Chart.yaml file
+// and not empty.
+func (t *Testing) ValidateRequiredAnnotations(chart *Chart) error {
+	fmt.Println("Validating required annotations...")
+
+	annotations := chart.Yaml().Annotations
+
+	var result error
+	for _, annotation := range t.config.RequiredAnnotations {
+		if strings.TrimSpace(annotations[annotation]) == "" {
+			result = multierror.Append(result, fmt.Errorf("Chart.yaml is missing required annotation '%s'", annotation))
+		}
+	}
+
+	return result
+}
+
+// ValidateDependencyVersions validates that the versions of dependencies vendored as directories in the chart's
+// 'charts' directory satisfy the versions declared for them in the Chart.yaml file.
+func (t *Testing) ValidateDependencyVersions(chart *Chart) error {
+	fmt.Println("Validating dependency versions...")
+
+	var result error
+	for _, dependency := range chart.Yaml().Dependencies {
+		vendoredDir := filepath.Join(chart.Path(), "charts", dependency.Name)
+		if !util.FileExists(filepath.Join(vendoredDir, "Chart.yaml")) {
+			continue
+		}
+
+		vendoredChartYaml, err := util.ReadChartYaml(vendoredDir)
+		if err != nil {
+			result = multierror.Append(result, errors.Wrapf(err, "Error reading vendored dependency '%s'", dependency.Name))
+			continue
+		}
+
+		constraint, err := semver.NewConstraint(dependency.Version)
+		if err != nil {
+			result = multierror.Append(result, errors.Wrapf(err, "Error parsing version of dependency '%s'", dependency.Name))
+			continue
+		}
+		vendoredVersion, err := semver.NewVersion(vendoredChartYaml.Version)
+		if err != nil {
+			result = multierror.Append(result, errors.Wrapf(err, "Error parsing version of vendored dependency '%s'", dependency.Name))
+			continue
+		}
+		if !constraint.Check(vendoredVersion) {
+			result = multierror.Append(result, fmt.Errorf(
+				"Vendored dependency '%s' has version '%s', but Chart.yaml declares '%s'",
+				dependency.Name, vendoredChartYaml.Version, dependency.Version))
+		}
+	}
+
+	return result
+}
+
+var pinnedVersionPattern = regexp.MustCompile(`^v?\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+
+// ValidatePinnedDependencies validates that the dependencies in the Chart.yaml file are pinned to exact versions
+// rather than version ranges. Local dependencies, i.e. those with a 'file://' repository, are exempt, as they are
+// versioned with the chart.
+func (t *Testing) ValidatePinnedDependencies(chart *Chart) error {
+	fmt.Println("Validating dependencies are pinned...")
+
+	var result error
+	for _, dependency := range chart.Yaml().Dependencies {
+		if strings.HasPrefix(dependency.Repository, "file://") {
+			continue
+		}
+		if !pinnedVersionPattern.MatchString(strings.TrimSpace(dependency.Version)) {
+			result = multierror.Append(result, fmt.Errorf(
+				"Dependency '%s' has version '%s', which is not an exact version", dependency.Name, dependency.Version))
+		}
+	}
+
+	return result
+}
+
+// ResolveDependencies checks that the dependencies of all charts resolve, i.e. that local dependencies referenced
+// with 'file://' are charts and that remote dependencies are available in their repositories in a matching version.
+// Dependencies without repository are expected to be vendored and are not checked. All problems are returned at once.
+// Dependencies from the URL of a repository specified with '--chart-repos' are resolved by the repository's name, so
+// its credentials and its index already downloaded by Helm are used. Each distinct reference is only resolved once.
+func (t *Testing) ResolveDependencies(charts []*Chart) error {
+	fmt.Println("Resolving dependencies of charts...")
+
+	repoNames := t.chartRepoNamesByURL()
+	resolved := map[string]error{}
+	resolve := func(chart string, repository string, version string) error {
+		key := strings.Join([]string{chart, repository, version}, " ")
+		if err, ok := resolved[key]; ok {
 			return err
 		}
+		err := t.helm.ResolveChart(chart, repository, version)
+		resolved[key] = err
+		return err
+	}
+
+	var result error
+	for _, chart := range charts {
+		for _, dependency := range chart.Yaml().Dependencies {
+			repository := dependency.Repository
+			var err error
+			switch {
+			case repository == "":
+				continue
+			case strings.HasPrefix(repository, "file://"):
+				path := filepath.Join(chart.Path(), strings.TrimPrefix(repository, "file://"))
+				if !util.FileExists(filepath.Join(path, "Chart.yaml")) {
+					err = fmt.Errorf("'%s' is not a chart", path)
+				}
+			case strings.HasPrefix(repository, "oci://"):
+				err = resolve(strings.TrimSuffix(repository, "/")+"/"+dependency.Name, "", dependency.Version)
+			case strings.HasPrefix(repository, "@") || strings.HasPrefix(repository, "alias:"):
+				alias := strings.TrimPrefix(strings.TrimPrefix(repository, "@"), "alias:")
+				err = resolve(alias+"/"+dependency.Name, "", dependency.Version)
+			default:
+				if name, ok := repoNames[strings.TrimSuffix(repository, "/")]; ok {
+					err = resolve(name+"/"+dependency.Name, "", dependency.Version)
+				} else {
+					err = resolve(dependency.Name, repository, dependency.Version)
+				}
+			}
+			if err != nil {
+				result = multierror.Append(result, errors.Wrapf(err, "Dependency '%s' of chart '%s' does not resolve", dependency.Name, chart.Path()))
+			}
+		}
+	}
+	return result
+}
+
+// chartRepoNamesByURL returns the names of the repositories specified with '--chart-repos' by their URLs without
+// trailing slash.
+func (t *Testing) chartRepoNamesByURL() map[string]string {
+	names := map[string]string{}
+	for _, repo := range t.config.ChartRepos {
+		repoSlice := strings.SplitN(repo, "=", 2)
+		if len(repoSlice) == 2 {
+			names[strings.TrimSuffix(repoSlice[1], "/")] = repoSlice[0]
+		}
+	}
+	return names
+}
+
+// ValidateCIValuesKeys validates that the top-level keys set in the values files exist in the chart's values, i.e.
+// in its 'values.yaml', in the properties of its 'values.schema.json', or as the alias or, lacking one, the name of a
+// dependency.
+// 'global' is always allowed.
+func (t *Testing) ValidateCIValuesKeys(chart *Chart, valuesFiles []string) error {
+	fmt.Println("Validating keys of CI values files...")
+
+	knownKeys, err := chartValuesKeys(chart)
+	if err != nil {
+		return err
+	}
+
+	var result error
+	for _, valuesFile := range valuesFiles {
+		keys, err := valuesFileKeys(valuesFile)
+		if err != nil {
+			result = multierror.Append(result, err)
+			continue
+		}
+		var unknownKeys []string
+		for _, key := range keys {
+			if !knownKeys[key] {
+				unknownKeys = append(unknownKeys, key)
+			}
+		}
+		if len(unknownKeys) > 0 {
+			result = multierror.Append(result, fmt.Errorf("values file '%s' sets keys which do not exist in the chart's values: %s",
+				valuesFile, strings.Join(unknownKeys, ", ")))
+		}
+	}
+	return result
+}
+
+// ValidateDefaultsAgainstSchema validates the chart's default values in its 'values.yaml' against its
+// 'values.schema.json'. Helm only validates the defaults merged with the values files passed to it, so values files
+// overriding invalid defaults hide them. The chart is therefore linted without values files, and errors reported for
+// 'values.yaml' are returned. Other findings are left to the regular lint. If linting fails without any findings, e.g.
+// because Helm cannot load the chart, the error is returned.
+func (t *Testing) ValidateDefaultsAgainstSchema(chart *Chart) error {
+	if !util.FileExists(filepath.Join(chart.Path(), "values.schema.json")) {
+		return nil
 	}
 
+	fmt.Println("Validating default values against values schema...")
+	output, err := t.helm.LintWithValuesAndCaptureOutput(chart.Path(), "")
+	findings := lintFindings(output)
+	if err != nil && len(findings) == 0 {
+		return errors.Wrapf(err, "Error linting chart with its default values: %s", strings.TrimSpace(output))
+	}
+	var violations []string
+	for _, finding := range findings {
+		if finding.Severity == "ERROR" && finding.Path == "values.yaml" {
+			violations = append(violations, strings.TrimPrefix(finding.Message, "- "))
+		}
+	}
+	if len(violations) > 0 {
+		return fmt.Errorf("default values in 'values.yaml' do not match the chart's values schema: %s", strings.Join(violations, "; "))
+	}
 	return nil
 }
 
+// chartValuesKeys returns the top-level keys the chart's values may have.
+func chartValuesKeys(chart *Chart) (map[string]bool, error) {
+	knownKeys := map[string]bool{"global": true}
+	for _, dependency := range chart.Yaml().Dependencies {
+		if dependency.Alias != "" {
+			knownKeys[dependency.Alias] = true
+		} else {
+			knownKeys[dependency.Name] = true
+		}
+	}
+
+	if valuesYaml := filepath.Join(chart.Path(), "values.yaml"); util.FileExists(valuesYaml) {
+		keys, err := valuesFileKeys(valuesYaml)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			knownKeys[key] = true
+		}
+	}
+
+	if valuesSchema := filepath.Join(chart.Path(), "values.schema.json"); util.FileExists(valuesSchema) {
+		content, err := ioutil.ReadFile(valuesSchema)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error reading values schema")
+		}
+		var schema struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		}
+		if err := json.Unmarshal(content, &schema); err != nil {
+			return nil, errors.Wrap(err, "Error parsing values schema")
+		}
+		for key := range schema.Properties {
+			knownKeys[key] = true
+		}
+	}
+	return knownKeys, nil
+}
+
+// valuesFileKeys returns the sorted top-level keys of the values file.
+func valuesFileKeys(valuesFile string) ([]string, error) {
+	content, err := ioutil.ReadFile(valuesFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error reading values file '%s'", valuesFile)
+	}
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(content, &values); err != nil {
+		return nil, errors.Wrapf(err, "Error parsing values file '%s'", valuesFile)
+	}
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
 func (t *Testing) PrintEventsPodDetailsAndLogs(namespace string, selector string) {
-	util.PrintDelimiterLine("=")
+	t.printDelimiterLine("=")
 
-	printDetails(namespace, "Events of namespace", ".", func(item string) error {
+	t.printDetails(namespace, "Events of namespace", ".", func(item string) (string, error) {
 		return t.kubectl.GetEvents(namespace)
 	}, namespace)
 
@@ -855,57 +3682,158 @@ func (t *Testing) PrintEventsPodDetailsAndLogs(namespace string, selector string
 		"jsonpath={.items[*].metadata.name}",
 	)
 	if err != nil {
-		fmt.Println("Error printing logs:", err)
+		fmt.Fprintln(t.output(), "Error printing logs:", err)
 		return
 	}
 
-	for _, pod := range pods {
-		printDetails(pod, "Description of pod", "~", func(item string) error {
-			return t.kubectl.DescribePod(namespace, pod)
-		}, pod)
+	t.fetchPodDetailsAndLogs(namespace, pods, func(details string) {
+		fmt.Fprint(t.output(), details)
+	})
 
-		initContainers, err := t.kubectl.GetInitContainers(namespace, pod)
-		if err != nil {
-			fmt.Println("Error printing logs:", err)
-			return
+	t.printDelimiterLine("=")
+}
+
+// fetchPodDetailsAndLogs fetches the descriptions and container logs of the pods concurrently and passes each pod's
+// output as a single block to the callback, in the order of the pods.
+func (t *Testing) fetchPodDetailsAndLogs(namespace string, pods []string, callback func(details string)) {
+	blocks := make([]chan string, len(pods))
+	for i := range blocks {
+		blocks[i] = make(chan string, 1)
+	}
+
+	go func() {
+		semaphore := make(chan struct{}, podDetailsParallelism)
+		for i, pod := range pods {
+			semaphore <- struct{}{}
+			go func(pod string, block chan<- string) {
+				defer func() { <-semaphore }()
+				block <- t.podDetailsAndLogs(namespace, pod)
+			}(pod, blocks[i])
 		}
+	}()
+
+	for _, block := range blocks {
+		callback(<-block)
+	}
+}
+
+// podDetailsAndLogs returns the description of the pod and the logs of its init containers and containers.
+func (t *Testing) podDetailsAndLogs(namespace string, pod string) string {
+	var details strings.Builder
+	details.WriteString(formatDetails(pod, "Description of pod", "~", func(item string) (string, error) {
+		return t.kubectl.DescribePod(namespace, pod)
+	}, pod))
+
+	initContainers, err := t.kubectl.GetInitContainers(namespace, pod)
+	if err != nil {
+		fmt.Fprintln(&details, "Error printing logs:", err)
+		return details.String()
+	}
 
-		printDetails(pod, "Logs of init container", "-",
-			func(item string) error {
-				return t.kubectl.Logs(namespace, pod, item)
-			}, initContainers...)
+	containers, err := t.kubectl.GetContainers(namespace, pod)
+	if err != nil {
+		fmt.Fprintln(&details, "Error printing logs:", err)
+		return details.String()
+	}
 
-		containers, err := t.kubectl.GetContainers(namespace, pod)
+	if t.config.LogsFailedContainersOnly {
+		failedContainers, err := t.kubectl.GetFailedContainers(namespace, pod)
 		if err != nil {
-			fmt.Println("Error printing logs:", err)
-			return
+			fmt.Fprintln(&details, "Error printing logs:", err)
+			return details.String()
+		}
+		initContainers = filterContainers(initContainers, failedContainers)
+		containers = filterContainers(containers, failedContainers)
+	}
+
+	details.WriteString(formatDetails(pod, "Logs of init container", "-",
+		func(item string) (string, error) {
+			return t.kubectl.Logs(namespace, pod, item, t.config.LogsTail)
+		}, initContainers...))
+
+	details.WriteString(formatDetails(pod, "Logs of container", "-",
+		func(item string) (string, error) {
+			return t.kubectl.Logs(namespace, pod, item, t.config.LogsTail)
+		},
+		containers...))
+	return details.String()
+}
+
+// filterContainers returns the containers which are contained in selected, in their original order.
+func filterContainers(containers []string, selected []string) []string {
+	var filtered []string
+	for _, container := range containers {
+		if util.StringSliceContains(selected, container) {
+			filtered = append(filtered, container)
+		}
+	}
+	return filtered
+}
+
+// PrintDebugInfo prints a triage snapshot of the specified namespace: all resources, the descriptions of all pods
+// which are not ready, and the events of the namespace.
+func (t *Testing) PrintDebugInfo(namespace string) {
+	t.printDelimiterLine("=")
+
+	t.printDetails(namespace, "Resources in namespace", ".", func(item string) (string, error) {
+		return t.kubectl.GetAll(namespace)
+	}, namespace)
+
+	pods, err := t.kubectl.GetNonReadyPods(namespace)
+	if err != nil {
+		fmt.Fprintln(t.output(), "Error printing debug info:", err)
+	} else {
+		for _, pod := range pods {
+			t.printDetails(pod, "Description of non-ready pod", "~", func(item string) (string, error) {
+				return t.kubectl.DescribePod(namespace, pod)
+			}, pod)
 		}
+	}
+
+	t.printDetails(namespace, "Events of namespace", ".", func(item string) (string, error) {
+		return t.kubectl.GetEvents(namespace)
+	}, namespace)
+
+	t.printDelimiterLine("=")
+}
 
-		printDetails(pod, "Logs of container", "-",
-			func(item string) error {
-				return t.kubectl.Logs(namespace, pod, item)
-			},
-			containers...)
+// printDebugInfoOnFailure prints debug info for the namespace if debugging on failure is enabled and
+// err points to a non-nil error. It is meant to be deferred after cleanup, so it runs before the
+// namespace is deleted.
+func (t *Testing) printDebugInfoOnFailure(namespace string, err *error) {
+	if t.config.DebugOnFailure && *err != nil {
+		t.PrintDebugInfo(namespace)
 	}
+}
 
-	util.PrintDelimiterLine("=")
+// printDetails prints the details of each item, as formatted by formatDetails, to the output of Testing.
+func (t *Testing) printDetails(resource string, text string, delimiterChar string, fetchFunc func(item string) (string, error), items ...string) {
+	fmt.Fprint(t.output(), formatDetails(resource, text, delimiterChar, fetchFunc, items...))
 }
 
-func printDetails(resource string, text string, delimiterChar string, printFunc func(item string) error, items ...string) {
+// formatDetails returns the details of each item, as returned by fetchFunc, between delimiter lines and a header and
+// footer naming the resource. If fetching fails, the error is included and the remaining items are skipped.
+func formatDetails(resource string, text string, delimiterChar string, fetchFunc func(item string) (string, error), items ...string) string {
+	var details strings.Builder
 	for _, item := range items {
 		item = strings.Trim(item, "'")
 
-		util.PrintDelimiterLine(delimiterChar)
-		fmt.Printf("==> %s %s\n", text, resource)
-		util.PrintDelimiterLine(delimiterChar)
+		fmt.Fprintln(&details, util.DelimiterLine(delimiterChar))
+		fmt.Fprintf(&details, "==> %s %s\n", text, resource)
+		fmt.Fprintln(&details, util.DelimiterLine(delimiterChar))
 
-		if err := printFunc(item); err != nil {
-			fmt.Println("Error printing details:", err)
-			return
+		output, err := fetchFunc(item)
+		if output != "" {
+			fmt.Fprintln(&details, output)
+		}
+		if err != nil {
+			fmt.Fprintln(&details, "Error printing details:", err)
+			break
 		}
 
-		util.PrintDelimiterLine(delimiterChar)
-		fmt.Printf("<== %s %s\n", text, resource)
-		util.PrintDelimiterLine(delimiterChar)
+		fmt.Fprintln(&details, util.DelimiterLine(delimiterChar))
+		fmt.Fprintf(&details, "<== %s %s\n", text, resource)
+		fmt.Fprintln(&details, util.DelimiterLine(delimiterChar))
 	}
+	return details.String()
 }
@@ -15,11 +15,23 @@
 package chart
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/hashicorp/go-multierror"
 	"github.com/helm/chart-testing/v3/pkg/config"
+	"github.com/helm/chart-testing/v3/pkg/tool"
 	"github.com/helm/chart-testing/v3/pkg/util"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
@@ -63,10 +75,30 @@ func (g fakeGit) GetUrlForRemote(remote string) (string, error) {
 	return "git@github.com/helm/chart-testing", nil
 }
 
+func (g fakeGit) CommitExists(commit string) bool {
+	return commit != "unknown"
+}
+
 func (g fakeGit) ValidateRepository() error {
 	return nil
 }
 
+func (g fakeGit) ListRemotes() ([]string, error) {
+	return []string{"origin", "upstream"}, nil
+}
+
+func (g fakeGit) BranchExists(remote string, branch string) bool {
+	return true
+}
+
+type fakeForkGit struct {
+	fakeGit
+}
+
+func (g fakeForkGit) BranchExists(remote string, branch string) bool {
+	return remote == "upstream"
+}
+
 type fakeAccountValidator struct{}
 
 func (v fakeAccountValidator) Validate(repoDomain string, account string) error {
@@ -88,27 +120,143 @@ func (l *fakeLinter) Yamale(yamlFile, schemaFile string) error {
 	l.Called(yamlFile, schemaFile)
 	return nil
 }
+func (l *fakeLinter) Conftest(manifest, policyDir string) error {
+	args := l.Called(manifest, policyDir)
+	return args.Error(0)
+}
 
 type fakeHelm struct{}
 
-func (h fakeHelm) AddRepo(name, url string, extraArgs []string) error   { return nil }
-func (h fakeHelm) BuildDependencies(chart string) error                 { return nil }
-func (h fakeHelm) LintWithValues(chart string, valuesFile string) error { return nil }
+func (h fakeHelm) AddRepo(name, url string, extraArgs []string) error { return nil }
+func (h fakeHelm) BuildDependencies(chart string) error               { return nil }
+func (h fakeHelm) LintWithValuesAndCaptureOutput(chart string, valuesFile string) (string, error) {
+	return "", nil
+}
+func (h fakeHelm) UnitTest(chart string) error                 { return nil }
+func (h fakeHelm) RunPlugin(plugin string, chart string) error { return nil }
+func (h fakeHelm) Pull(chart string, version string, destination string) error {
+	return nil
+}
+func (h fakeHelm) ResolveChart(chart string, repository string, version string) error {
+	return nil
+}
+func (h fakeHelm) TemplateWithValues(chart string, valuesFile string) (string, error) {
+	return "", nil
+}
 func (h fakeHelm) InstallWithValues(chart string, valuesFile string, namespace string, release string) error {
 	return nil
 }
 func (h fakeHelm) Upgrade(chart string, namespace string, release string) error {
 	return nil
 }
-func (h fakeHelm) Test(namespace string, release string) error {
+func (h fakeHelm) Test(namespace string, release string, filters []string) error {
 	return nil
 }
 func (h fakeHelm) DeleteRelease(namespace string, release string) {}
+func (h fakeHelm) Validate() error                                { return nil }
 
 func (h fakeHelm) Version() (string, error) {
 	return "v3.0.0", nil
 }
 
+type fakeKubectl struct {
+	mock.Mock
+}
+
+func (k *fakeKubectl) CreateNamespace(namespace string) error { return nil }
+func (k *fakeKubectl) DeleteNamespace(namespace string)       {}
+func (k *fakeKubectl) CreateDockerSecret(namespace string, name string, dockerConfig string) error {
+	args := k.Called(namespace, name, dockerConfig)
+	return args.Error(0)
+}
+func (k *fakeKubectl) CreateServiceAccount(namespace string, name string) error {
+	args := k.Called(namespace, name)
+	return args.Error(0)
+}
+func (k *fakeKubectl) BindClusterRole(namespace string, serviceAccount string, clusterRole string) error {
+	args := k.Called(namespace, serviceAccount, clusterRole)
+	return args.Error(0)
+}
+func (k *fakeKubectl) WaitForDeployments(namespace string, selector string) error {
+	return nil
+}
+func (k *fakeKubectl) WaitForResourcesDeleted(namespace string, selector string, ignored []string) error {
+	return nil
+}
+func (k *fakeKubectl) ListNamespacedResources(namespace string, selector string) ([]string, error) {
+	return nil, nil
+}
+func (k *fakeKubectl) GetPodsforDeployment(namespace string, deployment string) ([]string, error) {
+	return nil, nil
+}
+func (k *fakeKubectl) GetPods(args ...string) ([]string, error)   { return nil, nil }
+func (k *fakeKubectl) GetEvents(namespace string) (string, error) { return "", nil }
+func (k *fakeKubectl) GetAll(namespace string) (string, error) {
+	k.Called(namespace)
+	return "", nil
+}
+func (k *fakeKubectl) GetNonReadyPods(namespace string) ([]string, error) {
+	k.Called(namespace)
+	return []string{"pod"}, nil
+}
+func (k *fakeKubectl) DescribePod(namespace string, pod string) (string, error) {
+	k.Called(namespace, pod)
+	return "", nil
+}
+func (k *fakeKubectl) Logs(namespace string, pod string, container string, tail int) (string, error) {
+	return "", nil
+}
+func (k *fakeKubectl) GetInitContainers(namespace string, pod string) ([]string, error) {
+	return nil, nil
+}
+func (k *fakeKubectl) GetContainers(namespace string, pod string) ([]string, error) {
+	return nil, nil
+}
+func (k *fakeKubectl) GetFailedContainers(namespace string, pod string) ([]string, error) {
+	return nil, nil
+}
+func (k *fakeKubectl) DeleteClusterResources(selector string) error {
+	k.Called(selector)
+	return nil
+}
+func (k *fakeKubectl) ListResourcesBySelector(selector string) ([]string, error) {
+	args := k.Called(selector)
+	return args.Get(0).([]string), args.Error(1)
+}
+func (k *fakeKubectl) ApplyManifests(files []string) error {
+	args := k.Called(files)
+	return args.Error(0)
+}
+func (k *fakeKubectl) WaitForCondition(condition string, files []string) error {
+	args := k.Called(condition, files)
+	return args.Error(0)
+}
+func (k *fakeKubectl) GetNamespaces(selector string) (map[string]time.Time, error) {
+	args := k.Called(selector)
+	return args.Get(0).(map[string]time.Time), args.Error(1)
+}
+func (k *fakeKubectl) GetReleases(namespace string) ([]string, error) {
+	args := k.Called(namespace)
+	return args.Get(0).([]string), args.Error(1)
+}
+func (k *fakeKubectl) WaitForAutoscaling(namespace string, selector string) error {
+	args := k.Called(namespace, selector)
+	return args.Error(0)
+}
+func (k *fakeKubectl) CheckResourceExists(namespace string, resource string) (bool, error) {
+	args := k.Called(namespace, resource)
+	return args.Bool(0), args.Error(1)
+}
+
+type fakeScriptRunner struct {
+	mock.Mock
+}
+
+func (r *fakeScriptRunner) Run(script string, env []string) error {
+	args := r.Called(script, env)
+	return args.Error(0)
+}
+
 var ct Testing
 
 func init() {
@@ -130,6 +278,7 @@ func newTestingMock(cfg config.Configuration) Testing {
 		accountValidator: fakeAccountValidator{},
 		linter:           fakeMockLinter,
 		helm:             fakeHelm{},
+		kubectl:          new(fakeKubectl),
 	}
 }
 
@@ -143,11 +292,77 @@ func TestComputeChangedChartDirectories(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestFindChartDirsToBeProcessedFailedCharts(t *testing.T) {
+	var testDataSlice = []struct {
+		name     string
+		content  string
+		expected []string
+		err      string
+	}{
+		{"results", `[{"chart": "testdata/test_lints", "status": "Failed", "error": "lint failed"}, {"chart": "testdata/valid_maintainers", "status": "Passed"}]`, []string{"testdata/test_lints"}, ""},
+		{"payload", `{"success": false, "results": [{"chart": "testdata/test_lints", "status": "Failed"}, {"chart": "testdata/test_lints", "status": "Failed"}, {"chart": "testdata/removed", "status": "Failed"}]}`, []string{"testdata/test_lints"}, ""},
+		{"no failures", `[{"chart": "testdata/test_lints", "status": "Passed"}]`, []string{}, ""},
+		{"empty", "", []string{"test_charts/foo", "test_charts/bar", "test_chart_at_root"}, ""},
+		{"no results", `{"results": []}`, []string{"test_charts/foo", "test_charts/bar", "test_chart_at_root"}, ""},
+		{"invalid", "failed", nil, "Error parsing results file"},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			resultsFile := filepath.Join(t.TempDir(), "results.json")
+			assert.Nil(t, ioutil.WriteFile(resultsFile, []byte(testData.content), 0644))
+
+			cfg := ct.config
+			cfg.FailedChartsFrom = resultsFile
+			failedCt := newTestingMock(cfg)
+			failedCt.directoryLister = ct.directoryLister
+			failedCt.chartUtils = ct.chartUtils
+
+			actual, err := failedCt.FindChartDirsToBeProcessed()
+			if testData.err != "" {
+				assert.Contains(t, err.Error(), testData.err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.ElementsMatch(t, testData.expected, actual)
+		})
+	}
+}
+
+func TestComputeMergeBase(t *testing.T) {
+	var testDataSlice = []struct {
+		name      string
+		mergeBase string
+		expected  string
+		err       string
+	}{
+		{"computed", "", "HEAD", ""},
+		{"configured", "abc123", "abc123", ""},
+		{"unknown", "unknown", "", "Merge base 'unknown' does not exist"},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			ct := newTestingMock(config.Configuration{MergeBase: testData.mergeBase})
+			mergeBase, err := ct.computeMergeBase()
+			if testData.err != "" {
+				assert.EqualError(t, err, testData.err)
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, testData.expected, mergeBase)
+		})
+	}
+}
+
 func TestReadAllChartDirectories(t *testing.T) {
 	actual, err := ct.ReadAllChartDirectories()
 	expected := []string{
 		"test_charts/foo",
 		"test_charts/bar",
+		"test_charts/cluster-scoped-resources",
+		"test_charts/crds-and-custom-resources",
+		"test_charts/migration-job",
 		"test_charts/must-pass-upgrade-install",
 		"test_charts/mutating-deployment-selector",
 		"test_charts/mutating-sfs-volumeclaim",
@@ -156,8 +371,204 @@ func TestReadAllChartDirectories(t *testing.T) {
 	for _, chart := range actual {
 		assert.Contains(t, expected, chart)
 	}
-	assert.Len(t, actual, 6)
+	assert.Len(t, actual, 9)
+	assert.Nil(t, err)
+}
+
+func TestValidateDeprecation(t *testing.T) {
+	var testDataSlice = []struct {
+		name     string
+		chartDir string
+		expected bool
+	}{
+		{"not-deprecated", "testdata/valid_maintainers", true},
+		{"deprecated-without-notice", "testdata/no_maintainers_deprecated", false},
+		{"deprecated-with-replacement", "testdata/deprecated_with_replacement", true},
+		{"deprecated-with-description", "testdata/deprecated_with_description", true},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			chart, err := NewChart(testData.chartDir)
+			assert.Nil(t, err)
+			validationErr := ct.ValidateDeprecation(chart)
+			assert.Equal(t, testData.expected, validationErr == nil)
+		})
+	}
+}
+
+type fakeTemplateHelm struct {
+	fakeHelm
+	manifests map[string]string
+}
+
+func (h fakeTemplateHelm) TemplateWithValues(chart string, valuesFile string) (string, error) {
+	return h.manifests[valuesFile], nil
+}
+
+func TestValidateImageRegistries(t *testing.T) {
+	defaultManifests := `---
+# Source: foo/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: init
+          image: busybox:1.32
+      containers:
+        - name: foo
+          image: quay.io/foo/foo:1.0.0
+---
+# Source: foo/templates/service.yaml
+apiVersion: v1
+kind: Service
+`
+	ciManifests := `---
+# Source: foo/templates/cronjob.yaml
+apiVersion: batch/v1beta1
+kind: CronJob
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+            - name: job
+              image: registry.example.com:5000/foo/job
+`
+
+	var testDataSlice = []struct {
+		name       string
+		registries []string
+		errors     int
+	}{
+		{"all-allowed", []string{"docker.io", "quay.io", "registry.example.com:5000"}, 0},
+		{"docker-hub-forbidden", []string{"quay.io", "registry.example.com:5000"}, 1},
+		{"only-mirror-allowed", []string{"registry.example.com:5000"}, 2},
+	}
+
+	chart, err := NewChart("testdata/test_lints")
+	assert.Nil(t, err)
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			ct := newTestingMock(config.Configuration{AllowedImageRegistries: testData.registries})
+			ct.helm = fakeTemplateHelm{manifests: map[string]string{"": defaultManifests, "ci/test-values.yaml": ciManifests}}
+
+			err := ct.ValidateImageRegistries(chart, []string{"ci/test-values.yaml"})
+			if testData.errors == 0 {
+				assert.Nil(t, err)
+				return
+			}
+			multiErr, ok := err.(*multierror.Error)
+			assert.True(t, ok)
+			assert.Len(t, multiErr.Errors, testData.errors)
+		})
+	}
+}
+
+func TestValidateManifestLimits(t *testing.T) {
+	defaultManifests := `---
+# Source: foo/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+---
+# Source: foo/templates/empty.yaml
+---
+# Source: foo/templates/service.yaml
+apiVersion: v1
+kind: Service
+`
+	ciManifests := defaultManifests + `---
+# Source: foo/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+`
+
+	var testDataSlice = []struct {
+		name         string
+		maxResources int
+		maxBytes     int
+		expected     []string
+	}{
+		{"within-limits", 3, len(ciManifests), nil},
+		{"too-many-resources", 2, 0, []string{
+			"chart rendered with values file 'ci/test-values.yaml' has 3 resources, exceeding the maximum of 2"}},
+		{"too-large", 0, len(defaultManifests) - 1, []string{
+			fmt.Sprintf("chart rendered with default values has %d bytes of manifests, exceeding the maximum of %d", len(defaultManifests), len(defaultManifests)-1),
+			fmt.Sprintf("chart rendered with values file 'ci/test-values.yaml' has %d bytes of manifests, exceeding the maximum of %d", len(ciManifests), len(defaultManifests)-1)}},
+	}
+
+	chart, err := NewChart("testdata/test_lints")
+	assert.Nil(t, err)
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			ct := newTestingMock(config.Configuration{MaxRenderedResources: testData.maxResources, MaxManifestBytes: testData.maxBytes})
+			ct.helm = fakeTemplateHelm{manifests: map[string]string{"": defaultManifests, "ci/test-values.yaml": ciManifests}}
+
+			err := ct.ValidateManifestLimits(chart, []string{"ci/test-values.yaml"})
+			if testData.expected == nil {
+				assert.Nil(t, err)
+				return
+			}
+			multiErr, ok := err.(*multierror.Error)
+			assert.True(t, ok)
+			var actual []string
+			for _, err := range multiErr.Errors {
+				actual = append(actual, err.Error())
+			}
+			assert.Equal(t, testData.expected, actual)
+		})
+	}
+}
+
+func TestValidateConftestPolicies(t *testing.T) {
+	chart, err := NewChart("testdata/test_lints")
+	assert.Nil(t, err)
+
+	linter := new(fakeLinter)
+	linter.On("Conftest", "default", "policy/security").Return(nil)
+	linter.On("Conftest", "default", "policy/naming").Return(nil)
+	linter.On("Conftest", "ci", "policy/security").Return(errors.New("1 policy violation(s): main - Containers must not run as root"))
+	linter.On("Conftest", "ci", "policy/naming").Return(nil)
+
+	ct := newTestingMock(config.Configuration{ConftestPolicies: []string{"policy/security", "policy/naming"}})
+	ct.helm = fakeTemplateHelm{manifests: map[string]string{"": "default", "ci/test-values.yaml": "ci"}}
+	ct.linter = linter
+
+	err = ct.ValidateConftestPolicies(chart, []string{"ci/test-values.yaml"})
+	multiErr, ok := err.(*multierror.Error)
+	assert.True(t, ok)
+	assert.Len(t, multiErr.Errors, 1)
+	assert.EqualError(t, multiErr.Errors[0], "chart rendered with values file 'ci/test-values.yaml' violates policies in "+
+		"'policy/security': 1 policy violation(s): main - Containers must not run as root")
+	linter.AssertNumberOfCalls(t, "Conftest", 4)
+}
+
+func TestExcludedChartPaths(t *testing.T) {
+	ct := newTestingMock(config.Configuration{
+		ChartDirs:          []string{"test_charts", "."},
+		ExcludedChartPaths: []string{"test_charts/foo", `test_charts\mutating-sfs-volumeclaim\`},
+	})
+
+	actual, err := ct.ReadAllChartDirectories()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{
+		"test_charts/bar",
+		"test_charts/cluster-scoped-resources",
+		"test_charts/crds-and-custom-resources",
+		"test_charts/migration-job",
+		"test_charts/must-pass-upgrade-install",
+		"test_charts/mutating-deployment-selector",
+		"test_chart_at_root",
+	}, actual)
+
+	actual, err = ct.ComputeChangedChartDirectories()
 	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"test_charts/bar", "test_chart_at_root"}, actual)
 }
 
 func TestValidateMaintainers(t *testing.T) {
@@ -184,6 +595,40 @@ func TestValidateMaintainers(t *testing.T) {
 	}
 }
 
+type fakeTimeoutAccountValidator struct{}
+
+func (v fakeTimeoutAccountValidator) Validate(repoDomain string, account string) error {
+	return errors.Wrap(tool.ErrAccountValidationTimeout, "Error validating maintainer")
+}
+
+func TestValidateMaintainersTimeout(t *testing.T) {
+	chart, err := NewChart("testdata/valid_maintainers")
+	assert.Nil(t, err)
+
+	ct := newTestingMock(config.Configuration{})
+	ct.accountValidator = fakeTimeoutAccountValidator{}
+	assert.EqualError(t, ct.ValidateMaintainers(chart), "Error validating maintainer: timed out")
+
+	ct.config.WarnOnAccountTimeout = true
+	assert.Nil(t, ct.ValidateMaintainers(chart))
+}
+
+func TestValidateCIValuesKeys(t *testing.T) {
+	chart, err := NewChart("testdata/ci_values_keys")
+	assert.Nil(t, err)
+
+	ct := newTestingMock(config.Configuration{})
+	assert.Nil(t, ct.ValidateCIValuesKeys(chart, []string{"testdata/ci_values_keys/ci/valid-values.yaml"}))
+
+	err = ct.ValidateCIValuesKeys(chart, chart.ValuesFilePathsForCI())
+	assert.EqualError(t, err, "1 error occurred:\n\t* values file 'testdata/ci_values_keys/ci/stale-values.yaml' sets keys which do not exist in the chart's values: postgresql, replicas\n\n")
+
+	ct = newTestingMock(config.Configuration{ValidateCIValuesKeys: true})
+	result := ct.LintChart(chart)
+	assert.NotNil(t, result.Error)
+	assert.Equal(t, "lint", ErrorType(result.Error))
+}
+
 func TestLintChartMaintainerValidation(t *testing.T) {
 	type testData struct {
 		name     string
@@ -359,7 +804,8 @@ func TestGenerateInstallConfig(t *testing.T) {
 		t.Run(testData.name, func(t *testing.T) {
 			ct := newTestingMock(testData.cfg)
 
-			namespace, release, releaseSelector, _ := ct.generateInstallConfig(testData.chart)
+			namespace, release, releaseSelector, _, err := ct.generateInstallConfig(testData.chart, ct.fixedNamespace(testData.chart))
+			assert.Nil(t, err)
 			assert.NotEqual(t, "", namespace)
 			assert.NotEqual(t, "", release)
 			assert.True(t, len(release) < 64, "release should be less than 64 chars")
@@ -375,45 +821,2487 @@ func TestGenerateInstallConfig(t *testing.T) {
 	}
 }
 
-func TestChart_HasCIValuesFile(t *testing.T) {
-	type testData struct {
-		name     string
-		chart    *Chart
-		file     string
-		expected bool
+func TestGenerateInstallConfigDeleteClusterResources(t *testing.T) {
+	chart, err := NewChart("testdata/test_lints")
+	assert.Nil(t, err)
+
+	runTest := func(cfg config.Configuration, fixedNamespace string, expectedCalls int) {
+		kubectl := new(fakeKubectl)
+		kubectl.On("DeleteClusterResources", mock.Anything)
+		ct := newTestingMock(cfg)
+		ct.kubectl = kubectl
+
+		_, release, _, cleanup, err := ct.generateInstallConfig(chart, fixedNamespace)
+		assert.Nil(t, err)
+		cleanup(false)
+
+		kubectl.AssertNumberOfCalls(t, "DeleteClusterResources", expectedCalls)
+		if expectedCalls > 0 {
+			kubectl.AssertCalled(t, "DeleteClusterResources", "app.kubernetes.io/instance="+release)
+		}
 	}
 
-	testCases := []testData{
-		{
-			name: "has file",
-			chart: &Chart{
-				ciValuesPaths: []string{"foo-values.yaml"},
-			},
-			file:     "foo-values.yaml",
-			expected: true,
-		},
-		{
-			name: "different paths",
-			chart: &Chart{
-				ciValuesPaths: []string{"ci/foo-values.yaml"},
-			},
-			file:     "foo/bar/foo-values.yaml",
-			expected: true,
-		},
-		{
-			name: "does not have file",
-			chart: &Chart{
-				ciValuesPaths: []string{"foo-values.yaml"},
-			},
-			file:     "bar-values.yaml",
-			expected: false,
-		},
+	runTest(config.Configuration{ReleaseLabel: "app.kubernetes.io/instance"}, "", 0)
+	runTest(config.Configuration{ReleaseLabel: "app.kubernetes.io/instance", DeleteClusterResources: true}, "", 1)
+	runTest(config.Configuration{ReleaseLabel: "app.kubernetes.io/instance", DeleteClusterResources: true}, "default", 1)
+}
+
+func TestGenerateInstallConfigVerifyCleanup(t *testing.T) {
+	chart, err := NewChart("testdata/test_lints")
+	assert.Nil(t, err)
+
+	var testDataSlice = []struct {
+		name          string
+		cfg           config.Configuration
+		leaked        []string
+		expectedCalls int
+		expectedErr   string
+	}{
+		{"disabled", config.Configuration{}, []string{"persistentvolume/pv-1"}, 0, ""},
+		{"no leaks", config.Configuration{VerifyCleanup: true}, []string{}, 1, ""},
+		{"leaks warning", config.Configuration{VerifyCleanup: true}, []string{"persistentvolume/pv-1"}, 1, ""},
+		{"leaks failure", config.Configuration{VerifyCleanup: true, FailOnLeakedResources: true},
+			[]string{"persistentvolume/pv-1", "clusterrole.rbac.authorization.k8s.io/viewer"}, 1,
+			"Resources of release '%s' remain after cleanup: persistentvolume/pv-1, clusterrole.rbac.authorization.k8s.io/viewer"},
 	}
 
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			actual := tc.chart.HasCIValuesFile(tc.file)
-			assert.Equal(t, tc.expected, actual)
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			testData.cfg.ReleaseLabel = "app.kubernetes.io/instance"
+			kubectl := new(fakeKubectl)
+			kubectl.On("ListResourcesBySelector", mock.Anything).Return(testData.leaked, nil)
+			ct := newTestingMock(testData.cfg)
+			ct.kubectl = kubectl
+
+			_, release, _, cleanup, err := ct.generateInstallConfig(chart, "")
+			assert.Nil(t, err)
+			err = cleanup(false)
+
+			kubectl.AssertNumberOfCalls(t, "ListResourcesBySelector", testData.expectedCalls)
+			if testData.expectedCalls > 0 {
+				kubectl.AssertCalled(t, "ListResourcesBySelector", "app.kubernetes.io/instance="+release)
+			}
+			if testData.expectedErr == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, fmt.Sprintf(testData.expectedErr, release))
+			}
 		})
 	}
 }
+
+func TestGenerateInstallConfigNamespaceLifecycle(t *testing.T) {
+	chart, err := NewChart("testdata/test_lints")
+	assert.Nil(t, err)
+
+	t.Run("per-chart namespace", func(t *testing.T) {
+		kubectl := &fakeRecordingKubectl{fakeKubectl: new(fakeKubectl)}
+		ct := newTestingMock(config.Configuration{})
+		ct.kubectl = kubectl
+
+		namespace, _, _, cleanup, err := ct.generateInstallConfig(chart, "")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{namespace}, kubectl.createdNamespaces)
+		assert.Empty(t, kubectl.deletedNamespaces)
+
+		cleanup(false)
+		assert.Equal(t, []string{namespace}, kubectl.deletedNamespaces)
+	})
+
+	t.Run("shared namespace", func(t *testing.T) {
+		kubectl := &fakeRecordingKubectl{fakeKubectl: new(fakeKubectl)}
+		ct := newTestingMock(config.Configuration{Namespace: "default", ReleaseLabel: "app.kubernetes.io/instance"})
+		ct.kubectl = kubectl
+
+		namespace, _, _, cleanup, err := ct.generateInstallConfig(chart, ct.fixedNamespace(chart))
+		assert.Nil(t, err)
+		assert.Equal(t, "default", namespace)
+
+		cleanup(false)
+		assert.Empty(t, kubectl.createdNamespaces)
+		assert.Empty(t, kubectl.deletedNamespaces)
+	})
+
+	t.Run("namespace resources fail", func(t *testing.T) {
+		kubectl := &fakeRecordingKubectl{fakeKubectl: new(fakeKubectl)}
+		kubectl.On("CreateDockerSecret", mock.Anything, "regcred", "docker-config.json").Return(errors.New("exit status 1"))
+		ct := newTestingMock(config.Configuration{ImagePullSecret: "regcred=docker-config.json"})
+		ct.kubectl = kubectl
+
+		namespace, _, _, cleanup, err := ct.generateInstallConfig(chart, "")
+		assert.NotNil(t, err)
+		assert.Nil(t, cleanup)
+		assert.Equal(t, []string{namespace}, kubectl.createdNamespaces)
+		assert.Equal(t, []string{namespace}, kubectl.deletedNamespaces)
+	})
+}
+
+// fakeNamespace holds the resources in a namespace shared by fakeReinstallKubectl and fakeReinstallHelm.
+type fakeNamespace struct {
+	resources []string
+}
+
+func (n *fakeNamespace) remove(resource string) {
+	var resources []string
+	for _, r := range n.resources {
+		if r != resource {
+			resources = append(resources, r)
+		}
+	}
+	n.resources = resources
+}
+
+type fakeReinstallKubectl struct {
+	*fakeKubectl
+	namespace *fakeNamespace
+	ignored   []string
+}
+
+func (k *fakeReinstallKubectl) CreateDockerSecret(namespace string, name string, dockerConfig string) error {
+	k.namespace.resources = append(k.namespace.resources, "secret/"+name)
+	return nil
+}
+
+func (k *fakeReinstallKubectl) CreateServiceAccount(namespace string, name string) error {
+	k.namespace.resources = append(k.namespace.resources, "serviceaccount/"+name)
+	return nil
+}
+
+func (k *fakeReinstallKubectl) BindClusterRole(namespace string, serviceAccount string, clusterRole string) error {
+	k.namespace.resources = append(k.namespace.resources, "rolebinding.rbac.authorization.k8s.io/"+serviceAccount)
+	return nil
+}
+
+func (k *fakeReinstallKubectl) ListNamespacedResources(namespace string, selector string) ([]string, error) {
+	return append([]string(nil), k.namespace.resources...), nil
+}
+
+func (k *fakeReinstallKubectl) WaitForResourcesDeleted(namespace string, selector string, ignored []string) error {
+	k.ignored = ignored
+	var remaining []string
+	for _, resource := range k.namespace.resources {
+		if !util.StringSliceContains(ignored, resource) {
+			remaining = append(remaining, resource)
+		}
+	}
+	if len(remaining) > 0 {
+		return fmt.Errorf("resources in namespace '%s' were not deleted: %s", namespace, strings.Join(remaining, ", "))
+	}
+	return nil
+}
+
+type fakeReinstallHelm struct {
+	fakeHelm
+	namespace *fakeNamespace
+	installs  *int
+}
+
+func (h fakeReinstallHelm) InstallWithValues(chart string, valuesFile string, namespace string, release string) error {
+	*h.installs++
+	h.namespace.resources = append(h.namespace.resources, "deployment.apps/"+release, "persistentvolumeclaim/"+release)
+	return nil
+}
+
+func (h fakeReinstallHelm) DeleteRelease(namespace string, release string) {
+	h.namespace.remove("deployment.apps/" + release)
+	h.namespace.remove("persistentvolumeclaim/" + release)
+}
+
+func TestInstallWithValuesFileTestReinstall(t *testing.T) {
+	chart, err := NewChart("testdata/test_lints")
+	assert.Nil(t, err)
+
+	namespace := &fakeNamespace{}
+	installs := 0
+	kubectl := &fakeReinstallKubectl{fakeKubectl: new(fakeKubectl), namespace: namespace}
+	ct := newTestingMock(config.Configuration{
+		TestReinstall:             true,
+		ImagePullSecret:           "regcred=docker-config.json",
+		ServiceAccount:            "ci",
+		ServiceAccountClusterRole: "edit",
+		CleanupPolicy:             "never",
+	})
+	ct.kubectl = kubectl
+	ct.helm = fakeReinstallHelm{namespace: namespace, installs: &installs}
+
+	assert.Nil(t, ct.installWithValuesFile(chart, ""))
+	assert.Equal(t, 2, installs)
+	assert.Equal(t, []string{"secret/regcred", "serviceaccount/ci", "rolebinding.rbac.authorization.k8s.io/ci"}, kubectl.ignored)
+
+	t.Run("leftovers", func(t *testing.T) {
+		namespace := &fakeNamespace{}
+		installs := 0
+		ct.kubectl = &fakeReinstallKubectl{fakeKubectl: new(fakeKubectl), namespace: namespace}
+		ct.helm = fakeLeakingReinstallHelm{fakeReinstallHelm{namespace: namespace, installs: &installs}}
+
+		err := ct.installWithValuesFile(chart, "")
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "reinstall failed: resources in namespace")
+		assert.Contains(t, err.Error(), ": persistentvolumeclaim/")
+		assert.Equal(t, 1, installs)
+	})
+}
+
+// fakeLeakingReinstallHelm leaves the PVC of the release behind when deleting it, like a StatefulSet does.
+type fakeLeakingReinstallHelm struct {
+	fakeReinstallHelm
+}
+
+func (h fakeLeakingReinstallHelm) DeleteRelease(namespace string, release string) {
+	h.namespace.remove("deployment.apps/" + release)
+}
+
+func TestGenerateInstallConfigCleanupPolicy(t *testing.T) {
+	chart, err := NewChart("testdata/test_lints")
+	assert.Nil(t, err)
+
+	var testDataSlice = []struct {
+		policy          string
+		failed          bool
+		expectedDeleted bool
+	}{
+		{"always", false, true},
+		{"always", true, true},
+		{"on-success", false, true},
+		{"on-success", true, false},
+		{"on-failure", false, false},
+		{"on-failure", true, true},
+		{"never", false, false},
+		{"never", true, false},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(fmt.Sprintf("%s failed=%t", testData.policy, testData.failed), func(t *testing.T) {
+			kubectl := &fakeRecordingKubectl{fakeKubectl: new(fakeKubectl)}
+			ct := newTestingMock(config.Configuration{CleanupPolicy: testData.policy})
+			ct.kubectl = kubectl
+
+			_, _, _, cleanup, err := ct.generateInstallConfig(chart, "")
+			assert.Nil(t, err)
+			assert.Nil(t, cleanup(testData.failed))
+			assert.Equal(t, testData.expectedDeleted, len(kubectl.deletedNamespaces) == 1)
+		})
+	}
+}
+
+func TestCheckResourceAssertions(t *testing.T) {
+	dir := t.TempDir()
+	valuesFile := filepath.Join(dir, "ingress-values.yaml")
+	assert.Nil(t, ioutil.WriteFile(valuesFile, []byte("ingress:\n  enabled: true\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "ingress-assertions.yaml"), []byte(`resources:
+  - kind: Ingress
+    name: "{{ .Release }}-web"
+  - kind: Service
+    name: "{{ .Release }}-web"
+  - kind: ClusterRole
+    name: "{{ .Release }}-reader"
+`), 0644))
+
+	kubectl := new(fakeKubectl)
+	kubectl.On("CheckResourceExists", "ns", "ingress/foo-web").Return(false, nil)
+	kubectl.On("CheckResourceExists", "ns", "service/foo-web").Return(true, nil)
+	kubectl.On("CheckResourceExists", "ns", "clusterrole/foo-reader").Return(false, nil)
+	ct := newTestingMock(config.Configuration{})
+	ct.kubectl = kubectl
+
+	err := ct.checkResourceAssertions(valuesFile, "ns", "foo")
+	assert.EqualError(t, err, fmt.Sprintf("Expected resources missing after installing with values file '%s': ingress/foo-web, clusterrole/foo-reader", valuesFile))
+
+	assert.Equal(t, filepath.Join(dir, "ingress-assertions.yaml"), assertionsFile(valuesFile+".tpl"))
+	assert.Nil(t, ct.checkResourceAssertions(filepath.Join(dir, "other-values.yaml"), "ns", "foo"))
+	assert.Nil(t, ct.checkResourceAssertions("", "ns", "foo"))
+}
+
+func TestLoadChart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ct_load_chart")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	chartDir := dir + "/foo"
+	assert.Nil(t, os.Mkdir(chartDir, 0755))
+	assert.Nil(t, ioutil.WriteFile(chartDir+"/Chart.yaml", []byte("name: foo\nversion: 1.0.0\n"), 0644))
+
+	ct := newTestingMock(config.Configuration{})
+	chart, err := ct.loadChart(chartDir)
+	assert.Nil(t, err)
+	assert.Equal(t, "1.0.0", chart.Yaml().Version)
+
+	// Changes during the run are not picked up
+	assert.Nil(t, ioutil.WriteFile(chartDir+"/Chart.yaml", []byte("name: foo\nversion: 2.0.0\n"), 0644))
+	cachedChart, err := ct.loadChart(chartDir + "/")
+	assert.Nil(t, err)
+	assert.Same(t, chart, cachedChart)
+
+	ct.forgetCharts(dir)
+	chart, err = ct.loadChart(chartDir)
+	assert.Nil(t, err)
+	assert.Equal(t, "2.0.0", chart.Yaml().Version)
+
+	_, err = ct.loadChart(dir + "/bar")
+	assert.NotNil(t, err)
+}
+
+func TestChart_HasCIValuesFile(t *testing.T) {
+	type testData struct {
+		name     string
+		chart    *Chart
+		file     string
+		expected bool
+	}
+
+	testCases := []testData{
+		{
+			name: "has file",
+			chart: &Chart{
+				ciValuesPaths: []string{"foo-values.yaml"},
+			},
+			file:     "foo-values.yaml",
+			expected: true,
+		},
+		{
+			name: "different paths",
+			chart: &Chart{
+				ciValuesPaths: []string{"ci/foo-values.yaml"},
+			},
+			file:     "foo/bar/foo-values.yaml",
+			expected: true,
+		},
+		{
+			name: "does not have file",
+			chart: &Chart{
+				ciValuesPaths: []string{"foo-values.yaml"},
+			},
+			file:     "bar-values.yaml",
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := tc.chart.HasCIValuesFile(tc.file)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestPrintResultsOutput(t *testing.T) {
+	chart, err := NewChart("testdata/test_lints")
+	assert.Nil(t, err)
+
+	var output bytes.Buffer
+	ct := newTestingMock(config.Configuration{})
+	ct.Output = &output
+
+	ct.PrintResults([]TestResult{{Chart: chart}, {Chart: chart, Error: errors.New("install failed")}})
+	assert.Contains(t, output.String(), fmt.Sprintf(" %s %s\n", ct.resultSymbol(StatusPassed), chart))
+	assert.Contains(t, output.String(), fmt.Sprintf(" %s %s > install failed\n", ct.resultSymbol(StatusFailed), chart))
+
+	output.Reset()
+	ct.PrintResults(nil)
+	assert.Contains(t, output.String(), "No chart changes detected.\n")
+}
+
+func TestGroupResults(t *testing.T) {
+	lintChart, err := NewChart("testdata/test_lints")
+	assert.Nil(t, err)
+	fooChart, err := NewChart("test_charts/foo")
+	assert.Nil(t, err)
+	nestedChart, err := NewChart("testdata/dependents/app")
+	assert.Nil(t, err)
+
+	results := []TestResult{
+		{Chart: fooChart, Error: errors.New("install failed")},
+		{Chart: lintChart},
+		{Chart: nestedChart},
+		{Chart: nil},
+	}
+
+	var output bytes.Buffer
+	ct := newTestingMock(config.Configuration{ChartDirs: []string{"testdata", "test_charts", "testdata/dependents"}})
+	ct.Output = &output
+
+	groups := ct.groupResults(results)
+	assert.Len(t, groups, 4)
+	assert.Equal(t, "testdata", groups[0].ChartDir)
+	assert.Equal(t, []TestResult{results[1]}, groups[0].Results)
+	assert.Equal(t, "test_charts", groups[1].ChartDir)
+	assert.Equal(t, ResultSummary{Failed: 1, Total: 1}, groups[1].Summary)
+	assert.Equal(t, "testdata/dependents", groups[2].ChartDir)
+	assert.Equal(t, "", groups[3].ChartDir)
+
+	ct.PrintResults(results[:3])
+	assert.Contains(t, output.String(), " testdata:\n")
+	assert.Contains(t, output.String(), " test_charts:\n")
+	assert.Contains(t, output.String(), " Passed: 1, Failed: 0, Skipped: 0 (total 1)\n")
+	assert.Contains(t, output.String(), " Passed: 2, Failed: 1, Skipped: 0 (total 3)\n")
+
+	ct = newTestingMock(config.Configuration{ChartDirs: []string{"testdata"}})
+	assert.Len(t, ct.groupResults(results[1:3]), 1)
+}
+
+func TestPrintDebugInfoOnFailure(t *testing.T) {
+	type testData struct {
+		name           string
+		debugOnFailure bool
+		err            error
+		expectedCalls  int
+	}
+
+	testCases := []testData{
+		{"enabled with error", true, errors.New("install failed"), 1},
+		{"enabled without error", true, nil, 0},
+		{"disabled with error", false, errors.New("install failed"), 0},
+	}
+
+	for _, testData := range testCases {
+		t.Run(testData.name, func(t *testing.T) {
+			fakeMockKubectl := new(fakeKubectl)
+			fakeMockKubectl.On("GetAll", mock.Anything)
+			fakeMockKubectl.On("GetNonReadyPods", mock.Anything)
+			fakeMockKubectl.On("DescribePod", mock.Anything, mock.Anything)
+
+			ct := newTestingMock(config.Configuration{DebugOnFailure: testData.debugOnFailure})
+			ct.kubectl = fakeMockKubectl
+
+			err := testData.err
+			ct.printDebugInfoOnFailure("foo", &err)
+			fakeMockKubectl.AssertNumberOfCalls(t, "GetAll", testData.expectedCalls)
+			fakeMockKubectl.AssertNumberOfCalls(t, "DescribePod", testData.expectedCalls)
+		})
+	}
+}
+
+type fakeDetailsKubectl struct {
+	*fakeKubectl
+	mutex      sync.Mutex
+	running    int
+	maxRunning int
+}
+
+func (k *fakeDetailsKubectl) DescribePod(namespace string, pod string) (string, error) {
+	k.mutex.Lock()
+	k.running++
+	if k.running > k.maxRunning {
+		k.maxRunning = k.running
+	}
+	k.mutex.Unlock()
+
+	// Make earlier pods take longer, so they would be finished last without ordering
+	delay, _ := strconv.Atoi(strings.TrimPrefix(pod, "pod-"))
+	time.Sleep(time.Duration(10-delay) * 5 * time.Millisecond)
+
+	k.mutex.Lock()
+	k.running--
+	k.mutex.Unlock()
+	return "description of " + pod, nil
+}
+func (k *fakeDetailsKubectl) Logs(namespace string, pod string, container string, tail int) (string, error) {
+	if tail > 0 {
+		return fmt.Sprintf("last %d lines of logs of %s/%s", tail, pod, container), nil
+	}
+	return "logs of " + pod + "/" + container, nil
+}
+func (k *fakeDetailsKubectl) GetContainers(namespace string, pod string) ([]string, error) {
+	return []string{"main", "sidecar"}, nil
+}
+func (k *fakeDetailsKubectl) GetFailedContainers(namespace string, pod string) ([]string, error) {
+	return []string{"sidecar"}, nil
+}
+
+func TestFetchPodDetailsAndLogs(t *testing.T) {
+	defer func(parallelism int) { podDetailsParallelism = parallelism }(podDetailsParallelism)
+	podDetailsParallelism = 3
+
+	fakeDetailsKubectl := &fakeDetailsKubectl{fakeKubectl: new(fakeKubectl)}
+	ct := newTestingMock(config.Configuration{})
+	ct.kubectl = fakeDetailsKubectl
+
+	var pods []string
+	for i := 0; i < 10; i++ {
+		pods = append(pods, fmt.Sprintf("pod-%d", i))
+	}
+
+	var blocks []string
+	ct.fetchPodDetailsAndLogs("foo", pods, func(details string) {
+		blocks = append(blocks, details)
+	})
+
+	assert.Len(t, blocks, len(pods))
+	for i, block := range blocks {
+		description := strings.Index(block, "description of "+pods[i])
+		mainLogs := strings.Index(block, "logs of "+pods[i]+"/main")
+		sidecarLogs := strings.Index(block, "logs of "+pods[i]+"/sidecar")
+		assert.True(t, description >= 0 && description < mainLogs && mainLogs < sidecarLogs, "unexpected block for '%s': %s", pods[i], block)
+		assert.Equal(t, 1, strings.Count(block, "==> Description of pod"))
+	}
+	assert.True(t, fakeDetailsKubectl.maxRunning > 1, "pods not fetched concurrently")
+	assert.True(t, fakeDetailsKubectl.maxRunning <= 3, "more pods fetched concurrently than allowed: %d", fakeDetailsKubectl.maxRunning)
+}
+
+func TestPodDetailsAndLogsFailedContainersOnly(t *testing.T) {
+	ct := newTestingMock(config.Configuration{LogsFailedContainersOnly: true, LogsTail: 100})
+	ct.kubectl = &fakeDetailsKubectl{fakeKubectl: new(fakeKubectl)}
+
+	details := ct.podDetailsAndLogs("foo", "pod-9")
+	assert.Contains(t, details, "last 100 lines of logs of pod-9/sidecar")
+	assert.NotContains(t, details, "pod-9/main")
+}
+
+func TestValidateRequiredAnnotations(t *testing.T) {
+	chart, err := NewChart("testdata/deprecated_with_replacement")
+	assert.Nil(t, err)
+
+	ct := newTestingMock(config.Configuration{RequiredAnnotations: []string{"ct.helm.sh/replacement"}})
+	assert.Nil(t, ct.ValidateRequiredAnnotations(chart))
+
+	ct = newTestingMock(config.Configuration{RequiredAnnotations: []string{"ct.helm.sh/replacement", "artifacthub.io/changes", "team"}})
+	assert.EqualError(t, ct.ValidateRequiredAnnotations(chart), "2 errors occurred:\n"+
+		"\t* Chart.yaml is missing required annotation 'artifacthub.io/changes'\n"+
+		"\t* Chart.yaml is missing required annotation 'team'\n\n")
+
+	result := ct.LintChart(chart)
+	assert.Equal(t, "lint", ErrorType(result.Error))
+}
+
+func TestValidateChartYaml(t *testing.T) {
+	var testDataSlice = []struct {
+		name     string
+		chartDir string
+		expected bool
+	}{
+		{"valid", "testdata/test_lints", true},
+		{"missing-required-fields", "testdata/missing_required_fields", false},
+		{"v1-dependencies", "testdata/v1_dependencies", false},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			chart, err := NewChart(testData.chartDir)
+			assert.Nil(t, err)
+			validationErr := ct.ValidateChartYaml(chart)
+			assert.Equal(t, testData.expected, validationErr == nil)
+		})
+	}
+}
+
+func TestCheckVersionIncrementNewChartMinVersion(t *testing.T) {
+	var testDataSlice = []struct {
+		name       string
+		minVersion string
+		expected   bool
+	}{
+		{"no-min-version", "", true},
+		{"above-min-version", "0.1.0", true},
+		{"equal-to-min-version", "1.2.3", true},
+		{"below-min-version", "2.0.0", false},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			ct := newTestingMock(config.Configuration{NewChartMinVersion: testData.minVersion})
+			chart, err := NewChart("testdata/test_lints")
+			assert.Nil(t, err)
+			err = ct.CheckVersionIncrement(chart)
+			assert.Equal(t, testData.expected, err == nil)
+		})
+	}
+}
+
+type fakeOldChartYamlGit struct {
+	fakeGit
+	chartYaml string
+}
+
+func (g fakeOldChartYamlGit) FileExistsOnBranch(file string, remote string, branch string) bool {
+	return g.chartYaml != ""
+}
+
+func (g fakeOldChartYamlGit) Show(file string, remote string, branch string) (string, error) {
+	return g.chartYaml, nil
+}
+
+func TestCheckVersionIncrementPrerelease(t *testing.T) {
+	var testDataSlice = []struct {
+		chartPath            string
+		oldVersion           string
+		allowPrereleaseBumps bool
+		expected             bool
+	}{
+		{"testdata/prerelease_version", "1.2.3-rc.1", true, true},
+		{"testdata/prerelease_version", "1.2.3-rc.1", false, false},
+		{"testdata/prerelease_version", "1.2.3-rc.10", true, false},
+		{"testdata/prerelease_version", "1.2.2", false, true},
+		{"testdata/prerelease_version", "1.2.3", true, false},
+		{"testdata/prerelease_version", "1.2.3-rc.2+build.1", true, false},
+		{"testdata/test_lints", "1.2.3-rc.2", false, true},
+	}
+
+	for _, testData := range testDataSlice {
+		chart, err := NewChart(testData.chartPath)
+		assert.Nil(t, err)
+
+		name := fmt.Sprintf("%s => %s (allow prerelease bumps: %t)", testData.oldVersion, chart.Yaml().Version, testData.allowPrereleaseBumps)
+		t.Run(name, func(t *testing.T) {
+			ct := newTestingMock(config.Configuration{AllowPrereleaseBumps: testData.allowPrereleaseBumps})
+			ct.git = fakeOldChartYamlGit{chartYaml: "name: " + chart.Yaml().Name + "\nversion: " + testData.oldVersion + "\n"}
+			err := ct.CheckVersionIncrement(chart)
+			assert.Equal(t, testData.expected, err == nil)
+		})
+	}
+}
+
+func TestCheckAppVersionIncrement(t *testing.T) {
+	chart, err := NewChart("testdata/test_lints")
+	assert.Nil(t, err)
+
+	var testDataSlice = []struct {
+		name         string
+		oldChartYaml string
+		changedFiles []string
+		expected     bool
+	}{
+		{"changed-files-unknown", "name: test_lints\nversion: 1.2.3\n", nil, true},
+		{"no-template-changes", "name: test_lints\nversion: 1.2.3\n", []string{"testdata/test_lints/values.yaml"}, true},
+		{"new-chart", "", []string{"testdata/test_lints/templates/configmap.yaml"}, true},
+		{"app-version-changed", "name: test_lints\nversion: 1.2.3\nappVersion: 0.9.0\n", []string{"testdata/test_lints/templates/configmap.yaml"}, true},
+		{"app-version-unchanged", "name: test_lints\nversion: 1.2.3\nappVersion: xoxo\n", []string{"testdata/test_lints/templates/configmap.yaml"}, false},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			ct := newTestingMock(config.Configuration{})
+			ct.git = fakeOldChartYamlGit{chartYaml: testData.oldChartYaml}
+			if testData.changedFiles != nil {
+				ct.changedChartFiles = map[string][]string{"testdata/test_lints": testData.changedFiles}
+			}
+			err := ct.CheckAppVersionIncrement(chart)
+			assert.Equal(t, testData.expected, err == nil)
+		})
+	}
+}
+
+func TestProcessChartsResultCallback(t *testing.T) {
+	ct := newTestingMock(config.Configuration{
+		Charts: []string{"testdata/test_lints", "testdata/valid_maintainers"},
+	})
+
+	var callbackResults []TestResult
+	ct.ResultCallback = func(result TestResult) {
+		callbackResults = append(callbackResults, result)
+	}
+
+	results, err := ct.processCharts(func(chart *Chart) TestResult {
+		return TestResult{Chart: chart}
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, results, callbackResults)
+}
+
+func TestChartYamlSchema(t *testing.T) {
+	ct := newTestingMock(config.Configuration{
+		ChartYamlSchema:  "chart_schema.yaml",
+		ChartYamlSchemas: map[string]string{"v2": "v2_schema.yaml"},
+	})
+
+	v1Chart, err := NewChart("testdata/test_lints")
+	assert.Nil(t, err)
+	assert.Equal(t, "chart_schema.yaml", ct.chartYamlSchema(v1Chart))
+
+	v2Chart, err := NewChart("testdata/library_chart")
+	assert.Nil(t, err)
+	assert.Equal(t, "v2_schema.yaml", ct.chartYamlSchema(v2Chart))
+}
+
+func TestLintConf(t *testing.T) {
+	var testDataSlice = []struct {
+		name      string
+		lintConfs map[string]string
+		file      string
+		expected  string
+	}{
+		{"single lint config", nil, "foo/values.yaml", "lintconf.yaml"},
+		{"no matching pattern", map[string]string{"Chart.yaml": "chart.yaml"}, "foo/values.yaml", "lintconf.yaml"},
+		{"matching pattern", map[string]string{"Chart.yaml": "chart.yaml"}, "foo/Chart.yaml", "chart.yaml"},
+		{"fallback pattern", map[string]string{"*": "relaxed.yaml", "*-values.yaml": "strict.yaml"}, "foo/Chart.yaml", "relaxed.yaml"},
+		{"longest pattern wins", map[string]string{"*": "relaxed.yaml", "*-values.yaml": "strict.yaml"}, "foo/ci/test-values.yaml", "strict.yaml"},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			ct := newTestingMock(config.Configuration{LintConf: "lintconf.yaml", LintConfs: testData.lintConfs})
+			assert.Equal(t, testData.expected, ct.lintConf(testData.file))
+		})
+	}
+}
+
+func TestSummarizeResults(t *testing.T) {
+	results := []TestResult{
+		{Status: StatusPassed},
+		{},
+		{Error: errors.New("failed")},
+		{Status: StatusSkipped, SkipReason: "library charts are not installable"},
+	}
+	summary := summarizeResults(results)
+	assert.Equal(t, ResultSummary{Passed: 2, Failed: 1, Skipped: 1, Total: 4}, summary)
+	assert.Equal(t, "Passed: 2, Failed: 1, Skipped: 1 (total 4)", summary.String())
+}
+
+type fakeInvalidHelm struct {
+	fakeHelm
+}
+
+func (h fakeInvalidHelm) Validate() error {
+	return errors.New("post-renderer 'foo' is neither an executable nor an installed Helm plugin")
+}
+
+func TestProcessChartsInvalidHelm(t *testing.T) {
+	ct := newTestingMock(config.Configuration{Charts: []string{"testdata/test_lints"}})
+	ct.helm = fakeInvalidHelm{}
+
+	processed := false
+	_, err := ct.processCharts(func(chart *Chart) TestResult {
+		processed = true
+		return TestResult{Chart: chart}
+	})
+	assert.EqualError(t, err, "Invalid Helm environment: post-renderer 'foo' is neither an executable nor an installed Helm plugin")
+	assert.False(t, processed)
+}
+
+func TestProcessChartsFailOnNoCharts(t *testing.T) {
+	action := func(chart *Chart) TestResult {
+		return TestResult{Chart: chart}
+	}
+
+	emptyDir, err := ioutil.TempDir("", "empty-chart-dir")
+	assert.Nil(t, err)
+	defer os.RemoveAll(emptyDir)
+
+	ct := newTestingMock(config.Configuration{ChartDirs: []string{emptyDir}, ProcessAllCharts: true})
+	results, err := ct.processCharts(action)
+	assert.Nil(t, err)
+	assert.Empty(t, results)
+
+	ct = newTestingMock(config.Configuration{ChartDirs: []string{emptyDir}, ProcessAllCharts: true, FailOnNoCharts: true})
+	results, err = ct.processCharts(action)
+	assert.NotNil(t, err)
+	assert.Empty(t, results)
+}
+
+func TestProcessChartsFailFast(t *testing.T) {
+	cfg := config.Configuration{
+		Charts: []string{"testdata/test_lints", "testdata/valid_maintainers", "testdata/library_chart"},
+	}
+	action := func(chart *Chart) TestResult {
+		if chart.Path() == "testdata/valid_maintainers" {
+			return TestResult{Chart: chart, Error: errors.New("failed")}
+		}
+		return TestResult{Chart: chart}
+	}
+
+	ct := newTestingMock(cfg)
+	results, err := ct.processCharts(action)
+	assert.EqualError(t, err, "Error processing charts")
+	assert.Len(t, results, 3)
+
+	cfg.FailFast = true
+	ct = newTestingMock(cfg)
+	results, err = ct.processCharts(action)
+	assert.EqualError(t, err, "Error processing charts")
+	assert.Len(t, results, 2)
+	assert.Equal(t, StatusFailed, results[1].Status)
+}
+
+func TestProcessChartsMaxCharts(t *testing.T) {
+	cfg := config.Configuration{
+		Charts:    []string{"testdata/test_lints", "testdata/valid_maintainers", "testdata/library_chart"},
+		MaxCharts: 2,
+	}
+	action := func(chart *Chart) TestResult {
+		return TestResult{Chart: chart}
+	}
+
+	ct := newTestingMock(cfg)
+	results, err := ct.processCharts(action)
+	assert.EqualError(t, err, "Found 3 charts to process, which exceeds the limit of 2 charts set by '--max-charts'")
+	assert.Empty(t, results)
+
+	cfg.MaxCharts = 3
+	ct = newTestingMock(cfg)
+	results, err = ct.processCharts(action)
+	assert.Nil(t, err)
+	assert.Len(t, results, 3)
+}
+
+type fakeRepoHelm struct {
+	fakeHelm
+	repos *[]string
+}
+
+func (h fakeRepoHelm) AddRepo(name, url string, extraArgs []string) error {
+	*h.repos = append(*h.repos, fmt.Sprintf("%s=%s %s", name, url, strings.Join(extraArgs, " ")))
+	return nil
+}
+
+func TestAddRepos(t *testing.T) {
+	cfg := config.Configuration{
+		ChartRepos:        []string{"stable=https://charts.example.com", "private=https://private.example.com"},
+		HelmRepoExtraArgs: []string{"private=--username user"},
+	}
+
+	var repos []string
+	ct := newTestingMock(cfg)
+	ct.helm = fakeRepoHelm{repos: &repos}
+	assert.Nil(t, ct.addRepos())
+	assert.Equal(t, []string{"stable=https://charts.example.com ", "private=https://private.example.com --username user"}, repos)
+
+	repos = nil
+	cfg.SkipRepoAdd = true
+	ct = newTestingMock(cfg)
+	ct.helm = fakeRepoHelm{repos: &repos}
+	assert.Nil(t, ct.addRepos())
+	assert.Empty(t, repos)
+}
+
+func TestProcessChartsStatus(t *testing.T) {
+	ct := newTestingMock(config.Configuration{
+		Charts: []string{"testdata/test_lints", "testdata/valid_maintainers", "testdata/library_chart"},
+	})
+
+	results, err := ct.processCharts(func(chart *Chart) TestResult {
+		switch chart.Path() {
+		case "testdata/valid_maintainers":
+			return TestResult{Chart: chart, Error: errors.New("failed")}
+		case "testdata/library_chart":
+			return ct.InstallChart(chart)
+		default:
+			return TestResult{Chart: chart}
+		}
+	})
+	assert.NotNil(t, err)
+	assert.Len(t, results, 3)
+	assert.Equal(t, StatusPassed, results[0].Status)
+	assert.Equal(t, StatusFailed, results[1].Status)
+	assert.Equal(t, StatusSkipped, results[2].Status)
+	assert.Equal(t, "library charts are not installable", results[2].SkipReason)
+}
+
+func TestComputeChangedChartDirectoriesFromFile(t *testing.T) {
+	file, err := ioutil.TempFile("", "changed-files")
+	assert.Nil(t, err)
+	defer os.Remove(file.Name())
+
+	_, err = file.WriteString("test_charts/foo/Chart.yaml\n\ntest_charts\\foo\\values.yaml\ntest_charts\\bar\\bar_sub\\templates\\bar_sub.yaml\nsome_non_chart_dir/some_non_chart_file\n")
+	assert.Nil(t, err)
+	assert.Nil(t, file.Close())
+
+	ct := newTestingMock(config.Configuration{
+		ChartDirs:        []string{"test_charts"},
+		ChangedFilesFrom: file.Name(),
+	})
+	actual, err := ct.ComputeChangedChartDirectories()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"test_charts/foo", "test_charts/bar"}, actual)
+	assert.Equal(t, []string{"test_charts/foo/Chart.yaml", "test_charts/foo/values.yaml"}, ct.changedChartFiles["test_charts/foo"])
+	assert.Equal(t, []string{"Chart.yaml", "values.yaml"}, ct.ChangedFiles("test_charts/foo"))
+	assert.Equal(t, "", ct.ExplainChanges("test_charts/foo"))
+
+	ct.config.ExplainChanges = true
+	assert.Equal(t, " (changed: Chart.yaml, values.yaml)", ct.ExplainChanges("test_charts/foo"))
+	assert.Equal(t, " (changed: bar_sub/templates/bar_sub.yaml)", ct.ExplainChanges("test_charts/bar"))
+	assert.Equal(t, "", ct.ExplainChanges("test_charts/must-pass-upgrade-install"))
+}
+
+func TestComputeChangedChartDirectoriesStrict(t *testing.T) {
+	dir, err := ioutil.TempDir("", "strict-change-detection")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	chartsDir := filepath.Join(dir, "charts")
+	assert.Nil(t, os.MkdirAll(filepath.Join(chartsDir, "broken", "templates"), 0755))
+	assert.Nil(t, os.MkdirAll(filepath.Join(chartsDir, "valid"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(chartsDir, "valid", "Chart.yaml"), []byte("name: valid\nversion: 0.1.0\n"), 0644))
+
+	changedFiles := []string{
+		filepath.Join(chartsDir, "valid", "values.yaml"),
+		filepath.Join(chartsDir, "README.md"),
+		filepath.Join(chartsDir, "deleted", "Chart.yaml"),
+		filepath.Join(chartsDir, "broken", "templates", "configmap.yaml"),
+	}
+	file := filepath.Join(dir, "changed-files")
+	assert.Nil(t, ioutil.WriteFile(file, []byte(strings.Join(changedFiles, "\n")), 0644))
+
+	cfg := config.Configuration{ChartDirs: []string{chartsDir}, ChangedFilesFrom: file}
+	ct := newTestingMock(cfg)
+	actual, err := ct.ComputeChangedChartDirectories()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join(chartsDir, "valid")}, actual)
+
+	cfg.StrictChangeDetection = true
+	ct = newTestingMock(cfg)
+	_, err = ct.ComputeChangedChartDirectories()
+	assert.EqualError(t, err, fmt.Sprintf("Changed files in directories which are not valid chart directories: %s",
+		filepath.Join(chartsDir, "broken", "templates")))
+}
+
+func TestComputeChangedChartDirectoriesChangedPaths(t *testing.T) {
+	file, err := ioutil.TempFile("", "changed-files")
+	assert.Nil(t, err)
+	defer os.Remove(file.Name())
+
+	_, err = file.WriteString("test_charts/foo/Chart.yaml\ntest_charts/bar/Chart.yaml\ntest_charts/must-pass-upgrade-install/Chart.yaml\n")
+	assert.Nil(t, err)
+	assert.Nil(t, file.Close())
+
+	var testDataSlice = []struct {
+		name           string
+		changedPaths   []string
+		excludedCharts []string
+		expected       []string
+	}{
+		{"all paths", nil, nil, []string{"test_charts/foo", "test_charts/bar", "test_charts/must-pass-upgrade-install"}},
+		{"single path", []string{"test_charts/foo"}, nil, []string{"test_charts/foo"}},
+		{"multiple paths", []string{"test_charts/foo/", "test_charts/bar"}, nil, []string{"test_charts/foo", "test_charts/bar"}},
+		{"excluded chart within path", []string{"test_charts/foo", "test_charts/bar"}, []string{"bar"}, []string{"test_charts/foo"}},
+		{"no changes within path", []string{"other_charts"}, nil, nil},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			ct := newTestingMock(config.Configuration{
+				ChartDirs:        []string{"test_charts"},
+				ChangedFilesFrom: file.Name(),
+				ChangedPaths:     testData.changedPaths,
+				ExcludedCharts:   testData.excludedCharts,
+			})
+			actual, err := ct.ComputeChangedChartDirectories()
+			assert.Nil(t, err)
+			assert.Equal(t, testData.expected, actual)
+		})
+	}
+}
+
+func TestOnlyCIOrDocsChanged(t *testing.T) {
+	var testDataSlice = []struct {
+		name         string
+		changedFiles []string
+		expected     bool
+	}{
+		{"no-changes-computed", nil, false},
+		{"ci-values-only", []string{"testdata/test_lints/ci/test-values.yaml"}, true},
+		{"ci-values-and-docs", []string{"testdata/test_lints/ci/test-values.yaml", "testdata/test_lints/README.md", "testdata/test_lints/docs/usage.txt"}, true},
+		{"chart-changes", []string{"testdata/test_lints/ci/test-values.yaml", "testdata/test_lints/values.yaml"}, false},
+		{"template-changes", []string{"testdata/test_lints/templates/ci/configmap.yaml"}, false},
+	}
+
+	chart, err := NewChart("testdata/test_lints")
+	assert.Nil(t, err)
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			ct := newTestingMock(config.Configuration{})
+			if testData.changedFiles != nil {
+				ct.changedChartFiles = map[string][]string{"testdata/test_lints": testData.changedFiles}
+			}
+			assert.Equal(t, testData.expected, ct.onlyCIOrDocsChanged(chart))
+		})
+	}
+}
+
+func TestValidateDependencyVersions(t *testing.T) {
+	var testDataSlice = []struct {
+		name     string
+		chartDir string
+		expected bool
+	}{
+		{"no-dependencies", "testdata/test_lints", true},
+		{"matching-version", "testdata/dependency_version_match", true},
+		{"mismatching-version", "testdata/dependency_version_mismatch", false},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			chart, err := NewChart(testData.chartDir)
+			assert.Nil(t, err)
+			validationErr := ct.ValidateDependencyVersions(chart)
+			assert.Equal(t, testData.expected, validationErr == nil)
+		})
+	}
+}
+
+func TestValidatePinnedDependencies(t *testing.T) {
+	var testDataSlice = []struct {
+		name     string
+		chartDir string
+		expected string
+	}{
+		{"no-dependencies", "testdata/test_lints", ""},
+		{"pinned", "testdata/ci_values_keys", ""},
+		{"local", "testdata/dependents/umbrella", ""},
+		{"floating", "testdata/dependency_version_match", "1 error occurred:\n\t* Dependency 'foo' has version '~0.1.0', which is not an exact version\n\n"},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			chart, err := NewChart(testData.chartDir)
+			assert.Nil(t, err)
+			validationErr := ct.ValidatePinnedDependencies(chart)
+			if testData.expected == "" {
+				assert.Nil(t, validationErr)
+			} else {
+				assert.EqualError(t, validationErr, testData.expected)
+			}
+		})
+	}
+
+	for _, version := range []string{"1.2", "1.x", ">=1.2.0", "^1.2.3", "1.2.3 - 1.4.0", ""} {
+		assert.False(t, pinnedVersionPattern.MatchString(version), version)
+	}
+	for _, version := range []string{"1.2.3", "v1.2.3", "1.2.3-rc.1", "1.2.3+build.5"} {
+		assert.True(t, pinnedVersionPattern.MatchString(version), version)
+	}
+}
+
+func TestRemote(t *testing.T) {
+	var testDataSlice = []struct {
+		name     string
+		git      Git
+		expected string
+	}{
+		{"branch-on-configured-remote", fakeGit{}, "origin"},
+		{"branch-on-other-remote", fakeForkGit{}, "upstream"},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			ct := newTestingMock(config.Configuration{Remote: "origin", TargetBranch: "master"})
+			ct.git = testData.git
+			assert.Equal(t, testData.expected, ct.remote())
+		})
+	}
+}
+
+func TestInstallHooks(t *testing.T) {
+	var testDataSlice = []struct {
+		name      string
+		scriptErr error
+		expected  bool
+	}{
+		{"pre-install-succeeds", nil, true},
+		{"pre-install-fails", errors.New("exit status 1"), false},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			fakeMockScriptRunner := new(fakeScriptRunner)
+			fakeMockScriptRunner.On("Run", "testdata/install_hooks/ci/pre-install.sh", mock.Anything).Return(testData.scriptErr)
+
+			ct := newTestingMock(config.Configuration{})
+			ct.scriptRunner = fakeMockScriptRunner
+			chart, err := NewChart("testdata/install_hooks")
+			assert.Nil(t, err)
+
+			err = ct.doInstall(chart)
+			assert.Equal(t, testData.expected, err == nil)
+			fakeMockScriptRunner.AssertNumberOfCalls(t, "Run", 1)
+		})
+	}
+}
+
+func TestInstallImagePullSecret(t *testing.T) {
+	var testDataSlice = []struct {
+		name      string
+		secret    string
+		secretErr error
+		calls     int
+		expected  bool
+	}{
+		{"no-secret", "", nil, 0, true},
+		{"secret-created", "regcred=docker-config.json", nil, 1, true},
+		{"secret-fails", "regcred=docker-config.json", errors.New("exit status 1"), 1, false},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			fakeMockKubectl := new(fakeKubectl)
+			fakeMockKubectl.On("CreateDockerSecret", mock.Anything, "regcred", "docker-config.json").Return(testData.secretErr)
+
+			ct := newTestingMock(config.Configuration{ImagePullSecret: testData.secret})
+			ct.kubectl = fakeMockKubectl
+			chart, err := NewChart("testdata/test_lints")
+			assert.Nil(t, err)
+
+			err = ct.doInstall(chart)
+			assert.Equal(t, testData.expected, err == nil)
+			fakeMockKubectl.AssertNumberOfCalls(t, "CreateDockerSecret", testData.calls)
+		})
+	}
+}
+
+func TestInstallServiceAccount(t *testing.T) {
+	var testDataSlice = []struct {
+		name         string
+		cfg          config.Configuration
+		bindErr      error
+		createCalls  int
+		bindingCalls int
+		expected     bool
+	}{
+		{"no-service-account", config.Configuration{}, nil, 0, 0, true},
+		{"service-account", config.Configuration{ServiceAccount: "tester"}, nil, 1, 0, true},
+		{"service-account-with-role", config.Configuration{ServiceAccount: "tester", ServiceAccountClusterRole: "view"}, nil, 1, 1, true},
+		{"binding-fails", config.Configuration{ServiceAccount: "tester", ServiceAccountClusterRole: "view"}, errors.New("exit status 1"), 1, 1, false},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			fakeMockKubectl := new(fakeKubectl)
+			fakeMockKubectl.On("CreateServiceAccount", mock.Anything, "tester").Return(nil)
+			fakeMockKubectl.On("BindClusterRole", mock.Anything, "tester", "view").Return(testData.bindErr)
+
+			ct := newTestingMock(testData.cfg)
+			ct.kubectl = fakeMockKubectl
+			chart, err := NewChart("testdata/test_lints")
+			assert.Nil(t, err)
+
+			err = ct.doInstall(chart)
+			assert.Equal(t, testData.expected, err == nil)
+			fakeMockKubectl.AssertNumberOfCalls(t, "CreateServiceAccount", testData.createCalls)
+			fakeMockKubectl.AssertNumberOfCalls(t, "BindClusterRole", testData.bindingCalls)
+		})
+	}
+}
+
+type fakeParallelInstallHelm struct {
+	fakeHelm
+	mutex      *sync.Mutex
+	namespaces map[string]bool
+	running    *int
+	maxRunning *int
+}
+
+func (h fakeParallelInstallHelm) InstallWithValues(chart string, valuesFile string, namespace string, release string) error {
+	h.mutex.Lock()
+	h.namespaces[namespace] = true
+	*h.running++
+	if *h.running > *h.maxRunning {
+		*h.maxRunning = *h.running
+	}
+	h.mutex.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	h.mutex.Lock()
+	*h.running--
+	h.mutex.Unlock()
+
+	if strings.HasSuffix(valuesFile, "other-values.yaml") {
+		return errors.New("install failed")
+	}
+	return nil
+}
+
+func TestInstallValuesFilesInParallel(t *testing.T) {
+	chart, err := NewChart("testdata/values_files_order")
+	assert.Nil(t, err)
+
+	var testDataSlice = []struct {
+		name        string
+		parallelism int
+		namespace   string
+		maxRunning  int
+		err         string
+	}{
+		{"sequential", 1, "", 1, "install failed"},
+		{"parallel", 2, "", 2, "1 error occurred:\n\t* Error installing chart with values file 'testdata/values_files_order/ci/other-values.yaml': install failed\n\n"},
+		{"fixed namespace", 2, "default", 1, "install failed"},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			var running, maxRunning int
+			namespaces := map[string]bool{}
+			ct := newTestingMock(config.Configuration{ValuesFilesParallelism: testData.parallelism, Namespace: testData.namespace})
+			ct.helm = fakeParallelInstallHelm{
+				mutex:      new(sync.Mutex),
+				namespaces: namespaces,
+				running:    &running,
+				maxRunning: &maxRunning,
+			}
+
+			err := ct.doInstall(chart)
+			assert.EqualError(t, err, testData.err)
+			assert.Equal(t, testData.maxRunning, maxRunning)
+			if testData.parallelism > 1 && testData.namespace == "" {
+				assert.Len(t, namespaces, len(chart.ValuesFilePathsForCI()))
+			}
+		})
+	}
+}
+
+type fakeFlakyHelm struct {
+	fakeHelm
+	failures int
+	tests    *int
+}
+
+func (h fakeFlakyHelm) Test(namespace string, release string, filters []string) error {
+	*h.tests++
+	if *h.tests <= h.failures {
+		return errors.New("test failed")
+	}
+	return nil
+}
+
+func TestTestReleaseRetries(t *testing.T) {
+	defer func(backoff time.Duration) { testRetryBackoff = backoff }(testRetryBackoff)
+	testRetryBackoff = 0
+
+	var testDataSlice = []struct {
+		name          string
+		retries       int
+		failures      int
+		expectedTests int
+		expected      bool
+	}{
+		{"no-retries", 0, 1, 1, false},
+		{"fails-once", 1, 1, 2, true},
+		{"passes-first", 3, 0, 1, true},
+		{"retries-exhausted", 2, 5, 3, false},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			tests := 0
+			ct := newTestingMock(config.Configuration{TestRetries: testData.retries})
+			ct.helm = fakeFlakyHelm{failures: testData.failures, tests: &tests}
+
+			err := ct.testRelease("foo", "release", "")
+			assert.Equal(t, testData.expected, err == nil)
+			assert.Equal(t, testData.expectedTests, tests)
+		})
+	}
+}
+
+type fakeConcurrentHelm struct {
+	fakeHelm
+	mutex       *sync.Mutex
+	builds      *[]string
+	running     *int
+	maxRunning  *int
+	buildFailed bool
+}
+
+func (h fakeConcurrentHelm) BuildDependencies(chart string) error {
+	h.mutex.Lock()
+	*h.builds = append(*h.builds, chart)
+	*h.running++
+	if *h.running > *h.maxRunning {
+		*h.maxRunning = *h.running
+	}
+	h.mutex.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	h.mutex.Lock()
+	*h.running--
+	h.mutex.Unlock()
+
+	if h.buildFailed {
+		return errors.New("build failed")
+	}
+	return nil
+}
+
+func TestBuildPreviousRevisionDependencies(t *testing.T) {
+	var charts []*Chart
+	for _, dir := range []string{"test_charts/foo", "test_charts/bar", "testdata/valid_maintainers", "testdata/fixed_namespace"} {
+		chart, err := NewChart(dir)
+		assert.Nil(t, err)
+		charts = append(charts, chart)
+	}
+
+	var testDataSlice = []struct {
+		name        string
+		parallelism int
+		maxRunning  int
+		buildFailed bool
+	}{
+		{"serial by default", 0, 1, false},
+		{"bounded", 2, 2, false},
+		{"errors are not fatal", 4, 4, true},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			var builds []string
+			var running, maxRunning int
+			ct := newTestingMock(config.Configuration{DependencyBuildParallelism: testData.parallelism})
+			ct.helm = fakeConcurrentHelm{
+				mutex:       new(sync.Mutex),
+				builds:      &builds,
+				running:     &running,
+				maxRunning:  &maxRunning,
+				buildFailed: testData.buildFailed,
+			}
+
+			ct.buildPreviousRevisionDependencies(charts)
+
+			assert.Len(t, builds, len(charts))
+			assert.Equal(t, testData.maxRunning, maxRunning)
+		})
+	}
+}
+
+func TestProcessChartsInstallDependencyUpdate(t *testing.T) {
+	runTest := func(dependencyUpdate bool, expectedBuilds []string) {
+		var builds []string
+		var running, maxRunning int
+		ct := newTestingMock(config.Configuration{
+			Charts:                  []string{"testdata/dependencies_without_lock", "testdata/dependencies_with_lock"},
+			InstallDependencyUpdate: dependencyUpdate,
+		})
+		ct.helm = fakeConcurrentHelm{mutex: new(sync.Mutex), builds: &builds, running: &running, maxRunning: &maxRunning}
+
+		results, err := ct.processCharts(func(chart *Chart) TestResult {
+			return TestResult{Chart: chart}
+		})
+		assert.Nil(t, err)
+		assert.Len(t, results, 2)
+		assert.Equal(t, expectedBuilds, builds)
+	}
+
+	runTest(false, []string{"testdata/dependencies_without_lock", "testdata/dependencies_with_lock"})
+	runTest(true, []string{"testdata/dependencies_with_lock"})
+}
+
+type fakePullingHelm struct {
+	fakeHelm
+	pulls *[]string
+}
+
+func (h fakePullingHelm) Pull(chart string, version string, destination string) error {
+	*h.pulls = append(*h.pulls, chart+"@"+version)
+	dir := filepath.Join(destination, filepath.Base(chart))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte("name: "+filepath.Base(chart)+"\nversion: 1.0.0\n"), 0644)
+}
+
+func TestProcessChartsRemoteCharts(t *testing.T) {
+	var pulls []string
+	ct := newTestingMock(config.Configuration{
+		RemoteCharts: []string{"stable/foo:1.0.0", "other/foo"},
+	})
+	ct.helm = fakePullingHelm{pulls: &pulls}
+
+	var dirs []string
+	results, err := ct.processCharts(func(chart *Chart) TestResult {
+		dirs = append(dirs, chart.Path())
+		_, err := os.Stat(filepath.Join(chart.Path(), "Chart.yaml"))
+		assert.Nil(t, err)
+		return TestResult{Chart: chart}
+	})
+	assert.Nil(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, []string{"stable/foo@1.0.0", "other/foo@"}, pulls)
+	assert.Equal(t, "foo", results[0].Chart.Yaml().Name)
+	assert.NotEqual(t, dirs[0], dirs[1])
+
+	for _, dir := range dirs {
+		_, err := os.Stat(dir)
+		assert.True(t, os.IsNotExist(err), "temporary directory '%s' not removed", dir)
+	}
+}
+
+type fakeRecordingKubectl struct {
+	*fakeKubectl
+	waits             int
+	namespace         string
+	selector          string
+	createdNamespaces []string
+	deletedNamespaces []string
+}
+
+func (k *fakeRecordingKubectl) CreateNamespace(namespace string) error {
+	k.createdNamespaces = append(k.createdNamespaces, namespace)
+	return nil
+}
+
+func (k *fakeRecordingKubectl) DeleteNamespace(namespace string) {
+	k.deletedNamespaces = append(k.deletedNamespaces, namespace)
+}
+
+func (k *fakeRecordingKubectl) WaitForDeployments(namespace string, selector string) error {
+	k.waits++
+	k.namespace = namespace
+	k.selector = selector
+	return nil
+}
+
+type fakePodsKubectl struct {
+	*fakeKubectl
+	pods      []string
+	selectors []string
+}
+
+func (k *fakePodsKubectl) GetPods(args ...string) ([]string, error) {
+	for i, arg := range args {
+		if arg == "--selector" && i+1 < len(args) {
+			k.selectors = append(k.selectors, args[i+1])
+		}
+	}
+	return k.pods, nil
+}
+
+func TestReleaseSelector(t *testing.T) {
+	ct := newTestingMock(config.Configuration{ReleaseLabel: "app.kubernetes.io/instance"})
+
+	chart, err := NewChart("testdata/test_lints")
+	assert.Nil(t, err)
+	assert.Equal(t, "app.kubernetes.io/instance=my-release", ct.releaseSelector(chart, "my-release"))
+
+	chart, err = NewChart("testdata/custom_release_label")
+	assert.Nil(t, err)
+	assert.Equal(t, "release=my-release", ct.releaseSelector(chart, "my-release"))
+}
+
+func TestTestReleaseSelectorWarning(t *testing.T) {
+	kubectl := &fakePodsKubectl{fakeKubectl: new(fakeKubectl)}
+	ct := newTestingMock(config.Configuration{})
+	ct.kubectl = kubectl
+
+	assert.Nil(t, ct.testRelease("foo", "release", ""))
+	assert.Empty(t, kubectl.selectors)
+
+	assert.Nil(t, ct.testRelease("foo", "release", "release=release"))
+	assert.Equal(t, []string{"release=release"}, kubectl.selectors)
+}
+
+func TestTestReleaseHelmWait(t *testing.T) {
+	runTest := func(helmWait bool, expectedWaits int) {
+		kubectl := &fakeRecordingKubectl{fakeKubectl: new(fakeKubectl)}
+		ct := newTestingMock(config.Configuration{HelmWait: helmWait})
+		ct.kubectl = kubectl
+
+		assert.Nil(t, ct.testRelease("foo", "release", ""))
+		assert.Equal(t, expectedWaits, kubectl.waits)
+	}
+
+	runTest(false, 1)
+	runTest(true, 0)
+}
+
+func TestTestReleaseWaitForAutoscaling(t *testing.T) {
+	kubectl := new(fakeKubectl)
+	kubectl.On("WaitForAutoscaling", "foo", "release=release").Return(errors.New("horizontalpodautoscaler/foo not ready"))
+	ct := newTestingMock(config.Configuration{HelmWait: true})
+	ct.kubectl = kubectl
+
+	assert.Nil(t, ct.testRelease("foo", "release", "release=release"))
+	kubectl.AssertNotCalled(t, "WaitForAutoscaling", "foo", "release=release")
+
+	ct.config.WaitForAutoscaling = true
+	assert.EqualError(t, ct.testRelease("foo", "release", "release=release"), "horizontalpodautoscaler/foo not ready")
+}
+
+func TestInstallChartRendersNothing(t *testing.T) {
+	chart, err := NewChart("testdata/umbrella_chart")
+	assert.Nil(t, err)
+
+	var testDataSlice = []struct {
+		name      string
+		manifests string
+		skipped   bool
+	}{
+		{"no-manifests", "", true},
+		{"only-comments", "---\n# Source: umbrella/charts/common/templates/_helpers.tpl\n", true},
+		{"resources", "---\n# Source: umbrella/charts/common/templates/configmap.yaml\napiVersion: v1\nkind: ConfigMap\n", false},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			kubectl := &fakeRecordingKubectl{fakeKubectl: new(fakeKubectl)}
+			ct := newTestingMock(config.Configuration{})
+			ct.helm = fakeTemplateHelm{manifests: map[string]string{"": testData.manifests}}
+			ct.kubectl = kubectl
+
+			result := ct.InstallChart(chart).withStatus()
+			if testData.skipped {
+				assert.Equal(t, StatusSkipped, result.Status)
+				assert.Equal(t, "nothing to install, the chart renders no resources", result.SkipReason)
+				assert.Empty(t, kubectl.createdNamespaces)
+			} else {
+				assert.Equal(t, StatusPassed, result.Status)
+				assert.Len(t, kubectl.createdNamespaces, 1)
+			}
+		})
+	}
+}
+
+func TestInstallChartWithTemplatesNotRendered(t *testing.T) {
+	chart, err := NewChart("testdata/yaml_lint_all_files")
+	assert.Nil(t, err)
+
+	kubectl := &fakeRecordingKubectl{fakeKubectl: new(fakeKubectl)}
+	ct := newTestingMock(config.Configuration{})
+	ct.helm = fakeTemplateHelm{manifests: map[string]string{}}
+	ct.kubectl = kubectl
+
+	assert.False(t, ct.rendersNothing(chart))
+	result := ct.InstallChart(chart).withStatus()
+	assert.Equal(t, StatusPassed, result.Status)
+	assert.Len(t, kubectl.createdNamespaces, 1)
+}
+
+func TestInstallChartUpgradeOnlyKeepsUpgradeResult(t *testing.T) {
+	chart, err := NewChart("testdata/yaml_lint_all_files")
+	assert.Nil(t, err)
+
+	kubectl := &fakeRecordingKubectl{fakeKubectl: new(fakeKubectl)}
+	ct := newTestingMock(config.Configuration{Upgrade: true, UpgradeOnly: true})
+	ct.helm = fakeTemplateHelm{manifests: map[string]string{}}
+	ct.kubectl = kubectl
+	ct.git = fakeOldChartYamlGit{}
+
+	result := ct.InstallChart(chart).withStatus()
+	assert.Equal(t, StatusSkipped, result.Status)
+	assert.Equal(t, "chart has no previous revision", result.SkipReason)
+}
+
+type fakeWorktreeGit struct {
+	fakeGit
+	added   *string
+	removed *string
+}
+
+func (g fakeWorktreeGit) AddWorktree(path string, ref string) error {
+	*g.added = path
+	return nil
+}
+
+func (g fakeWorktreeGit) RemoveWorktree(path string) error {
+	*g.removed = path
+	return nil
+}
+
+func TestAddPreviousRevisionWorktree(t *testing.T) {
+	root := t.TempDir()
+	var added, removed string
+	ct := newTestingMock(config.Configuration{RepositoryRoot: root})
+	ct.git = fakeWorktreeGit{added: &added, removed: &removed}
+
+	removeWorktree, err := ct.addPreviousRevisionWorktree("main")
+	assert.Nil(t, err)
+	assert.True(t, filepath.IsAbs(added))
+	assert.Equal(t, root, filepath.Dir(added))
+	assert.DirExists(t, added)
+	assert.Equal(t, filepath.Join(added, "charts", "foo"), ct.computePreviousRevisionPath(filepath.Join(root, "charts", "foo")))
+
+	removeWorktree()
+	assert.Equal(t, added, removed)
+	assert.NoDirExists(t, added)
+}
+
+func TestApplyCRDs(t *testing.T) {
+	crdFiles := []string{"testdata/crds_chart/crds/gadgets.yaml"}
+	chart, err := NewChart("testdata/crds_chart")
+	assert.Nil(t, err)
+	chartWithoutCRDs, err := NewChart("testdata/test_lints")
+	assert.Nil(t, err)
+
+	t.Run("established", func(t *testing.T) {
+		kubectl := new(fakeKubectl)
+		kubectl.On("ApplyManifests", crdFiles).Return(nil)
+		kubectl.On("WaitForCondition", "Established", crdFiles).Return(nil)
+		ct := newTestingMock(config.Configuration{})
+		ct.kubectl = kubectl
+
+		assert.Nil(t, ct.applyCRDs(chart))
+		kubectl.AssertExpectations(t)
+	})
+
+	t.Run("not established", func(t *testing.T) {
+		kubectl := new(fakeKubectl)
+		kubectl.On("ApplyManifests", crdFiles).Return(nil)
+		kubectl.On("WaitForCondition", "Established", crdFiles).Return(errors.New("timed out waiting for the condition"))
+		ct := newTestingMock(config.Configuration{})
+		ct.kubectl = kubectl
+
+		err := ct.applyCRDs(chart)
+		assert.EqualError(t, err, `CRDs of chart 'crds-chart => (version: "0.1.0", path: "testdata/crds_chart")' did not become established: timed out waiting for the condition`)
+	})
+
+	t.Run("no CRDs", func(t *testing.T) {
+		kubectl := new(fakeKubectl)
+		ct := newTestingMock(config.Configuration{})
+		ct.kubectl = kubectl
+
+		assert.Nil(t, ct.applyCRDs(chartWithoutCRDs))
+		kubectl.AssertNotCalled(t, "ApplyManifests", mock.Anything)
+	})
+}
+
+func TestInstallChartExistingRelease(t *testing.T) {
+	kubectl := &fakeRecordingKubectl{fakeKubectl: new(fakeKubectl)}
+	ct := newTestingMock(config.Configuration{TestExistingRelease: "prod/my-release", ReleaseLabel: "app.kubernetes.io/instance"})
+	ct.kubectl = kubectl
+	chart, err := NewChart("testdata/test_lints")
+	assert.Nil(t, err)
+
+	result := ct.InstallChart(chart)
+	assert.Nil(t, result.Error)
+	assert.Equal(t, 1, kubectl.waits)
+	assert.Equal(t, "prod", kubectl.namespace)
+	assert.Equal(t, "app.kubernetes.io/instance=my-release", kubectl.selector)
+}
+
+func TestInstallChartFixedNamespace(t *testing.T) {
+	var testDataSlice = []struct {
+		name              string
+		chartDir          string
+		namespace         string
+		expectedNamespace string
+		ownNamespace      bool
+	}{
+		{"own-namespace", "testdata/test_lints", "", "", true},
+		{"configured-namespace", "testdata/test_lints", "default", "default", false},
+		{"annotated-namespace", "testdata/fixed_namespace", "", "kube-system", false},
+		{"annotation-precedence", "testdata/fixed_namespace", "default", "kube-system", false},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			kubectl := &fakeRecordingKubectl{fakeKubectl: new(fakeKubectl)}
+			ct := newTestingMock(config.Configuration{Namespace: testData.namespace})
+			ct.kubectl = kubectl
+			chart, err := NewChart(testData.chartDir)
+			assert.Nil(t, err)
+
+			assert.Nil(t, ct.doInstall(chart))
+			if testData.ownNamespace {
+				assert.Len(t, kubectl.createdNamespaces, 1)
+				assert.Equal(t, kubectl.createdNamespaces, kubectl.deletedNamespaces)
+			} else {
+				assert.Equal(t, testData.expectedNamespace, kubectl.namespace)
+				assert.Empty(t, kubectl.createdNamespaces)
+				assert.Empty(t, kubectl.deletedNamespaces)
+			}
+		})
+	}
+}
+
+func TestFixedUpgradeNamespace(t *testing.T) {
+	chart, err := NewChart("testdata/fixed_namespace")
+	assert.Nil(t, err)
+	annotatedNamespace := chart.Yaml().Annotations[namespaceAnnotation]
+
+	ct := newTestingMock(config.Configuration{Namespace: "default"})
+	assert.Equal(t, annotatedNamespace, ct.fixedUpgradeNamespace(chart))
+
+	ct = newTestingMock(config.Configuration{Namespace: "default", UpgradeSeparateNamespace: true})
+	assert.Equal(t, "", ct.fixedUpgradeNamespace(chart))
+	assert.Equal(t, annotatedNamespace, ct.fixedNamespace(chart))
+}
+
+type fakeLintOutputHelm struct {
+	fakeHelm
+	output string
+	err    error
+}
+
+func (h fakeLintOutputHelm) LintWithValuesAndCaptureOutput(chart string, valuesFile string) (string, error) {
+	return h.output, h.err
+}
+
+type fakeResolveHelm struct {
+	fakeHelm
+	resolved *[]string
+}
+
+func (h fakeResolveHelm) ResolveChart(chart string, repository string, version string) error {
+	*h.resolved = append(*h.resolved, strings.TrimSpace(chart+" "+repository+" "+version))
+	if strings.HasSuffix(chart, "missing") {
+		return errors.New("chart \"missing\" version \"1.0.0\" not found in https://charts.example.com repository")
+	}
+	return nil
+}
+
+func TestResolveDependencies(t *testing.T) {
+	chart, err := NewChart("testdata/resolve_dependencies")
+	assert.Nil(t, err)
+
+	var resolved []string
+	ct := newTestingMock(config.Configuration{ChartRepos: []string{"example=https://charts.example.com/"}})
+	ct.helm = fakeResolveHelm{resolved: &resolved}
+	err = ct.ResolveDependencies([]*Chart{chart})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Dependency 'removed'")
+	assert.Contains(t, err.Error(), "Dependency 'missing'")
+	assert.NotContains(t, err.Error(), "Dependency 'common'")
+	assert.NotContains(t, err.Error(), "Dependency 'library-chart'")
+	assert.Equal(t, []string{
+		"example/common  ~1.0.0",
+		"example/missing  1.0.0",
+		"oci://registry.example.com/charts/redis  17.0.0",
+		"bitnami/postgresql  12.0.0",
+		"mysql https://other.example.com 9.0.0",
+	}, resolved)
+}
+
+func TestValidateDefaultsAgainstSchema(t *testing.T) {
+	chart, err := NewChart("testdata/ci_values_keys")
+	assert.Nil(t, err)
+
+	ct := newTestingMock(config.Configuration{})
+	ct.helm = fakeLintOutputHelm{output: "[INFO] Chart.yaml: icon is recommended\n[ERROR] templates/: template: foo/templates/deployment.yaml:1:3: image.repository is required", err: errors.New("exit status 1")}
+	assert.Nil(t, ct.ValidateDefaultsAgainstSchema(chart))
+
+	ct.helm = fakeLintOutputHelm{output: "Error: unable to load chart", err: errors.New("exit status 1")}
+	err = ct.ValidateDefaultsAgainstSchema(chart)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Error linting chart with its default values: Error: unable to load chart")
+
+	ct.helm = fakeLintOutputHelm{output: "[ERROR] values.yaml: - replicas: Invalid type. Expected: integer, given: string"}
+	err = ct.ValidateDefaultsAgainstSchema(chart)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "replicas: Invalid type. Expected: integer, given: string")
+
+	chart, err = NewChart("testdata/test_lints")
+	assert.Nil(t, err)
+	assert.Nil(t, ct.ValidateDefaultsAgainstSchema(chart))
+}
+
+func TestLintInfoAsError(t *testing.T) {
+	chart, err := NewChart("testdata/test_lints")
+	assert.Nil(t, err)
+
+	var testDataSlice = []struct {
+		name            string
+		lintInfoAsError bool
+		output          string
+		expected        string
+	}{
+		{"disabled", false, "==> Linting charts/foo\n[INFO] Chart.yaml: icon is recommended\n\n1 chart(s) linted, 0 chart(s) failed", ""},
+		{"no recommendations", true, "==> Linting charts/foo\n\n1 chart(s) linted, 0 chart(s) failed", ""},
+		{"recommendations", true, "==> Linting charts/foo\n[INFO] Chart.yaml: icon is recommended\n[WARNING] templates/: directory not found\n[INFO] values.yaml: file does not exist\n\n1 chart(s) linted, 0 chart(s) failed",
+			"'helm lint' reported INFO recommendations, which are treated as errors: Chart.yaml: icon is recommended; values.yaml: file does not exist"},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			ct := newTestingMock(config.Configuration{LintInfoAsError: testData.lintInfoAsError})
+			ct.helm = fakeLintOutputHelm{output: testData.output}
+
+			_, err := ct.lintWithValues(chart, "")
+			if testData.expected == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, testData.expected)
+			}
+		})
+	}
+}
+
+func TestLintChartErrorType(t *testing.T) {
+	chart, err := NewChart("testdata/test_lints")
+	assert.Nil(t, err)
+
+	ct := newTestingMock(config.Configuration{LintInfoAsError: true})
+	ct.helm = fakeLintOutputHelm{output: "[INFO] Chart.yaml: icon is recommended"}
+
+	result := ct.LintChart(chart)
+	var lintError *LintError
+	assert.True(t, errors.As(result.Error, &lintError))
+	assert.EqualError(t, result.Error, "'helm lint' reported INFO recommendations, which are treated as errors: Chart.yaml: icon is recommended")
+	assert.Equal(t, "lint", ErrorType(result.Error))
+}
+
+func TestLintChartFindings(t *testing.T) {
+	chart, err := NewChart("testdata/test_lints")
+	assert.Nil(t, err)
+
+	ct := newTestingMock(config.Configuration{QuietLint: true})
+	ct.helm = fakeLintOutputHelm{output: "==> Linting charts/foo\n[INFO] Chart.yaml: icon is recommended\n[WARNING] templates/deployment.yaml: object name does not conform to Kubernetes naming requirements\n[ERROR] values.yaml: unable to parse YAML\n[WARNING] chart directory is missing these dependencies: common\n\n1 chart(s) linted, 0 chart(s) failed"}
+
+	result := ct.LintChart(chart)
+	assert.Nil(t, result.Error)
+	assert.Equal(t, []LintFinding{
+		{Code: "chartfile", Severity: "INFO", Message: "icon is recommended", Path: "Chart.yaml"},
+		{Code: "templates", Severity: "WARNING", Message: "object name does not conform to Kubernetes naming requirements", Path: "templates/deployment.yaml"},
+		{Code: "values", Severity: "ERROR", Message: "unable to parse YAML", Path: "values.yaml"},
+		{Code: "other", Severity: "WARNING", Message: "chart directory is missing these dependencies: common"},
+	}, result.LintFindings)
+
+	actual, err := json.Marshal(TestResult{Chart: chart, LintFindings: result.LintFindings[:1]})
+	assert.Nil(t, err)
+	assert.Contains(t, string(actual), `"lintFindings":[{"code":"chartfile","severity":"INFO","message":"icon is recommended","path":"Chart.yaml"}]`)
+}
+
+type fakePluginHelm struct {
+	fakeHelm
+	plugins *[]string
+}
+
+func (h fakePluginHelm) RunPlugin(plugin string, chart string) error {
+	*h.plugins = append(*h.plugins, plugin+" "+chart)
+	if plugin == "schema" {
+		return errors.New("schema validation failed")
+	}
+	return nil
+}
+
+func TestLintChartPlugins(t *testing.T) {
+	chart, err := NewChart("testdata/test_lints")
+	assert.Nil(t, err)
+
+	var testDataSlice = []struct {
+		name     string
+		plugins  []string
+		expected []string
+		err      string
+	}{
+		{"no plugins", nil, nil, ""},
+		{"passing plugins", []string{"unittest --strict", "lint-docs"}, []string{"unittest --strict testdata/test_lints", "lint-docs testdata/test_lints"}, ""},
+		{"failing plugin", []string{"schema", "unittest"}, []string{"schema testdata/test_lints"}, "Helm plugin 'schema' failed: schema validation failed"},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			var plugins []string
+			ct := newTestingMock(config.Configuration{LintPlugins: testData.plugins})
+			ct.helm = fakePluginHelm{plugins: &plugins}
+
+			result := ct.LintChart(chart)
+			assert.Equal(t, testData.expected, plugins)
+			if testData.err == "" {
+				assert.Nil(t, result.Error)
+			} else {
+				assert.EqualError(t, result.Error, testData.err)
+				assert.Equal(t, "lint", ErrorType(result.Error))
+			}
+		})
+	}
+}
+
+type fakeUnitTestHelm struct {
+	fakeHelm
+	err error
+}
+
+func (h fakeUnitTestHelm) UnitTest(chart string) error {
+	return h.err
+}
+
+func TestLintChartUnitTests(t *testing.T) {
+	chart, err := NewChart("testdata/test_lints")
+	assert.Nil(t, err)
+
+	unitTestErr := errors.New("1 unit test suite(s) failed: test deployment (testdata/test_lints/tests/deployment_test.yaml)")
+
+	ct := newTestingMock(config.Configuration{})
+	ct.helm = fakeUnitTestHelm{err: unitTestErr}
+	assert.Nil(t, ct.LintChart(chart).Error)
+
+	ct = newTestingMock(config.Configuration{RunUnitTests: true})
+	ct.helm = fakeUnitTestHelm{}
+	assert.Nil(t, ct.LintChart(chart).Error)
+
+	ct.helm = fakeUnitTestHelm{err: unitTestErr}
+	result := ct.LintChart(chart)
+	assert.EqualError(t, result.Error, unitTestErr.Error())
+	assert.Equal(t, "lint", ErrorType(result.Error))
+}
+
+func TestLintChartExpectLintFailure(t *testing.T) {
+	chart, err := NewChart("testdata/expect_lint_failure")
+	assert.Nil(t, err)
+
+	var testDataSlice = []struct {
+		name          string
+		output        string
+		expectedError string
+		expectedLint  string
+	}{
+		{"lint failure", "[INFO] Chart.yaml: icon is recommended", "", "'helm lint' reported INFO recommendations, which are treated as errors: Chart.yaml: icon is recommended"},
+		{"lint success", "1 chart(s) linted, 0 chart(s) failed", "chart linted successfully, but is expected to fail linting due to the 'ct.helm.sh/expect-lint-failure' annotation", ""},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			ct := newTestingMock(config.Configuration{LintInfoAsError: true})
+			ct.helm = fakeLintOutputHelm{output: testData.output}
+
+			result := ct.LintChart(chart)
+			if testData.expectedError == "" {
+				assert.Nil(t, result.Error)
+				assert.Equal(t, StatusPassed, result.withStatus().Status)
+			} else {
+				assert.EqualError(t, result.Error, testData.expectedError)
+				assert.Equal(t, "lint", ErrorType(result.Error))
+			}
+			if testData.expectedLint == "" {
+				assert.Nil(t, result.ExpectedError)
+			} else {
+				assert.EqualError(t, result.ExpectedError, testData.expectedLint)
+			}
+		})
+	}
+}
+
+type fakeInstallRecordingHelm struct {
+	fakeLintOutputHelm
+	installed *bool
+}
+
+func (h fakeInstallRecordingHelm) InstallWithValues(chart string, valuesFile string, namespace string, release string) error {
+	*h.installed = true
+	return nil
+}
+
+func TestLintAndInstallChartExpectLintFailure(t *testing.T) {
+	chart, err := NewChart("testdata/expect_lint_failure")
+	assert.Nil(t, err)
+
+	installed := false
+	ct := newTestingMock(config.Configuration{LintInfoAsError: true})
+	ct.helm = fakeInstallRecordingHelm{fakeLintOutputHelm: fakeLintOutputHelm{output: "[INFO] Chart.yaml: icon is recommended"}, installed: &installed}
+
+	result := ct.LintAndInstallChart(chart)
+	assert.Nil(t, result.Error)
+	assert.NotNil(t, result.ExpectedError)
+	assert.Equal(t, StatusPassed, result.withStatus().Status)
+	assert.False(t, installed)
+}
+
+func TestInstallValuesFilesForCI(t *testing.T) {
+	chart, err := NewChart("testdata/values_files_order")
+	assert.Nil(t, err)
+	allValuesFiles := chart.ValuesFilePathsForCI()
+
+	var testDataSlice = []struct {
+		name                   string
+		onlyChangedValuesFiles bool
+		changedFiles           []string
+		expected               []string
+	}{
+		{"disabled", false, []string{"testdata/values_files_order/ci/other-values.yaml"}, allValuesFiles},
+		{"not changed", true, nil, allValuesFiles},
+		{"only values files changed", true,
+			[]string{"testdata/values_files_order/ci/other-values.yaml", "testdata/values_files_order/ci/default-values.yaml"},
+			[]string{"testdata/values_files_order/ci/default-values.yaml", "testdata/values_files_order/ci/other-values.yaml"}},
+		{"chart changed", true,
+			[]string{"testdata/values_files_order/ci/other-values.yaml", "testdata/values_files_order/values.yaml"},
+			allValuesFiles},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			ct := newTestingMock(config.Configuration{OnlyChangedValuesFiles: testData.onlyChangedValuesFiles})
+			if testData.changedFiles != nil {
+				ct.changedChartFiles = map[string][]string{"testdata/values_files_order": testData.changedFiles}
+			}
+			assert.Equal(t, testData.expected, ct.installValuesFilesForCI(chart))
+		})
+	}
+}
+
+func TestInstallValuesFilesForCIValuesBaseDir(t *testing.T) {
+	chart, err := NewChart("testdata/values_files_order")
+	assert.Nil(t, err)
+
+	ct := newTestingMock(config.Configuration{OnlyChangedValuesFiles: true, ValuesBaseDir: "testdata/values_base_dir"})
+	ct.changedChartFiles = map[string][]string{"testdata/values_files_order": {
+		"testdata/values_files_order/ci/default-values.yaml",
+		"testdata/values_files_order/ci/other-values.yaml",
+	}}
+	assert.Equal(t, []string{
+		"testdata/values_base_dir/values_files_order/default-values.yaml",
+		"testdata/values_files_order/ci/other-values.yaml",
+	}, ct.installValuesFilesForCI(chart))
+}
+
+func TestNotifyWebhook(t *testing.T) {
+	passed, err := NewChart("testdata/valid_maintainers")
+	assert.Nil(t, err)
+	failed, err := NewChart("testdata/test_lints")
+	assert.Nil(t, err)
+	results := []TestResult{{Chart: passed}, {Chart: failed, Error: &LintError{Err: errors.New("lint failed")}}}
+
+	var payloads []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		payload := map[string]interface{}{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&payload))
+		payloads = append(payloads, payload)
+	}))
+	defer server.Close()
+
+	ct := newTestingMock(config.Configuration{NotifyWebhook: server.URL})
+	ct.NotifyWebhook(results[:1], nil)
+	assert.Empty(t, payloads)
+
+	ct.NotifyWebhook(results, errors.New("Error processing charts"))
+	assert.Len(t, payloads, 1)
+	assert.Equal(t, false, payloads[0]["success"])
+	assert.Equal(t, []interface{}{"invalid"}, payloads[0]["failedCharts"])
+	assert.Equal(t, "Error processing charts", payloads[0]["error"])
+	assert.Equal(t, "chart-testing failed. Passed: 1, Failed: 1, Skipped: 0 (total 2). Failed charts: invalid", payloads[0]["text"])
+	assert.Equal(t, map[string]interface{}{"passed": 1.0, "failed": 1.0, "skipped": 0.0, "total": 2.0}, payloads[0]["summary"])
+	assert.Len(t, payloads[0]["results"], 2)
+
+	ct = newTestingMock(config.Configuration{NotifyWebhook: server.URL, NotifyWebhookAlways: true})
+	ct.NotifyWebhook(results[:1], nil)
+	assert.Len(t, payloads, 2)
+	assert.Equal(t, true, payloads[1]["success"])
+	assert.Equal(t, []interface{}{}, payloads[1]["failedCharts"])
+
+	// Failing webhooks are only logged
+	server.Close()
+	ct.NotifyWebhook(results, errors.New("Error processing charts"))
+	assert.Len(t, payloads, 2)
+}
+
+func TestWriteResultsFile(t *testing.T) {
+	passed, err := NewChart("testdata/valid_maintainers")
+	assert.Nil(t, err)
+	failed, err := NewChart("testdata/test_lints")
+	assert.Nil(t, err)
+	results := []TestResult{{Chart: passed}, {Chart: failed, Error: &LintError{Err: errors.New("lint failed")}}}
+
+	resultsFile := filepath.Join(t.TempDir(), "results.json")
+	ct := newTestingMock(config.Configuration{})
+	assert.Nil(t, ct.WriteResultsFile(results, errors.New("Error processing charts")))
+	assert.NoFileExists(t, resultsFile)
+
+	ct = newTestingMock(config.Configuration{ResultsFile: resultsFile})
+	assert.Nil(t, ct.WriteResultsFile(results, errors.New("Error processing charts")))
+	content, err := ioutil.ReadFile(resultsFile)
+	assert.Nil(t, err)
+	payload := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(content, &payload))
+	assert.Equal(t, false, payload["success"])
+	assert.Equal(t, "chart-testing failed. Passed: 1, Failed: 1, Skipped: 0 (total 2). Failed charts: invalid", payload["text"])
+	assert.Len(t, payload["results"], 2)
+
+	failedCharts, err := ReadFailedCharts(resultsFile)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"testdata/test_lints"}, failedCharts)
+
+	// Results are written on success, too
+	assert.Nil(t, ct.WriteResultsFile(results[:1], nil))
+	failedCharts, err = ReadFailedCharts(resultsFile)
+	assert.Nil(t, err)
+	assert.Empty(t, failedCharts)
+
+	ct = newTestingMock(config.Configuration{ResultsFile: filepath.Join(resultsFile, "results.json")})
+	err = ct.WriteResultsFile(results, nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Error writing results file")
+}
+
+func TestWriteResultsFileDetails(t *testing.T) {
+	lintChart, err := NewChart("testdata/test_lints")
+	assert.Nil(t, err)
+	fooChart, err := NewChart("test_charts/foo")
+	assert.Nil(t, err)
+	results := []TestResult{
+		{Chart: lintChart, Error: &LintError{Err: errors.New("lint failed")}, LintFindings: []LintFinding{
+			{Code: "values", Severity: "ERROR", Message: "key 'replicas' is required", Path: "values.yaml"},
+		}},
+		skippedResult(fooChart, "library charts are not installable"),
+	}
+
+	resultsFile := filepath.Join(t.TempDir(), "results.json")
+	ct := newTestingMock(config.Configuration{ResultsFile: resultsFile, ChartDirs: []string{"testdata", "test_charts"}})
+	assert.Nil(t, ct.WriteResultsFile(results, errors.New("Error processing charts")))
+
+	content, err := ioutil.ReadFile(resultsFile)
+	assert.Nil(t, err)
+	var payload struct {
+		Results []map[string]interface{} `json:"results"`
+		Groups  []struct {
+			ChartDir string                   `json:"chartDir"`
+			Summary  ResultSummary            `json:"summary"`
+			Results  []map[string]interface{} `json:"results"`
+		} `json:"groups"`
+	}
+	assert.Nil(t, json.Unmarshal(content, &payload))
+
+	assert.Len(t, payload.Results, 2)
+	assert.Equal(t, "Failed", payload.Results[0]["status"])
+	assert.Equal(t, "lint", payload.Results[0]["errorType"])
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"code": "values", "severity": "ERROR", "message": "key 'replicas' is required", "path": "values.yaml",
+	}}, payload.Results[0]["lintFindings"])
+	assert.Equal(t, "Skipped", payload.Results[1]["status"])
+	assert.Equal(t, "library charts are not installable", payload.Results[1]["skipReason"])
+
+	assert.Len(t, payload.Groups, 2)
+	assert.Equal(t, "testdata", payload.Groups[0].ChartDir)
+	assert.Equal(t, ResultSummary{Failed: 1, Total: 1}, payload.Groups[0].Summary)
+	assert.Equal(t, "test_charts", payload.Groups[1].ChartDir)
+	assert.Equal(t, ResultSummary{Skipped: 1, Total: 1}, payload.Groups[1].Summary)
+	assert.Equal(t, "test_charts/foo", payload.Groups[1].Results[0]["chart"])
+}
+
+func TestErrorType(t *testing.T) {
+	var testDataSlice = []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{"nil", nil, ""},
+		{"lint", &LintError{Err: errors.New("lint")}, "lint"},
+		{"install", &InstallError{Err: errors.New("install")}, "install"},
+		{"upgrade", &UpgradeError{Err: errors.New("upgrade")}, "upgrade"},
+		{"version", &VersionError{Err: errors.New("version")}, "version"},
+		{"wrapped", errors.Wrap(&InstallError{Err: errors.New("install")}, "wrapped"), "install"},
+		{"other", errors.New("other"), "other"},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			assert.Equal(t, testData.expected, ErrorType(testData.err))
+		})
+	}
+}
+
+func TestResultMarshalJSON(t *testing.T) {
+	chart, err := NewChart("testdata/test_lints")
+	assert.Nil(t, err)
+
+	output, err := json.Marshal([]TestResult{
+		{Chart: chart},
+		{Chart: chart, Error: &VersionError{Err: errors.New("chart version not ok. Needs a version bump!")}, KubeVersion: "1.18"},
+		skippedResult(chart, "library charts are not installable"),
+	})
+	assert.Nil(t, err)
+	assert.JSONEq(t, `[
+		{"chart": "testdata/test_lints", "status": "Passed"},
+		{"chart": "testdata/test_lints", "status": "Failed", "error": "chart version not ok. Needs a version bump!", "errorType": "version", "kubeVersion": "1.18"},
+		{"chart": "testdata/test_lints", "status": "Skipped", "skipReason": "library charts are not installable"}
+	]`, string(output))
+}
+
+func TestLintYamlValidationAllFiles(t *testing.T) {
+	runTest := func(includeTemplates bool, callsYamlLint int) {
+		fakeMockLinter := new(fakeLinter)
+		fakeMockLinter.On("YamlLint", mock.Anything, mock.Anything).Return(true)
+
+		ct := newTestingMock(config.Configuration{
+			ValidateYaml:      true,
+			YamlLintAllFiles:  true,
+			YamlLintTemplates: includeTemplates,
+		})
+		ct.linter = fakeMockLinter
+
+		chart, err := NewChart("testdata/yaml_lint_all_files")
+		assert.Nil(t, err)
+		result := ct.LintChart(chart)
+		assert.Nil(t, result.Error)
+		fakeMockLinter.AssertNumberOfCalls(t, "YamlLint", callsYamlLint)
+	}
+
+	runTest(false, 3)
+	runTest(true, 4)
+}
+
+func TestValuesFilesForCI(t *testing.T) {
+	chart, err := NewChart("testdata/values_files_order")
+	assert.Nil(t, err)
+
+	lexical := []string{
+		"testdata/values_files_order/ci/00-base-values.yaml",
+		"testdata/values_files_order/ci/10-override-values.yaml",
+		"testdata/values_files_order/ci/2-extra-values.yaml",
+		"testdata/values_files_order/ci/default-values.yaml",
+		"testdata/values_files_order/ci/other-values.yaml",
+	}
+	ct := newTestingMock(config.Configuration{})
+	assert.Equal(t, lexical, ct.valuesFilesForCI(chart))
+
+	numeric := []string{
+		"testdata/values_files_order/ci/00-base-values.yaml",
+		"testdata/values_files_order/ci/2-extra-values.yaml",
+		"testdata/values_files_order/ci/10-override-values.yaml",
+		"testdata/values_files_order/ci/default-values.yaml",
+		"testdata/values_files_order/ci/other-values.yaml",
+	}
+	ct = newTestingMock(config.Configuration{ValuesFilesNumericOrder: true})
+	assert.Equal(t, numeric, ct.valuesFilesForCI(chart))
+	assert.Equal(t, lexical, chart.ValuesFilePathsForCI())
+}
+
+func TestValuesFilesForCIValuesBaseDir(t *testing.T) {
+	chart, err := NewChart("testdata/values_files_order")
+	assert.Nil(t, err)
+
+	ct := newTestingMock(config.Configuration{ValuesBaseDir: "testdata/values_base_dir"})
+	assert.Equal(t, []string{
+		"testdata/values_files_order/ci/00-base-values.yaml",
+		"testdata/values_base_dir/values_files_order/05-external-values.yaml",
+		"testdata/values_files_order/ci/10-override-values.yaml",
+		"testdata/values_files_order/ci/2-extra-values.yaml",
+		"testdata/values_base_dir/values_files_order/default-values.yaml",
+		"testdata/values_files_order/ci/other-values.yaml",
+	}, ct.valuesFilesForCI(chart))
+}
+
+func TestRenderValuesFile(t *testing.T) {
+	chart, err := NewChart("testdata/values_template")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"testdata/values_template/ci/default-values.yaml",
+		"testdata/values_template/ci/host-values.yaml.tpl",
+		"testdata/values_template/ci/invalid-values.yaml.tpl",
+	}, chart.ValuesFilePathsForCI())
+
+	ct := newTestingMock(config.Configuration{BuildId: "pr-42"})
+
+	valuesFile, remove, err := ct.renderValuesFile("testdata/values_template/ci/default-values.yaml", "foo", "bar")
+	assert.Nil(t, err)
+	assert.Equal(t, "testdata/values_template/ci/default-values.yaml", valuesFile)
+	remove()
+
+	valuesFile, remove, err = ct.renderValuesFile("testdata/values_template/ci/host-values.yaml.tpl", "foo", "bar")
+	assert.Nil(t, err)
+	content, err := ioutil.ReadFile(valuesFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "ingress:\n  host: bar.foo.example.com\nbuildId: pr-42\n", string(content))
+	remove()
+	_, err = os.Stat(valuesFile)
+	assert.True(t, os.IsNotExist(err))
+
+	_, _, err = ct.renderValuesFile("testdata/values_template/ci/invalid-values.yaml.tpl", "foo", "bar")
+	assert.NotNil(t, err)
+}
+
+func TestDownloadRemoteValuesFiles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/base-values.yaml":
+			fmt.Fprintln(w, "replicaCount: 2")
+		case "/invalid-values.yaml":
+			fmt.Fprintln(w, "- this is\nnot: a map")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	var testDataSlice = []struct {
+		name     string
+		urls     []string
+		expected bool
+	}{
+		{"valid", []string{server.URL + "/base-values.yaml"}, true},
+		{"not-found", []string{server.URL + "/base-values.yaml", server.URL + "/missing-values.yaml"}, false},
+		{"invalid-yaml", []string{server.URL + "/invalid-values.yaml"}, false},
+	}
+
+	chart, err := NewChart("testdata/test_lints")
+	assert.Nil(t, err)
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			ct := newTestingMock(config.Configuration{RemoteValuesFiles: testData.urls})
+			cleanup, err := ct.downloadRemoteValuesFiles()
+			assert.Equal(t, testData.expected, err == nil)
+			if err != nil {
+				assert.Empty(t, ct.remoteValuesFiles)
+				return
+			}
+
+			valuesFiles := ct.valuesFilesForCI(chart)
+			assert.Len(t, valuesFiles, len(chart.ValuesFilePathsForCI())+1)
+			remoteFile := valuesFiles[len(valuesFiles)-1]
+			content, err := ioutil.ReadFile(remoteFile)
+			assert.Nil(t, err)
+			assert.Equal(t, "replicaCount: 2\n", string(content))
+
+			cleanup()
+			assert.Empty(t, ct.remoteValuesFiles)
+			_, err = os.Stat(remoteFile)
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
+
+func TestEnsureBuildID(t *testing.T) {
+	ct := newTestingMock(config.Configuration{})
+	ct.ensureBuildID()
+	assert.Len(t, ct.config.BuildId, 6)
+
+	chart, err := NewChart("testdata/values_template")
+	assert.Nil(t, err)
+	release, namespace := chart.CreateInstallParams(ct.config.BuildId)
+	assert.True(t, strings.HasPrefix(release, "values_template-"+ct.config.BuildId+"-"))
+	assert.True(t, strings.HasPrefix(namespace, "values_template-"+ct.config.BuildId+"-"))
+
+	ct = newTestingMock(config.Configuration{BuildId: "pr-42"})
+	ct.ensureBuildID()
+	assert.Equal(t, "pr-42", ct.config.BuildId)
+}
+
+func TestFindDependentChartDirectories(t *testing.T) {
+	var testDataSlice = []struct {
+		name     string
+		chart    string
+		expected []string
+		err      string
+	}{
+		{"transitive dependents by name", "common", []string{"testdata/dependents/app", "testdata/dependents/umbrella"}, ""},
+		{"dependents by path", "testdata/dependents/app", []string{"testdata/dependents/umbrella"}, ""},
+		{"no dependents", "umbrella", nil, ""},
+		{"unknown chart", "unknown", nil, "Chart 'unknown' not found"},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			ct := newTestingMock(config.Configuration{ChartDirs: []string{"testdata/dependents"}})
+			actual, err := ct.FindDependentChartDirectories(testData.chart)
+			if testData.err != "" {
+				assert.EqualError(t, err, testData.err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, testData.expected, actual)
+		})
+	}
+}
+
+type matrixInstall struct {
+	chart      string
+	valuesFile string
+	namespace  string
+}
+
+type fakeMatrixHelm struct {
+	fakeHelm
+	installs *[]matrixInstall
+}
+
+func (h fakeMatrixHelm) TemplateWithValues(chart string, valuesFile string) (string, error) {
+	return "kind: ConfigMap\n", nil
+}
+
+func (h fakeMatrixHelm) InstallWithValues(chart string, valuesFile string, namespace string, release string) error {
+	*h.installs = append(*h.installs, matrixInstall{chart, valuesFile, namespace})
+	return nil
+}
+
+func TestInstallMatrix(t *testing.T) {
+	defer func(newTesting func(config.Configuration) (Testing, error)) {
+		newMatrixEntryTesting = newTesting
+	}(newMatrixEntryTesting)
+
+	var installs []matrixInstall
+	var configs []config.Configuration
+	newMatrixEntryTesting = func(cfg config.Configuration) (Testing, error) {
+		configs = append(configs, cfg)
+		ct := newTestingMock(cfg)
+		ct.helm = fakeMatrixHelm{installs: &installs}
+		return ct, nil
+	}
+
+	matrixFile := filepath.Join(t.TempDir(), "ct-matrix.yaml")
+	assert.Nil(t, ioutil.WriteFile(matrixFile, []byte(`entries:
+  - chart: testdata/test_lints
+    valuesFiles:
+      - testdata/test_lints/values.yaml
+    namespace: matrix
+    helmArgs: --timeout 600s
+  - chart: testdata/test_lints
+`), 0644))
+
+	var callbackEntries []string
+	ct := newTestingMock(config.Configuration{Matrix: matrixFile, ReleaseLabel: "app.kubernetes.io/instance", HelmExtraArgs: "--atomic"})
+	ct.ResultCallback = func(result TestResult) {
+		callbackEntries = append(callbackEntries, result.MatrixEntry)
+	}
+
+	results, err := ct.InstallMatrix()
+	assert.Nil(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, "matrix entry 1", results[0].MatrixEntry)
+	assert.Equal(t, "matrix entry 2", results[1].MatrixEntry)
+	assert.Equal(t, []string{"matrix entry 1", "matrix entry 2"}, callbackEntries)
+
+	assert.Len(t, configs, 2)
+	assert.Equal(t, []string{"testdata/test_lints"}, configs[0].Charts)
+	assert.Equal(t, "--atomic --timeout 600s", configs[0].HelmExtraArgs)
+	assert.Equal(t, "--atomic", configs[1].HelmExtraArgs)
+	assert.Equal(t, ct.config.BuildId, configs[1].BuildId)
+
+	assert.Equal(t, matrixInstall{"testdata/test_lints", "testdata/test_lints/values.yaml", "matrix"}, installs[0])
+	assert.NotEqual(t, "matrix", installs[len(installs)-1].namespace)
+}
+
+func TestReadMatrix(t *testing.T) {
+	var testDataSlice = []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"valid", "entries:\n  - chart: charts/foo\n", ""},
+		{"no entries", "entries: []\n", "has no entries"},
+		{"missing chart", "entries:\n  - namespace: foo\n", "Entry 1 of matrix file"},
+		{"unknown field", "entries:\n  - chart: charts/foo\n    values: foo.yaml\n", "Error parsing matrix file"},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			matrixFile := filepath.Join(t.TempDir(), "ct-matrix.yaml")
+			assert.Nil(t, ioutil.WriteFile(matrixFile, []byte(testData.content), 0644))
+			entries, err := ReadMatrix(matrixFile)
+			if testData.err != "" {
+				assert.Contains(t, err.Error(), testData.err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, []MatrixEntry{{Chart: "charts/foo"}}, entries)
+			}
+		})
+	}
+}
+
+type fakePruneKubectl struct {
+	*fakeKubectl
+	deleted *[]string
+}
+
+func (k fakePruneKubectl) DeleteNamespace(namespace string) {
+	*k.deleted = append(*k.deleted, namespace)
+}
+
+type fakePruneHelm struct {
+	fakeHelm
+	deleted *[]string
+}
+
+func (h fakePruneHelm) DeleteRelease(namespace string, release string) {
+	*h.deleted = append(*h.deleted, namespace+"/"+release)
+}
+
+func TestPruneNamespaces(t *testing.T) {
+	kubectl := new(fakeKubectl)
+	kubectl.On("GetNamespaces", tool.NamespaceLabel).Return(map[string]time.Time{
+		"foo-abc123": time.Now().Add(-2 * time.Hour),
+		"bar-def456": time.Now().Add(-3 * time.Hour),
+		"baz-ghi789": time.Now().Add(-10 * time.Minute),
+		"qux-jkl012": time.Now().Add(-4 * time.Hour),
+	}, nil)
+	kubectl.On("GetReleases", "foo-abc123").Return([]string{"foo-abc123"}, nil)
+	kubectl.On("GetReleases", "bar-def456").Return([]string{}, nil)
+	kubectl.On("GetReleases", "qux-jkl012").Return([]string(nil), errors.New("forbidden"))
+
+	var deletedNamespaces, deletedReleases []string
+	ct := newTestingMock(config.Configuration{PruneMinAge: time.Hour})
+	ct.kubectl = fakePruneKubectl{fakeKubectl: kubectl, deleted: &deletedNamespaces}
+	ct.helm = fakePruneHelm{deleted: &deletedReleases}
+
+	err := ct.PruneNamespaces()
+	assert.EqualError(t, err, "1 error occurred:\n\t* Error listing releases in namespace 'qux-jkl012': forbidden\n\n")
+	assert.Equal(t, []string{"bar-def456", "foo-abc123"}, deletedNamespaces)
+	assert.Equal(t, []string{"foo-abc123/foo-abc123"}, deletedReleases)
+	kubectl.AssertNotCalled(t, "GetReleases", "baz-ghi789")
+}
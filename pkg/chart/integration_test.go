@@ -39,8 +39,9 @@ func newTestingHelmIntegration(cfg config.Configuration) Testing {
 		chartUtils:       util.ChartUtils{},
 		accountValidator: fakeAccountValidator{},
 		linter:           fakeMockLinter,
-		helm:             tool.NewHelm(procExec, extraArgs),
-		kubectl:          tool.NewKubectl(procExec),
+		helm:             tool.NewHelm(procExec, tool.HelmOptions{ExtraArgs: extraArgs, WaitForJobs: cfg.WaitForJobs}),
+		kubectl:          tool.NewKubectl(procExec, nil, 0, 0, ""),
+		scriptRunner:     tool.NewScriptRunner(procExec),
 	}
 }
 
@@ -61,7 +62,7 @@ func TestInstallChart(t *testing.T) {
 				ReleaseLabel: "app.kubernetes.io/instance",
 			},
 			"test_charts/must-pass-upgrade-install",
-			TestResult{mustNewChart("test_charts/must-pass-upgrade-install"), nil},
+			TestResult{Chart: mustNewChart("test_charts/must-pass-upgrade-install")},
 		},
 		{
 			"install only in random namespace",
@@ -69,7 +70,57 @@ func TestInstallChart(t *testing.T) {
 				Debug: true,
 			},
 			"test_charts/must-pass-upgrade-install",
-			TestResult{mustNewChart("test_charts/must-pass-upgrade-install"), nil},
+			TestResult{Chart: mustNewChart("test_charts/must-pass-upgrade-install")},
+		},
+		{
+			"install and reinstall in random namespace",
+			config.Configuration{
+				Debug:         true,
+				TestReinstall: true,
+			},
+			"test_charts/must-pass-upgrade-install",
+			TestResult{Chart: mustNewChart("test_charts/must-pass-upgrade-install")},
+		},
+
+		{
+			"install chart with cluster-scoped resources",
+			config.Configuration{
+				Debug:                  true,
+				ReleaseLabel:           "app.kubernetes.io/instance",
+				DeleteClusterResources: true,
+			},
+			"test_charts/cluster-scoped-resources",
+			TestResult{Chart: mustNewChart("test_charts/cluster-scoped-resources")},
+		},
+		{
+			"install chart with cluster-scoped resources again",
+			config.Configuration{
+				Debug:                  true,
+				ReleaseLabel:           "app.kubernetes.io/instance",
+				DeleteClusterResources: true,
+			},
+			"test_charts/cluster-scoped-resources",
+			TestResult{Chart: mustNewChart("test_charts/cluster-scoped-resources")},
+		},
+
+		{
+			"install chart with CRDs and custom resources",
+			config.Configuration{
+				Debug:        true,
+				ValidateCRDs: true,
+			},
+			"test_charts/crds-and-custom-resources",
+			TestResult{Chart: mustNewChart("test_charts/crds-and-custom-resources")},
+		},
+		{
+			"install chart with migration job waiting for jobs",
+			config.Configuration{
+				Debug:       true,
+				HelmWait:    true,
+				WaitForJobs: true,
+			},
+			"test_charts/migration-job",
+			TestResult{Chart: mustNewChart("test_charts/migration-job")},
 		},
 	}
 
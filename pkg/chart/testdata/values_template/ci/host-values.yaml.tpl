@@ -0,0 +1,3 @@
+ingress:
+  host: {{ .Release }}.{{ .Namespace }}.example.com
+buildId: {{ .BuildId }}
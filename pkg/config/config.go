@@ -16,9 +16,13 @@ package config
 
 import (
 	"fmt"
-	"path"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/mitchellh/go-homedir"
 
@@ -27,39 +31,146 @@ import (
 	"github.com/spf13/cobra"
 	flag "github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
 )
 
 var (
 	homeDir, _            = homedir.Dir()
 	configSearchLocations = []string{
 		".",
-		path.Join(homeDir, ".ct"),
+		filepath.Join(homeDir, ".ct"),
 		"/etc/ct",
 	}
+	// targetBranchEnvVars are the environment variables CI systems provide the target branch of pull requests in.
+	targetBranchEnvVars = []string{
+		"GITHUB_BASE_REF",                     // GitHub Actions
+		"CI_MERGE_REQUEST_TARGET_BRANCH_NAME", // GitLab CI
+		"BITBUCKET_PR_DESTINATION_BRANCH",     // Bitbucket Pipelines
+		"SYSTEM_PULLREQUEST_TARGETBRANCH",     // Azure Pipelines
+		"BUILDKITE_PULL_REQUEST_BASE_BRANCH",  // Buildkite
+		"CHANGE_TARGET",                       // Jenkins
+	}
 )
 
+const redacted = "REDACTED"
+
 type Configuration struct {
-	Remote                string   `mapstructure:"remote"`
-	TargetBranch          string   `mapstructure:"target-branch"`
-	BuildId               string   `mapstructure:"build-id"`
-	LintConf              string   `mapstructure:"lint-conf"`
-	ChartYamlSchema       string   `mapstructure:"chart-yaml-schema"`
-	ValidateMaintainers   bool     `mapstructure:"validate-maintainers"`
-	ValidateChartSchema   bool     `mapstructure:"validate-chart-schema"`
-	ValidateYaml          bool     `mapstructure:"validate-yaml"`
-	CheckVersionIncrement bool     `mapstructure:"check-version-increment"`
-	ProcessAllCharts      bool     `mapstructure:"all"`
-	Charts                []string `mapstructure:"charts"`
-	ChartRepos            []string `mapstructure:"chart-repos"`
-	ChartDirs             []string `mapstructure:"chart-dirs"`
-	ExcludedCharts        []string `mapstructure:"excluded-charts"`
-	HelmExtraArgs         string   `mapstructure:"helm-extra-args"`
-	HelmRepoExtraArgs     []string `mapstructure:"helm-repo-extra-args"`
-	Debug                 bool     `mapstructure:"debug"`
-	Upgrade               bool     `mapstructure:"upgrade"`
-	SkipMissingValues     bool     `mapstructure:"skip-missing-values"`
-	Namespace             string   `mapstructure:"namespace"`
-	ReleaseLabel          string   `mapstructure:"release-label"`
+	RepositoryRoot                string            `mapstructure:"directory"`
+	Remote                        string            `mapstructure:"remote"`
+	TargetBranch                  string            `mapstructure:"target-branch"`
+	MergeBase                     string            `mapstructure:"merge-base"`
+	BuildId                       string            `mapstructure:"build-id"`
+	LintConf                      string            `mapstructure:"lint-conf"`
+	LintConfs                     map[string]string `mapstructure:"-"`
+	ChartYamlSchema               string            `mapstructure:"chart-yaml-schema"`
+	ChartYamlSchemas              map[string]string `mapstructure:"-"`
+	ValidateMaintainers           bool              `mapstructure:"validate-maintainers"`
+	ValidateDeprecation           bool              `mapstructure:"validate-deprecation"`
+	AllowedImageRegistries        []string          `mapstructure:"allowed-image-registries"`
+	MaxRenderedResources          int               `mapstructure:"max-rendered-resources"`
+	MaxManifestBytes              int               `mapstructure:"max-manifest-bytes"`
+	ConftestPolicies              []string          `mapstructure:"conftest-policies"`
+	LintPlugins                   []string          `mapstructure:"lint-plugins"`
+	RunUnitTests                  bool              `mapstructure:"run-unit-tests"`
+	UnitTestsPath                 string            `mapstructure:"unit-tests-path"`
+	ValidateChartSchema           bool              `mapstructure:"validate-chart-schema"`
+	ValidateYaml                  bool              `mapstructure:"validate-yaml"`
+	ValidateChartYaml             bool              `mapstructure:"validate-chart-yaml"`
+	RequiredAnnotations           []string          `mapstructure:"required-annotations"`
+	RequirePinnedDependencies     bool              `mapstructure:"require-pinned-dependencies"`
+	YamlLintAllFiles              bool              `mapstructure:"yaml-lint-all-files"`
+	YamlLintTemplates             bool              `mapstructure:"yaml-lint-templates"`
+	QuietLint                     bool              `mapstructure:"quiet-lint"`
+	LintInfoAsError               bool              `mapstructure:"lint-info-as-error"`
+	ValidateDependencyVersions    bool              `mapstructure:"validate-dependency-versions"`
+	ValidateCIValuesKeys          bool              `mapstructure:"validate-ci-values-keys"`
+	ValidateDefaultsAgainstSchema bool              `mapstructure:"validate-defaults-against-schema"`
+	AccountValidationTimeout      time.Duration     `mapstructure:"account-validation-timeout"`
+	WarnOnAccountTimeout          bool              `mapstructure:"warn-on-account-validation-timeout"`
+	CheckVersionIncrement         bool              `mapstructure:"check-version-increment"`
+	AllowPrereleaseBumps          bool              `mapstructure:"allow-prerelease-bumps"`
+	CheckAppVersionIncrement      bool              `mapstructure:"check-app-version-increment"`
+	NewChartMinVersion            string            `mapstructure:"new-chart-min-version"`
+	ProcessAllCharts              bool              `mapstructure:"all"`
+	Charts                        []string          `mapstructure:"charts"`
+	RemoteCharts                  []string          `mapstructure:"remote-charts"`
+	FailedChartsFrom              string            `mapstructure:"failed-charts-from"`
+	ResultsFile                   string            `mapstructure:"results-file"`
+	ChartRepos                    []string          `mapstructure:"chart-repos"`
+	SkipRepoAdd                   bool              `mapstructure:"skip-repo-add"`
+	ValuesBaseDir                 string            `mapstructure:"values-base-dir"`
+	ChartDirs                     []string          `mapstructure:"chart-dirs"`
+	ExcludedCharts                []string          `mapstructure:"excluded-charts"`
+	ExcludedChartPaths            []string          `mapstructure:"excluded-chart-paths"`
+	ChangedFilesFrom              string            `mapstructure:"changed-files-from"`
+	ChangedPaths                  []string          `mapstructure:"changed-paths"`
+	ExplainChanges                bool              `mapstructure:"explain-changes"`
+	StrictChangeDetection         bool              `mapstructure:"strict-change-detection"`
+	DependentsOf                  string            `mapstructure:"dependents-of"`
+	PrintConfig                   bool              `mapstructure:"print-config"`
+	NotifyWebhook                 string            `mapstructure:"notify-webhook"`
+	NotifyWebhookAlways           bool              `mapstructure:"notify-webhook-always"`
+	ListCharts                    bool              `mapstructure:"list-charts"`
+	FailOnNoCharts                bool              `mapstructure:"fail-on-no-charts"`
+	FailFast                      bool              `mapstructure:"fail-fast"`
+	MaxCharts                     int               `mapstructure:"max-charts"`
+	ListChartsFormat              string            `mapstructure:"list-charts-format"`
+	HelmExtraArgs                 string            `mapstructure:"helm-extra-args"`
+	KubectlExtraArgs              string            `mapstructure:"kubectl-extra-args"`
+	HelmRepoExtraArgs             []string          `mapstructure:"helm-repo-extra-args"`
+	HelmTestFilter                []string          `mapstructure:"helm-test-filter"`
+	TestRetries                   int               `mapstructure:"test-retries"`
+	HelmWait                      bool              `mapstructure:"helm-wait"`
+	HelmWaitTimeout               string            `mapstructure:"helm-wait-timeout"`
+	WaitForJobs                   bool              `mapstructure:"wait-for-jobs"`
+	RepositoryCache               string            `mapstructure:"repository-cache"`
+	RepositoryConfig              string            `mapstructure:"repository-config"`
+	VerifyProvenance              bool              `mapstructure:"verify-provenance"`
+	Keyring                       string            `mapstructure:"keyring"`
+	PreflightChecks               bool              `mapstructure:"preflight-checks"`
+	PreflightDependencies         bool              `mapstructure:"preflight-dependencies"`
+	MinToolVersions               []string          `mapstructure:"min-tool-versions"`
+	Debug                         bool              `mapstructure:"debug"`
+	DelimiterWidth                int               `mapstructure:"delimiter-width"`
+	ASCIIResults                  bool              `mapstructure:"ascii-results"`
+	Upgrade                       bool              `mapstructure:"upgrade"`
+	UpgradeSkipVersionBumpRule    string            `mapstructure:"upgrade-skip-version-bump-rule"`
+	UpgradeOnly                   bool              `mapstructure:"upgrade-only"`
+	UpgradeSeparateNamespace      bool              `mapstructure:"upgrade-separate-namespace"`
+	DependencyBuildParallelism    int               `mapstructure:"dependency-build-parallelism"`
+	ValuesFilesParallelism        int               `mapstructure:"values-files-parallelism"`
+	InstallDependencyUpdate       bool              `mapstructure:"install-dependency-update"`
+	IncludeDefaultValues          bool              `mapstructure:"include-default-values"`
+	SkipMissingValues             bool              `mapstructure:"skip-missing-values"`
+	ValuesFilesNumericOrder       bool              `mapstructure:"values-files-numeric-order"`
+	RemoteValuesFiles             []string          `mapstructure:"remote-values-files"`
+	OnlyChangedValuesFiles        bool              `mapstructure:"only-changed-values-files"`
+	Namespace                     string            `mapstructure:"namespace"`
+	ReleaseLabel                  string            `mapstructure:"release-label"`
+	TestExistingRelease           string            `mapstructure:"test-existing-release"`
+	NamespaceDeleteTimeout        time.Duration     `mapstructure:"namespace-delete-timeout"`
+	KubectlWaitTimeout            time.Duration     `mapstructure:"kubectl-wait-timeout"`
+	WaitExcludeSelector           string            `mapstructure:"wait-exclude-selector"`
+	WaitForAutoscaling            bool              `mapstructure:"wait-for-autoscaling"`
+	PruneMinAge                   time.Duration     `mapstructure:"prune-min-age"`
+	ImagePullSecret               string            `mapstructure:"image-pull-secret"`
+	ServiceAccount                string            `mapstructure:"service-account"`
+	ServiceAccountClusterRole     string            `mapstructure:"service-account-cluster-role"`
+	ValidateCRDs                  bool              `mapstructure:"validate-crds"`
+	CleanupPolicy                 string            `mapstructure:"cleanup-policy"`
+	DeleteClusterResources        bool              `mapstructure:"delete-cluster-resources"`
+	VerifyCleanup                 bool              `mapstructure:"verify-cleanup"`
+	FailOnLeakedResources         bool              `mapstructure:"fail-on-leaked-resources"`
+	DebugOnFailure                bool              `mapstructure:"debug-on-failure"`
+	LogsFailedContainersOnly      bool              `mapstructure:"logs-failed-containers-only"`
+	LogsTail                      int               `mapstructure:"logs-tail"`
+	TestReinstall                 bool              `mapstructure:"test-reinstall"`
+	KubeContext                   string            `mapstructure:"kube-context"`
+	CreateKindCluster             bool              `mapstructure:"create-kind-cluster"`
+	KindImage                     string            `mapstructure:"kind-image"`
+	KindConfig                    string            `mapstructure:"kind-config"`
+	KubeVersionsMatrix            []string          `mapstructure:"kube-versions-matrix"`
+	Matrix                        string            `mapstructure:"matrix"`
 }
 
 func LoadConfiguration(cfgFile string, cmd *cobra.Command, printConfig bool) (*Configuration, error) {
@@ -79,11 +190,23 @@ func LoadConfiguration(cfgFile string, cmd *cobra.Command, printConfig bool) (*C
 	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
 	v.SetEnvPrefix("CT")
 
+	// The repository's own config is looked up in the repository root, if one is specified, instead of the current
+	// working directory. Hence, the repository root cannot be set in the config file.
+	repositoryRoot := v.GetString("directory")
+	if repositoryRoot != "" {
+		absRepositoryRoot, err := filepath.Abs(repositoryRoot)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error resolving repository root")
+		}
+		repositoryRoot = absRepositoryRoot
+	}
+	searchLocations := configSearchLocationsIn(repositoryRoot)
+
 	if cfgFile != "" {
 		v.SetConfigFile(cfgFile)
 	} else {
 		v.SetConfigName("ct")
-		for _, searchLocation := range configSearchLocations {
+		for _, searchLocation := range searchLocations {
 			v.AddConfigPath(searchLocation)
 		}
 	}
@@ -93,40 +216,241 @@ func LoadConfiguration(cfgFile string, cmd *cobra.Command, printConfig bool) (*C
 			// Only error out for specified config file. Ignore for default locations.
 			return nil, errors.Wrap(err, "Error loading config file")
 		}
-	} else {
-		if printConfig {
-			fmt.Println("Using config file: ", v.ConfigFileUsed())
-		}
 	}
 
 	isLint := strings.Contains(cmd.Use, "lint")
 	isInstall := strings.Contains(cmd.Use, "install")
 
+	// The Chart.yaml schema may be specified per apiVersion, which cannot be unmarshaled into a string
+	chartYamlSchemas, err := parseChartYamlSchemas(v.Get("chart-yaml-schema"))
+	if err != nil {
+		return nil, err
+	}
+	if len(chartYamlSchemas) > 0 {
+		v.Set("chart-yaml-schema", "")
+	}
+
+	// Likewise, the lint config may be specified per file name pattern
+	lintConfs, err := parseLintConfs(v.Get("lint-conf"))
+	if err != nil {
+		return nil, err
+	}
+	if len(lintConfs) > 0 {
+		v.Set("lint-conf", "")
+	}
+
 	cfg := &Configuration{}
 	if err := v.Unmarshal(cfg); err != nil {
 		return nil, errors.Wrap(err, "Error unmarshaling configuration")
 	}
+	cfg.ChartYamlSchemas = chartYamlSchemas
+	cfg.LintConfs = lintConfs
+	cfg.RepositoryRoot = repositoryRoot
+	if repositoryRoot != "" {
+		resolveRepositoryPaths(cfg, v, cmd)
+	}
+
+	if cfg.DelimiterWidth < 0 {
+		return nil, errors.New("'--delimiter-width' must not be negative")
+	}
+
+	if cfg.MaxRenderedResources < 0 || cfg.MaxManifestBytes < 0 {
+		return nil, errors.New("'--max-rendered-resources' and '--max-manifest-bytes' must not be negative")
+	}
+
+	if cfg.MaxCharts < 0 {
+		return nil, errors.New("'--max-charts' must not be negative")
+	}
+
+	if cfg.LogsTail < 0 {
+		return nil, errors.New("'--logs-tail' must not be negative")
+	}
+	util.SetDelimiterWidth(cfg.DelimiterWidth)
+
+	// Keep stdout clean for piping when only listing charts or printing the effective configuration. Informational
+	// messages go to stderr then, and the configuration is not printed.
+	var info io.Writer = os.Stdout
+	if cfg.ListCharts || cfg.PrintConfig {
+		info = os.Stderr
+	}
+	if printConfig && v.ConfigFileUsed() != "" {
+		fmt.Fprintln(info, "Using config file: ", v.ConfigFileUsed())
+	}
+
+	// Unless explicitly set, prefer the target branch of the pull request being built over the default
+	if !v.IsSet("target-branch") {
+		if branch, envVar := detectTargetBranch(); branch != "" {
+			cfg.TargetBranch = branch
+			if printConfig {
+				fmt.Fprintf(info, "Using target branch '%s' detected from %s\n", branch, envVar)
+			}
+		}
+	}
+
+	if cfg.ListChartsFormat != "text" && cfg.ListChartsFormat != "json" && cfg.ListCharts {
+		return nil, fmt.Errorf("invalid chart list format '%s'; must be 'text' or 'json'", cfg.ListChartsFormat)
+	}
 
 	if cfg.ProcessAllCharts && len(cfg.Charts) > 0 {
 		return nil, errors.New("specifying both, '--all' and '--charts', is not allowed")
 	}
 
+	if cfg.FailedChartsFrom != "" {
+		if cfg.ProcessAllCharts || len(cfg.Charts) > 0 {
+			return nil, errors.New("specifying '--failed-charts-from' together with '--all' or '--charts' is not allowed")
+		}
+		if !util.FileExists(cfg.FailedChartsFrom) {
+			return nil, fmt.Errorf("results file '%s' does not exist", cfg.FailedChartsFrom)
+		}
+	}
+
+	if cfg.ValuesBaseDir != "" && !util.FileExists(cfg.ValuesBaseDir) {
+		return nil, fmt.Errorf("values base directory '%s' does not exist", cfg.ValuesBaseDir)
+	}
+
+	if cfg.VerifyProvenance && cfg.InstallDependencyUpdate {
+		return nil, errors.New("specifying both, '--verify-provenance' and '--install-dependency-update', is not allowed, because dependencies updated on install are not verified")
+	}
+
+	if cfg.Keyring != "" {
+		if !cfg.VerifyProvenance {
+			return nil, errors.New("specifying '--keyring' without '--verify-provenance' is not allowed")
+		}
+		if !util.FileExists(cfg.Keyring) {
+			return nil, fmt.Errorf("keyring '%s' does not exist", cfg.Keyring)
+		}
+	}
+
+	if (cfg.KindImage != "" || cfg.KindConfig != "") && !cfg.CreateKindCluster {
+		return nil, errors.New("specifying '--kind-image' or '--kind-config' without '--create-kind-cluster' is not allowed")
+	}
+
+	if cfg.CreateKindCluster && (cfg.KubeContext != "" || len(cfg.KubeVersionsMatrix) > 0) {
+		return nil, errors.New("specifying '--create-kind-cluster' together with '--kube-context' or '--kube-versions-matrix' is not allowed")
+	}
+
+	if cfg.Matrix != "" && (cfg.ProcessAllCharts || len(cfg.Charts) > 0 || len(cfg.RemoteCharts) > 0 || len(cfg.KubeVersionsMatrix) > 0) {
+		return nil, errors.New("specifying '--matrix' together with '--all', '--charts', '--remote-charts', or '--kube-versions-matrix' is not allowed")
+	}
+
 	if cfg.Namespace != "" && cfg.ReleaseLabel == "" {
 		return nil, errors.New("specifying '--namespace' without '--release-label' is not allowed")
 	}
 
+	for _, url := range cfg.RemoteValuesFiles {
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			return nil, fmt.Errorf("invalid remote values file '%s'; must be an http(s) URL", url)
+		}
+	}
+
+	if cfg.DependencyBuildParallelism < 1 {
+		cfg.DependencyBuildParallelism = 1
+	}
+	if cfg.ValuesFilesParallelism < 1 {
+		cfg.ValuesFilesParallelism = 1
+	}
+
+	if cfg.TestRetries < 0 {
+		return nil, errors.New("'--test-retries' must not be negative")
+	}
+
+	if cfg.NamespaceDeleteTimeout < 0 {
+		return nil, fmt.Errorf("invalid namespace delete timeout '%s'; must not be negative", cfg.NamespaceDeleteTimeout)
+	}
+
+	if cfg.KubectlWaitTimeout < 0 {
+		return nil, fmt.Errorf("invalid kubectl wait timeout '%s'; must not be negative", cfg.KubectlWaitTimeout)
+	}
+
+	switch cfg.UpgradeSkipVersionBumpRule {
+	case "":
+		cfg.UpgradeSkipVersionBumpRule = "major"
+	case "major", "minor", "none":
+	default:
+		return nil, fmt.Errorf("invalid upgrade skip version bump rule '%s'; must be 'major', 'minor', or 'none'", cfg.UpgradeSkipVersionBumpRule)
+	}
+
+	switch cfg.CleanupPolicy {
+	case "":
+		cfg.CleanupPolicy = "always"
+	case "always", "on-success", "on-failure", "never":
+	default:
+		return nil, fmt.Errorf("invalid cleanup policy '%s'; must be 'always', 'on-success', 'on-failure', or 'never'", cfg.CleanupPolicy)
+	}
+
+	if cfg.PruneMinAge < 0 {
+		return nil, fmt.Errorf("invalid prune minimum age '%s'; must not be negative", cfg.PruneMinAge)
+	}
+
+	if cfg.TestExistingRelease != "" && isInstall {
+		if releaseSlice := strings.SplitN(cfg.TestExistingRelease, "/", 2); len(releaseSlice) != 2 || releaseSlice[0] == "" || releaseSlice[1] == "" {
+			return nil, fmt.Errorf("invalid existing release '%s'; must be formatted as 'namespace/release'", cfg.TestExistingRelease)
+		}
+		if len(cfg.Charts) != 1 {
+			return nil, errors.New("specifying '--test-existing-release' requires exactly one chart specified with '--charts'")
+		}
+		if cfg.Upgrade {
+			return nil, errors.New("specifying both, '--test-existing-release' and '--upgrade', is not allowed")
+		}
+	}
+
+	if cfg.ServiceAccountClusterRole != "" && cfg.ServiceAccount == "" {
+		return nil, errors.New("specifying '--service-account-cluster-role' requires '--service-account'")
+	}
+
+	if cfg.HelmWaitTimeout != "" {
+		if !cfg.HelmWait {
+			return nil, errors.New("specifying '--helm-wait-timeout' requires '--helm-wait'")
+		}
+		if _, err := time.ParseDuration(cfg.HelmWaitTimeout); err != nil {
+			return nil, fmt.Errorf("invalid Helm wait timeout '%s'; must be a duration (e.g. '5m0s')", cfg.HelmWaitTimeout)
+		}
+	}
+
+	if cfg.WaitForJobs && !cfg.HelmWait {
+		return nil, errors.New("specifying '--wait-for-jobs' requires '--helm-wait'")
+	}
+
+	if secretSlice := strings.SplitN(cfg.ImagePullSecret, "=", 2); cfg.ImagePullSecret != "" && (len(secretSlice) != 2 || secretSlice[0] == "" || secretSlice[1] == "") {
+		return nil, fmt.Errorf("invalid image pull secret '%s'; must be formatted as 'name=path/to/config.json'", cfg.ImagePullSecret)
+	}
+
+	for _, remoteChart := range cfg.RemoteCharts {
+		if chartRef, _ := util.SplitRemoteChart(remoteChart); !strings.Contains(chartRef, "/") || strings.HasSuffix(chartRef, "/") {
+			return nil, fmt.Errorf("invalid remote chart '%s'; must be formatted as 'repo/chart[:version]'", remoteChart)
+		}
+	}
+
+	for _, entry := range cfg.MinToolVersions {
+		if entrySlice := strings.SplitN(entry, "=", 2); len(entrySlice) != 2 || entrySlice[0] == "" || entrySlice[1] == "" {
+			return nil, fmt.Errorf("invalid minimum tool version '%s'; must be formatted as 'tool=version'", entry)
+		}
+	}
+
+	for _, entry := range cfg.KubeVersionsMatrix {
+		if entrySlice := strings.SplitN(entry, "=", 2); len(entrySlice) != 2 || entrySlice[0] == "" || entrySlice[1] == "" {
+			return nil, fmt.Errorf("invalid Kubernetes versions matrix entry '%s'; must be formatted as 'version=context'", entry)
+		}
+	}
+
 	// Disable upgrade (this does some expensive dependency building on previous revisions)
 	// when neither "install" nor "lint-and-install" have not been specified.
 	cfg.Upgrade = isInstall && cfg.Upgrade
 	if (cfg.TargetBranch == "" || cfg.Remote == "") && cfg.Upgrade {
 		return nil, errors.New("specifying '--upgrade=true' without '--target-branch' or '--remote', is not allowed")
 	}
+	if cfg.UpgradeOnly && !cfg.Upgrade && isInstall {
+		return nil, errors.New("specifying '--upgrade-only' without '--upgrade' is not allowed")
+	}
+	if cfg.UpgradeSeparateNamespace && !cfg.Upgrade && isInstall {
+		return nil, errors.New("specifying '--upgrade-separate-namespace' without '--upgrade' is not allowed")
+	}
 
 	chartYamlSchemaPath := cfg.ChartYamlSchema
 	if chartYamlSchemaPath == "" {
 		var err error
-		cfgFile, err = findConfigFile("chart_schema.yaml")
-		if err != nil && isLint && cfg.ValidateChartSchema {
+		cfgFile, err = findConfigFile(searchLocations, "chart_schema.yaml")
+		if err != nil && isLint && cfg.ValidateChartSchema && len(cfg.ChartYamlSchemas) == 0 {
 			return nil, errors.New("'chart_schema.yaml' neither specified nor found in default locations")
 		}
 		cfg.ChartYamlSchema = cfgFile
@@ -135,19 +459,21 @@ func LoadConfiguration(cfgFile string, cmd *cobra.Command, printConfig bool) (*C
 	lintConfPath := cfg.LintConf
 	if lintConfPath == "" {
 		var err error
-		cfgFile, err = findConfigFile("lintconf.yaml")
-		if err != nil && isLint && cfg.ValidateYaml {
+		cfgFile, err = findConfigFile(searchLocations, "lintconf.yaml")
+		if err != nil && isLint && cfg.ValidateYaml && cfg.LintConfs["*"] == "" {
 			return nil, errors.New("'lintconf.yaml' neither specified nor found in default locations")
 		}
 		cfg.LintConf = cfgFile
 	}
 
 	if len(cfg.Charts) > 0 || cfg.ProcessAllCharts {
-		fmt.Println("Version increment checking disabled.")
+		if printConfig {
+			fmt.Fprintln(info, "Version increment checking disabled.")
+		}
 		cfg.CheckVersionIncrement = false
 	}
 
-	if printConfig {
+	if printConfig && !cfg.ListCharts && !cfg.PrintConfig {
 		printCfg(cfg)
 	}
 
@@ -167,6 +493,8 @@ func printCfg(cfg *Configuration) {
 		switch e.Field(i).Kind() {
 		case reflect.Bool:
 			pattern = "%s: %t\n"
+		case reflect.Int:
+			pattern = "%s: %d\n"
 		default:
 			pattern = "%s: %s\n"
 		}
@@ -176,9 +504,269 @@ func printCfg(cfg *Configuration) {
 	util.PrintDelimiterLine("-")
 }
 
-func findConfigFile(fileName string) (string, error) {
-	for _, location := range configSearchLocations {
-		filePath := path.Join(location, fileName)
+// EffectiveYaml serializes the configuration as YAML using the names of the corresponding flags as keys, so that
+// the output may be used as config file. Passwords and tokens in Helm arguments and in URLs are redacted.
+func (cfg *Configuration) EffectiveYaml() (string, error) {
+	e := reflect.ValueOf(cfg).Elem()
+	typeOfCfg := e.Type()
+
+	var effectiveCfg yaml.MapSlice
+	for i := 0; i < e.NumField(); i++ {
+		key := typeOfCfg.Field(i).Tag.Get("mapstructure")
+		if key == "-" || key == "print-config" {
+			continue
+		}
+
+		var value interface{}
+		switch key {
+		case "chart-yaml-schema":
+			if len(cfg.ChartYamlSchemas) > 0 {
+				value = cfg.ChartYamlSchemas
+			} else {
+				value = cfg.ChartYamlSchema
+			}
+		case "lint-conf":
+			if len(cfg.LintConfs) > 0 {
+				value = cfg.LintConfs
+			} else {
+				value = cfg.LintConf
+			}
+		case "namespace-delete-timeout":
+			value = cfg.NamespaceDeleteTimeout.String()
+		case "kubectl-wait-timeout":
+			value = cfg.KubectlWaitTimeout.String()
+		case "prune-min-age":
+			value = cfg.PruneMinAge.String()
+		case "helm-extra-args":
+			value = redactArgs(cfg.HelmExtraArgs)
+		case "kubectl-extra-args":
+			value = redactArgs(cfg.KubectlExtraArgs)
+		case "helm-repo-extra-args":
+			value = redactEntries(cfg.HelmRepoExtraArgs, redactArgs)
+		case "chart-repos":
+			value = redactEntries(cfg.ChartRepos, redactURL)
+		case "notify-webhook":
+			value = redactURLPath(cfg.NotifyWebhook)
+		case "remote-values-files":
+			var urls []string
+			for _, remoteURL := range cfg.RemoteValuesFiles {
+				urls = append(urls, redactURL(remoteURL))
+			}
+			value = urls
+		default:
+			value = e.Field(i).Interface()
+		}
+		effectiveCfg = append(effectiveCfg, yaml.MapItem{Key: key, Value: value})
+	}
+
+	out, err := yaml.Marshal(effectiveCfg)
+	if err != nil {
+		return "", errors.Wrap(err, "Error marshaling configuration")
+	}
+	return string(out), nil
+}
+
+// redactEntries redacts the values of 'name=value' entries using the given function.
+func redactEntries(entries []string, redact func(string) string) []string {
+	var redactedEntries []string
+	for _, entry := range entries {
+		if entrySlice := strings.SplitN(entry, "=", 2); len(entrySlice) == 2 {
+			entry = fmt.Sprintf("%s=%s", entrySlice[0], redact(entrySlice[1]))
+		}
+		redactedEntries = append(redactedEntries, entry)
+	}
+	return redactedEntries
+}
+
+// redactArgs redacts the values of arguments such as '--password' or '--token', passed either as '--password=value'
+// or as '--password value'.
+func redactArgs(args string) string {
+	fields := strings.Fields(args)
+	for i, field := range fields {
+		if !strings.HasPrefix(field, "-") || !isSensitiveArg(field) {
+			continue
+		}
+		if argSlice := strings.SplitN(field, "=", 2); len(argSlice) == 2 {
+			fields[i] = fmt.Sprintf("%s=%s", argSlice[0], redacted)
+		} else if i+1 < len(fields) && !strings.HasPrefix(fields[i+1], "-") {
+			fields[i+1] = redacted
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+func isSensitiveArg(arg string) bool {
+	name := strings.ToLower(strings.SplitN(arg, "=", 2)[0])
+	return strings.Contains(name, "password") || strings.Contains(name, "token")
+}
+
+// redactURL redacts the password of URLs containing user info.
+func redactURL(rawURL string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil || parsedURL.User == nil {
+		return rawURL
+	}
+	if _, hasPassword := parsedURL.User.Password(); hasPassword {
+		parsedURL.User = url.UserPassword(parsedURL.User.Username(), redacted)
+	}
+	return parsedURL.String()
+}
+
+// redactURLPath redacts the path and query of the URL, which contain the secret of webhook URLs such as Slack's.
+func redactURLPath(rawURL string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil || (parsedURL.Path == "" && parsedURL.RawQuery == "") {
+		return rawURL
+	}
+	return fmt.Sprintf("%s://%s/%s", parsedURL.Scheme, parsedURL.Host, redacted)
+}
+
+// parseChartYamlSchemas parses Chart.yaml schemas specified per apiVersion, either as a map or as a string of
+// comma-separated 'apiVersion=path' pairs. A single schema path for all charts results in an empty map.
+func parseChartYamlSchemas(value interface{}) (map[string]string, error) {
+	return parsePathsByKey(value, "Chart.yaml schema", "apiVersion")
+}
+
+// parseLintConfs parses lint configs specified per file name pattern, either as a map or as a string of
+// comma-separated 'pattern=path' pairs. A single lint config path for all files results in an empty map.
+func parseLintConfs(value interface{}) (map[string]string, error) {
+	lintConfs, err := parsePathsByKey(value, "lint config", "pattern")
+	if err != nil {
+		return nil, err
+	}
+	for pattern := range lintConfs {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid lint config pattern '%s': %s", pattern, err)
+		}
+	}
+	return lintConfs, nil
+}
+
+// parsePathsByKey parses paths specified per key, either as a map or as a string of comma-separated 'key=path' pairs.
+// A single path results in an empty map. description and keyName are used in error messages.
+func parsePathsByKey(value interface{}, description string, keyName string) (map[string]string, error) {
+	paths := map[string]string{}
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, path := range value {
+			paths[key] = fmt.Sprint(path)
+		}
+	case string:
+		if !strings.Contains(value, "=") {
+			return nil, nil
+		}
+		for _, entry := range strings.Split(value, ",") {
+			entrySlice := strings.SplitN(entry, "=", 2)
+			if len(entrySlice) != 2 {
+				return nil, fmt.Errorf("invalid %s '%s'; must be formatted as '%s=path'", description, entry, keyName)
+			}
+			paths[strings.TrimSpace(entrySlice[0])] = strings.TrimSpace(entrySlice[1])
+		}
+	default:
+		return nil, nil
+	}
+
+	for key, path := range paths {
+		if key == "" || path == "" {
+			return nil, fmt.Errorf("invalid %s '%s=%s'; must be formatted as '%s=path'", description, key, path, keyName)
+		}
+	}
+	return paths, nil
+}
+
+// detectTargetBranch returns the target branch of the pull request being built, as provided by common CI systems,
+// and the environment variable it was read from. It returns empty strings if no target branch is provided.
+func detectTargetBranch() (branch string, envVar string) {
+	for _, envVar := range targetBranchEnvVars {
+		if branch := strings.TrimPrefix(os.Getenv(envVar), "refs/heads/"); branch != "" {
+			return branch, envVar
+		}
+	}
+	return "", ""
+}
+
+// configSearchLocationsIn returns the locations to look up config files in, with the repository root in place of the
+// current working directory if one is specified.
+func configSearchLocationsIn(repositoryRoot string) []string {
+	if repositoryRoot == "" {
+		return configSearchLocations
+	}
+	return append([]string{repositoryRoot}, configSearchLocations[1:]...)
+}
+
+// resolveRepositoryPaths resolves relative paths in the configuration against the repository root, instead of the
+// current working directory. Chart directories and paths of charts are always relative to the repository root, like
+// the paths git reports. Paths of other files are only relative to it if they are set in the config file, so that
+// paths specified on the command line or in environment variables still refer to the current working directory.
+func resolveRepositoryPaths(cfg *Configuration, v *viper.Viper, cmd *cobra.Command) {
+	root := cfg.RepositoryRoot
+	cfg.ChartDirs = inRepositoryAll(root, cfg.ChartDirs)
+	cfg.ExcludedChartPaths = inRepositoryAll(root, cfg.ExcludedChartPaths)
+	cfg.ChangedPaths = inRepositoryAll(root, cfg.ChangedPaths)
+	cfg.Charts = inRepositoryAll(root, cfg.Charts)
+	if dependentsOf := inRepository(root, cfg.DependentsOf); util.FileExists(dependentsOf) {
+		cfg.DependentsOf = dependentsOf
+	}
+
+	for key, value := range map[string]*string{
+		"lint-conf":          &cfg.LintConf,
+		"chart-yaml-schema":  &cfg.ChartYamlSchema,
+		"values-base-dir":    &cfg.ValuesBaseDir,
+		"changed-files-from": &cfg.ChangedFilesFrom,
+		"failed-charts-from": &cfg.FailedChartsFrom,
+		"results-file":       &cfg.ResultsFile,
+		"keyring":            &cfg.Keyring,
+		"kind-config":        &cfg.KindConfig,
+		"matrix":             &cfg.Matrix,
+	} {
+		if isSetInConfigFile(v, cmd, key) && *value != "-" {
+			*value = inRepository(root, *value)
+		}
+	}
+	if isSetInConfigFile(v, cmd, "conftest-policies") {
+		cfg.ConftestPolicies = inRepositoryAll(root, cfg.ConftestPolicies)
+	}
+	// Per pattern or apiVersion, these can only be set in the config file
+	for pattern, lintConf := range cfg.LintConfs {
+		cfg.LintConfs[pattern] = inRepository(root, lintConf)
+	}
+	for apiVersion, schema := range cfg.ChartYamlSchemas {
+		cfg.ChartYamlSchemas[apiVersion] = inRepository(root, schema)
+	}
+}
+
+// isSetInConfigFile checks whether the value of key is taken from the config file, i.e. it is set there and neither
+// with a flag nor an environment variable.
+func isSetInConfigFile(v *viper.Viper, cmd *cobra.Command, key string) bool {
+	if flag := cmd.Flags().Lookup(key); flag != nil && flag.Changed {
+		return false
+	}
+	if _, ok := os.LookupEnv("CT_" + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))); ok {
+		return false
+	}
+	return v.InConfig(key)
+}
+
+// inRepository returns p resolved against the repository root, unless it is empty or absolute.
+func inRepository(root string, p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(root, p)
+}
+
+// inRepositoryAll returns paths, each resolved against the repository root.
+func inRepositoryAll(root string, paths []string) []string {
+	var resolved []string
+	for _, p := range paths {
+		resolved = append(resolved, inRepository(root, p))
+	}
+	return resolved
+}
+
+func findConfigFile(searchLocations []string, fileName string) (string, error) {
+	for _, location := range searchLocations {
+		filePath := filepath.Join(location, fileName)
 		if util.FileExists(filePath) {
 			return filePath, nil
 		}
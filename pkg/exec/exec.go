@@ -55,11 +55,36 @@ func (p ProcessExecutor) RunProcessInDirAndCaptureOutput(workingDirectory string
 	return strings.TrimSpace(string(bytes)), nil
 }
 
+// RunProcessAndCaptureCombinedOutput runs the process and returns its combined stdout and stderr output. Unlike
+// RunProcessAndCaptureOutput, the output is returned even if the process fails.
+func (p ProcessExecutor) RunProcessAndCaptureCombinedOutput(executable string, execArgs ...interface{}) (string, error) {
+	cmd, err := p.CreateProcess(executable, execArgs...)
+	if err != nil {
+		return "", err
+	}
+
+	bytes, err := cmd.CombinedOutput()
+	output := strings.TrimSpace(string(bytes))
+	if err != nil {
+		return output, errors.Wrap(err, "Error running process")
+	}
+	return output, nil
+}
+
 func (p ProcessExecutor) RunProcess(executable string, execArgs ...interface{}) error {
+	return p.RunProcessWithEnv(nil, executable, execArgs...)
+}
+
+// RunProcessWithEnv runs the process like RunProcess, adding the specified environment variables in the form
+// 'key=value' to the environment inherited from the current process.
+func (p ProcessExecutor) RunProcessWithEnv(env []string, executable string, execArgs ...interface{}) error {
 	cmd, err := p.CreateProcess(executable, execArgs...)
 	if err != nil {
 		return err
 	}
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
 
 	outReader, err := cmd.StdoutPipe()
 	if err != nil {
@@ -106,14 +131,14 @@ func (p ProcessExecutor) CreateProcess(executable string, execArgs ...interface{
 
 type fn func(port int) error
 
-func (p ProcessExecutor) RunWithProxy(withProxy fn) error {
+func (p ProcessExecutor) RunWithProxy(withProxy fn, proxyArgs ...string) error {
 	randomPort, err := util.GetRandomPort()
 	if err != nil {
 		return errors.Wrap(err, "Could not find a free port for running 'kubectl proxy'")
 	}
 
 	fmt.Printf("Running 'kubectl proxy' on port %d\n", randomPort)
-	cmdProxy, err := p.CreateProcess("kubectl", "proxy", fmt.Sprintf("--port=%d", randomPort))
+	cmdProxy, err := p.CreateProcess("kubectl", "proxy", fmt.Sprintf("--port=%d", randomPort), proxyArgs)
 	if err != nil {
 		return errors.Wrap(err, "Error creating the 'kubectl proxy' process")
 	}
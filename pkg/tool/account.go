@@ -15,14 +15,30 @@
 package tool
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"regexp"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
-type AccountValidator struct{}
+// ErrAccountValidationTimeout is returned, wrapped, by Validate if the Git provider does not respond in time.
+var ErrAccountValidationTimeout = errors.New("timed out")
+
+type AccountValidator struct {
+	client  *http.Client
+	timeout time.Duration
+}
+
+// NewAccountValidator creates a new AccountValidator. Each validation is canceled after timeout, unless it is zero.
+func NewAccountValidator(timeout time.Duration) AccountValidator {
+	return AccountValidator{
+		client:  http.DefaultClient,
+		timeout: timeout,
+	}
+}
 
 var repoDomainPattern = regexp.MustCompile("(?:https://(?:[^@:]+:[^@:]+@)?|git@)([^/:]+)")
 
@@ -31,11 +47,28 @@ func (v AccountValidator) Validate(repoURL string, account string) error {
 	if err != nil {
 		return err
 	}
+
+	ctx := context.Background()
+	if v.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, v.timeout)
+		defer cancel()
+	}
+
 	url := fmt.Sprintf("https://%s/%s", domain, account)
-	response, err := http.Head(url)
+	request, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return errors.Wrap(err, "Error validating maintainers")
+	}
+	response, err := v.client.Do(request)
+	if ctx.Err() == context.DeadlineExceeded {
+		return errors.Wrapf(ErrAccountValidationTimeout, "Error validating maintainer '%s': no response from '%s' after %s",
+			account, domain, v.timeout)
+	}
 	if err != nil {
 		return errors.Wrap(err, "Error validating maintainers")
 	}
+	defer response.Body.Close()
 	if response.StatusCode != 200 {
 		return fmt.Errorf("Error validating maintainer '%s': %s", account, response.Status)
 	}
@@ -2,8 +2,11 @@ package tool
 
 import (
 	"fmt"
+	"net/http"
 	"testing"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -34,3 +37,17 @@ func TestParseOutGitDomain(t *testing.T) {
 		})
 	}
 }
+
+type blockingTransport struct{}
+
+func (t blockingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	<-request.Context().Done()
+	return nil, request.Context().Err()
+}
+
+func TestValidateTimeout(t *testing.T) {
+	validator := AccountValidator{client: &http.Client{Transport: blockingTransport{}}, timeout: 10 * time.Millisecond}
+	err := validator.Validate("https://github.com/foo/bar", "foo")
+	assert.True(t, errors.Is(err, ErrAccountValidationTimeout))
+	assert.EqualError(t, err, "Error validating maintainer 'foo': no response from 'github.com' after 10ms: timed out")
+}
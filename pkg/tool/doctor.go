@@ -0,0 +1,130 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tool
+
+import (
+	"fmt"
+	osexec "os/exec"
+	"regexp"
+
+	"github.com/Masterminds/semver"
+	"github.com/helm/chart-testing/v3/pkg/exec"
+	"github.com/pkg/errors"
+)
+
+// DefaultMinToolVersions are the minimum versions of the external tools known to work, matching those shipped in the
+// Docker image.
+var DefaultMinToolVersions = map[string]string{
+	"git":      "2.5.0",
+	"helm":     "3.0.0",
+	"kubectl":  "1.18.0",
+	"yamllint": "1.21.0",
+	"yamale":   "2.0.1",
+}
+
+var (
+	toolVersionArgs = map[string][]string{
+		"git":      {"--version"},
+		"helm":     {"version", "--short"},
+		"kubectl":  {"version", "--client", "--output=json"},
+		"yamllint": {"--version"},
+		"yamale":   {"--version"},
+		"conftest": {"--version"},
+		"kind":     {"version"},
+	}
+	toolVersionPattern = regexp.MustCompile(`\d+\.\d+(\.\d+)?`)
+)
+
+// ToolCheck is the result of checking an external tool.
+type ToolCheck struct {
+	Name       string
+	Version    string
+	MinVersion string
+	Missing    bool
+	Outdated   bool
+	Error      error
+}
+
+func (c ToolCheck) String() string {
+	switch {
+	case c.Missing:
+		return fmt.Sprintf("%s: not found", c.Name)
+	case c.Error != nil:
+		return fmt.Sprintf("%s: found, but the version is unknown: %s", c.Name, c.Error)
+	case c.Outdated:
+		return fmt.Sprintf("%s: %s is older than the known-good minimum %s", c.Name, c.Version, c.MinVersion)
+	default:
+		return fmt.Sprintf("%s: %s", c.Name, c.Version)
+	}
+}
+
+type Doctor struct {
+	exec        exec.ProcessExecutor
+	minVersions map[string]string
+}
+
+// NewDoctor creates a new Doctor. minVersions override DefaultMinToolVersions per tool.
+func NewDoctor(exec exec.ProcessExecutor, minVersions map[string]string) Doctor {
+	versions := map[string]string{}
+	for name, version := range DefaultMinToolVersions {
+		versions[name] = version
+	}
+	for name, version := range minVersions {
+		versions[name] = version
+	}
+
+	return Doctor{
+		exec:        exec,
+		minVersions: versions,
+	}
+}
+
+// Check checks whether the tools are installed and at least at their minimum versions.
+func (d Doctor) Check(tools ...string) []ToolCheck {
+	var checks []ToolCheck
+	for _, name := range tools {
+		check := ToolCheck{Name: name, MinVersion: d.minVersions[name]}
+		if _, err := osexec.LookPath(name); err != nil {
+			check.Missing = true
+		} else if output, err := d.exec.RunProcessAndCaptureOutput(name, toolVersionArgs[name]); err != nil {
+			check.Error = err
+		} else {
+			check = check.withVersion(output)
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// withVersion sets the version parsed from the output of the tool's version command and whether it is older than the
+// minimum version.
+func (c ToolCheck) withVersion(output string) ToolCheck {
+	c.Version = toolVersionPattern.FindString(output)
+	version, err := semver.NewVersion(c.Version)
+	if err != nil {
+		c.Error = errors.Errorf("could not parse version from '%s'", output)
+		return c
+	}
+
+	if c.MinVersion != "" {
+		minVersion, err := semver.NewVersion(c.MinVersion)
+		if err != nil {
+			c.Error = errors.Wrapf(err, "invalid minimum version '%s'", c.MinVersion)
+			return c
+		}
+		c.Outdated = version.LessThan(minVersion)
+	}
+	return c
+}
@@ -0,0 +1,56 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tool
+
+import (
+	"testing"
+
+	"github.com/helm/chart-testing/v3/pkg/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToolCheckWithVersion(t *testing.T) {
+	var testDataSlice = []struct {
+		name       string
+		output     string
+		minVersion string
+		version    string
+		outdated   bool
+		err        bool
+	}{
+		{"helm", "v3.1.2+gd878d4d", "3.0.0", "3.1.2", false, false},
+		{"git", "git version 2.4.1", "2.5.0", "2.4.1", true, false},
+		{"kubectl", `{"clientVersion": {"major": "1", "minor": "18", "gitVersion": "v1.18.0"}}`, "1.18.0", "1.18.0", false, false},
+		{"yamllint", "yamllint 1.20", "1.21.0", "1.20", true, false},
+		{"yamale", "usage: yamale [-h]", "2.0.1", "", false, true},
+		{"no minimum", "tool 0.1.0", "", "0.1.0", false, false},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.name, func(t *testing.T) {
+			check := ToolCheck{Name: testData.name, MinVersion: testData.minVersion}.withVersion(testData.output)
+			assert.Equal(t, testData.version, check.Version)
+			assert.Equal(t, testData.outdated, check.Outdated)
+			assert.Equal(t, testData.err, check.Error != nil)
+		})
+	}
+}
+
+func TestNewDoctor(t *testing.T) {
+	doctor := NewDoctor(exec.NewProcessExecutor(false), map[string]string{"helm": "3.5.0"})
+	assert.Equal(t, "3.5.0", doctor.minVersions["helm"])
+	assert.Equal(t, DefaultMinToolVersions["kubectl"], doctor.minVersions["kubectl"])
+	assert.Equal(t, "3.0.0", DefaultMinToolVersions["helm"])
+}
@@ -16,6 +16,7 @@ package tool
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
 
 	"github.com/helm/chart-testing/v3/pkg/exec"
@@ -24,54 +25,104 @@ import (
 
 type Git struct {
 	exec exec.ProcessExecutor
+	dir  string
 }
 
-func NewGit(exec exec.ProcessExecutor) Git {
+// NewGit creates a new Git operating on the repository in dir, like 'git -C'. If dir is empty, it operates on the
+// repository in the current working directory. With dir, the paths of changed files are returned prefixed with dir
+// and absolute file paths are accepted, so they can be used without changing into dir.
+func NewGit(exec exec.ProcessExecutor, dir string) Git {
 	return Git{
 		exec: exec,
+		dir:  dir,
 	}
 }
 
 func (g Git) FileExistsOnBranch(file string, remote string, branch string) bool {
-	fileSpec := fmt.Sprintf("%s/%s:%s", remote, branch, file)
-	_, err := g.exec.RunProcessAndCaptureOutput("git", "cat-file", "-e", fileSpec)
+	fileSpec := fmt.Sprintf("%s/%s:%s", remote, branch, g.repositoryPath(file))
+	_, err := g.exec.RunProcessAndCaptureOutput("git", g.dirArgs(), "cat-file", "-e", fileSpec)
 	return err == nil
 }
 
 func (g Git) AddWorktree(path string, ref string) error {
-	return g.exec.RunProcess("git", "worktree", "add", path, ref)
+	return g.exec.RunProcess("git", g.dirArgs(), "worktree", "add", path, ref)
 }
 
 func (g Git) RemoveWorktree(path string) error {
-	return g.exec.RunProcess("git", "worktree", "remove", path)
+	return g.exec.RunProcess("git", g.dirArgs(), "worktree", "remove", path)
 }
 
 func (g Git) Show(file string, remote string, branch string) (string, error) {
-	fileSpec := fmt.Sprintf("%s/%s:%s", remote, branch, file)
-	return g.exec.RunProcessAndCaptureOutput("git", "show", fileSpec)
+	fileSpec := fmt.Sprintf("%s/%s:%s", remote, branch, g.repositoryPath(file))
+	return g.exec.RunProcessAndCaptureOutput("git", g.dirArgs(), "show", fileSpec)
 }
 
 func (g Git) MergeBase(commit1 string, commit2 string) (string, error) {
-	return g.exec.RunProcessAndCaptureOutput("git", "merge-base", commit1, commit2)
+	return g.exec.RunProcessAndCaptureOutput("git", g.dirArgs(), "merge-base", commit1, commit2)
 }
 
 func (g Git) ListChangedFilesInDirs(commit string, dirs ...string) ([]string, error) {
 	changedChartFilesString, err :=
-		g.exec.RunProcessAndCaptureOutput("git", "diff", "--find-renames", "--name-only", commit, "--", dirs)
+		g.exec.RunProcessAndCaptureOutput("git", g.dirArgs(), "diff", "--find-renames", "--name-only", commit, "--", dirs)
 	if err != nil {
 		return nil, errors.Wrap(err, "Error creating diff")
 	}
 	if changedChartFilesString == "" {
 		return nil, nil
 	}
-	return strings.Split(changedChartFilesString, "\n"), nil
+	changedFiles := strings.Split(changedChartFilesString, "\n")
+	if g.dir != "" {
+		for i, file := range changedFiles {
+			changedFiles[i] = filepath.Join(g.dir, file)
+		}
+	}
+	return changedFiles, nil
 }
 
 func (g Git) GetUrlForRemote(remote string) (string, error) {
-	return g.exec.RunProcessAndCaptureOutput("git", "ls-remote", "--get-url", remote)
+	return g.exec.RunProcessAndCaptureOutput("git", g.dirArgs(), "ls-remote", "--get-url", remote)
+}
+
+func (g Git) ListRemotes() ([]string, error) {
+	remotes, err := g.exec.RunProcessAndCaptureOutput("git", g.dirArgs(), "remote")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(remotes), nil
+}
+
+func (g Git) BranchExists(remote string, branch string) bool {
+	ref := fmt.Sprintf("refs/remotes/%s/%s", remote, branch)
+	_, err := g.exec.RunProcessAndCaptureOutput("git", g.dirArgs(), "rev-parse", "--verify", "--quiet", ref)
+	return err == nil
+}
+
+func (g Git) CommitExists(commit string) bool {
+	_, err := g.exec.RunProcessAndCaptureOutput("git", g.dirArgs(), "rev-parse", "--verify", "--quiet", commit+"^{commit}")
+	return err == nil
 }
 
 func (g Git) ValidateRepository() error {
-	_, err := g.exec.RunProcessAndCaptureOutput("git", "rev-parse", "--is-inside-work-tree")
+	_, err := g.exec.RunProcessAndCaptureOutput("git", g.dirArgs(), "rev-parse", "--is-inside-work-tree")
 	return err
 }
+
+// dirArgs returns the arguments making git operate on the repository in dir, if one is set.
+func (g Git) dirArgs() []string {
+	if g.dir == "" {
+		return nil
+	}
+	return []string{"-C", g.dir}
+}
+
+// repositoryPath returns file relative to dir, if one is set and file is absolute, as git expects paths in object
+// names to be relative to the repository root.
+func (g Git) repositoryPath(file string) string {
+	if g.dir == "" || !filepath.IsAbs(file) {
+		return file
+	}
+	if rel, err := filepath.Rel(g.dir, file); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return file
+}
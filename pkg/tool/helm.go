@@ -16,64 +16,276 @@ package tool
 
 import (
 	"fmt"
+	osexec "os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
 
 	"github.com/helm/chart-testing/v3/pkg/exec"
+	"github.com/helm/chart-testing/v3/pkg/util"
+	"github.com/pkg/errors"
+)
+
+var (
+	downloadErrorPattern   = regexp.MustCompile(`could not download (\S+):\s+(.*)`)
+	provenanceErrorPattern = regexp.MustCompile(`(?i)provenance|signature|openpgp|keyring|sum does not match`)
 )
 
 type Helm struct {
-	exec      exec.ProcessExecutor
-	extraArgs []string
+	exec                 exec.ProcessExecutor
+	extraArgs            []string
+	repositoryArgs       []string
+	installArgs          []string
+	unitTestArgs         []string
+	verifyArgs           []string
+	includeDefaultValues bool
 }
 
-func NewHelm(exec exec.ProcessExecutor, extraArgs []string) Helm {
+// HelmOptions configures the arguments a Helm passes to the helm CLI.
+type HelmOptions struct {
+	// ExtraArgs are passed to install, upgrade, and test commands.
+	ExtraArgs []string
+	// RepositoryCache is passed as '--repository-cache' if not empty.
+	RepositoryCache string
+	// RepositoryConfig is passed as '--repository-config' if not empty.
+	RepositoryConfig string
+	// DependencyUpdate updates dependencies on install and upgrade using '--dependency-update'.
+	DependencyUpdate bool
+	// WaitForJobs makes install and upgrade wait for jobs to complete using '--wait-for-jobs'.
+	WaitForJobs bool
+	// IncludeDefaultValues passes the chart's 'values.yaml' on install before the values file, which thus overrides it.
+	IncludeDefaultValues bool
+	// UnitTestsPath is passed to 'helm unittest' as '--file' if not empty.
+	UnitTestsPath string
+	// VerifyProvenance verifies the provenance of dependencies and pulled charts using '--verify'.
+	VerifyProvenance bool
+	// Keyring is passed as '--keyring' along with '--verify' if not empty.
+	Keyring string
+}
+
+// NewHelm creates a new Helm with the specified options.
+func NewHelm(exec exec.ProcessExecutor, options HelmOptions) Helm {
+	var repositoryArgs []string
+	if options.RepositoryCache != "" {
+		repositoryArgs = append(repositoryArgs, "--repository-cache", options.RepositoryCache)
+	}
+	if options.RepositoryConfig != "" {
+		repositoryArgs = append(repositoryArgs, "--repository-config", options.RepositoryConfig)
+	}
+
+	var installArgs []string
+	if options.DependencyUpdate {
+		installArgs = append(installArgs, "--dependency-update")
+	}
+	if options.WaitForJobs {
+		installArgs = append(installArgs, "--wait-for-jobs")
+	}
+
+	var unitTestArgs []string
+	if options.UnitTestsPath != "" {
+		unitTestArgs = append(unitTestArgs, "--file", options.UnitTestsPath)
+	}
+
+	var verifyArgs []string
+	if options.VerifyProvenance {
+		verifyArgs = append(verifyArgs, "--verify")
+		if options.Keyring != "" {
+			verifyArgs = append(verifyArgs, "--keyring", options.Keyring)
+		}
+	}
+
 	return Helm{
-		exec:      exec,
-		extraArgs: extraArgs,
+		exec:                 exec,
+		extraArgs:            options.ExtraArgs,
+		repositoryArgs:       repositoryArgs,
+		installArgs:          installArgs,
+		unitTestArgs:         unitTestArgs,
+		verifyArgs:           verifyArgs,
+		includeDefaultValues: options.IncludeDefaultValues,
 	}
 }
 
 func (h Helm) AddRepo(name string, url string, extraArgs []string) error {
-	return h.exec.RunProcess("helm", "repo", "add", name, url, extraArgs)
+	return h.exec.RunProcess("helm", "repo", "add", name, url, extraArgs, h.repositoryArgs)
 }
 
+// BuildDependencies runs `helm dependency build` for the given chart. If provenance verification is enabled and
+// fails, the returned error lists the dependencies which failed verification along with Helm's reason. Otherwise,
+// Helm's error message is returned.
 func (h Helm) BuildDependencies(chart string) error {
-	return h.exec.RunProcess("helm", "dependency", "build", chart)
+	if len(h.verifyArgs) == 0 {
+		return h.exec.RunProcess("helm", "dependency", "build", chart, h.repositoryArgs)
+	}
+
+	output, err := h.exec.RunProcessAndCaptureCombinedOutput("helm", "dependency", "build", chart, h.verifyArgs, h.repositoryArgs)
+	fmt.Println(output)
+	if err == nil {
+		return nil
+	}
+	if dependencies := unverifiedDependencies(output); len(dependencies) > 0 {
+		return fmt.Errorf("Provenance verification failed for dependencies: %s", strings.Join(dependencies, ", "))
+	}
+	if message := helmErrorMessage(output); message != "" {
+		return fmt.Errorf("Error building dependencies: %s", message)
+	}
+	return errors.Wrap(err, "Error building dependencies")
 }
 
-func (h Helm) LintWithValues(chart string, valuesFile string) error {
+// unverifiedDependencies returns the dependencies which failed provenance verification in the output of
+// `helm dependency build --verify`, each with Helm's reason, e.g. for
+// 'Error: could not download https://charts.example.com/foo-1.0.0.tgz: failed to fetch provenance ...'. Dependencies
+// which could not be downloaded for other reasons, e.g. network errors, are not returned.
+func unverifiedDependencies(output string) []string {
+	var dependencies []string
+	for _, match := range downloadErrorPattern.FindAllStringSubmatch(output, -1) {
+		if reason := strings.TrimSpace(match[2]); provenanceErrorPattern.MatchString(reason) {
+			dependencies = append(dependencies, fmt.Sprintf("%s (%s)", match[1], reason))
+		}
+	}
+	return dependencies
+}
+
+// helmErrorMessage returns the message of the last 'Error: ' line in Helm's output, or an empty string if there is
+// none.
+func helmErrorMessage(output string) string {
+	var message string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, "Error: ") {
+			message = strings.TrimPrefix(line, "Error: ")
+		}
+	}
+	return message
+}
+
+func (h Helm) LintWithValuesAndCaptureOutput(chart string, valuesFile string) (string, error) {
 	var values []string
 	if valuesFile != "" {
 		values = []string{"--values", valuesFile}
 	}
 
-	return h.exec.RunProcess("helm", "lint", chart, values)
+	return h.exec.RunProcessAndCaptureCombinedOutput("helm", "lint", chart, values)
+}
+
+func (h Helm) RunPlugin(plugin string, chart string) error {
+	return h.exec.RunProcess("helm", strings.Fields(plugin), chart)
+}
+
+// UnitTest runs `helm unittest` for the given chart and prints its output. If tests fail, the returned error lists
+// the failed test suites.
+func (h Helm) UnitTest(chart string) error {
+	output, err := h.exec.RunProcessAndCaptureCombinedOutput("helm", "unittest", h.unitTestArgs, chart)
+	fmt.Println(output)
+	if err == nil {
+		return nil
+	}
+	if suites := failedUnitTestSuites(output); len(suites) > 0 {
+		return fmt.Errorf("%d unit test suite(s) failed: %s", len(suites), strings.Join(suites, "; "))
+	}
+	return errors.Wrap(err, "Error running unit tests")
+}
+
+// failedUnitTestSuites returns the failed test suites reported in the output of `helm unittest`, e.g.
+// ' FAIL  test deployment	chart/tests/deployment_test.yaml', each formatted as 'name (file)'.
+func failedUnitTestSuites(output string) []string {
+	var suites []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "FAIL ") {
+			continue
+		}
+		suite := strings.SplitN(strings.TrimSpace(strings.TrimPrefix(line, "FAIL ")), "\t", 2)
+		if len(suite) == 2 {
+			suites = append(suites, fmt.Sprintf("%s (%s)", strings.TrimSpace(suite[0]), strings.TrimSpace(suite[1])))
+		} else {
+			suites = append(suites, suite[0])
+		}
+	}
+	return suites
+}
+
+func (h Helm) Pull(chart string, version string, destination string) error {
+	var versionArgs []string
+	if version != "" {
+		versionArgs = []string{"--version", version}
+	}
+
+	return h.exec.RunProcess("helm", "pull", chart, versionArgs, h.verifyArgs, "--untar", "--untardir", destination, h.repositoryArgs)
+}
+
+// ResolveChart checks that the given chart reference resolves by running `helm show chart`. If the chart cannot be
+// resolved, the returned error contains Helm's error message.
+func (h Helm) ResolveChart(chart string, repository string, version string) error {
+	var repoArgs []string
+	if repository != "" {
+		repoArgs = []string{"--repo", repository}
+	}
+	var versionArgs []string
+	if version != "" {
+		versionArgs = []string{"--version", version}
+	}
+
+	output, err := h.exec.RunProcessAndCaptureCombinedOutput("helm", "show", "chart", chart, repoArgs, versionArgs, h.repositoryArgs)
+	if err == nil {
+		return nil
+	}
+	if message := strings.TrimSpace(output); message != "" {
+		return errors.New(strings.TrimPrefix(message, "Error: "))
+	}
+	return err
+}
+
+// TemplateWithValues runs `helm template` for the given chart with the same values and arguments as an install,
+// including the chart's CRDs, and returns the rendered manifests.
+func (h Helm) TemplateWithValues(chart string, valuesFile string) (string, error) {
+	var values []string
+	if valuesFile != "" {
+		values = h.installValues(chart, valuesFile)
+	}
+
+	return h.exec.RunProcessAndCaptureOutput("helm", "template", chart, "--include-crds", values, h.installArgs,
+		h.extraArgs, h.repositoryArgs)
 }
 
 func (h Helm) InstallWithValues(chart string, valuesFile string, namespace string, release string) error {
 	var values []string
 	if valuesFile != "" {
-		values = []string{"--values", valuesFile}
+		values = h.installValues(chart, valuesFile)
 	}
 
 	if err := h.exec.RunProcess("helm", "install", release, chart, "--namespace", namespace,
-		"--wait", values, h.extraArgs); err != nil {
+		"--wait", values, h.installArgs, h.extraArgs, h.repositoryArgs); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// installValues returns the '--values' arguments for installing the chart with the values file, preceded by the
+// chart's 'values.yaml' if default values are to be included explicitly.
+func (h Helm) installValues(chart string, valuesFile string) []string {
+	defaultValuesFile := filepath.Join(chart, "values.yaml")
+	if h.includeDefaultValues && util.FileExists(defaultValuesFile) {
+		return []string{"--values", defaultValuesFile, "--values", valuesFile}
+	}
+	return []string{"--values", valuesFile}
+}
+
 func (h Helm) Upgrade(chart string, namespace string, release string) error {
 	if err := h.exec.RunProcess("helm", "upgrade", release, chart, "--namespace", namespace,
-		"--reuse-values", "--wait", h.extraArgs); err != nil {
+		"--reuse-values", "--wait", h.installArgs, h.extraArgs, h.repositoryArgs); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (h Helm) Test(namespace string, release string) error {
-	return h.exec.RunProcess("helm", "test", release, "--namespace", namespace, h.extraArgs)
+func (h Helm) Test(namespace string, release string, filters []string) error {
+	var filterArgs []string
+	if len(filters) > 0 {
+		filterArgs = []string{"--filter", strings.Join(filters, ",")}
+	}
+
+	return h.exec.RunProcess("helm", "test", release, "--namespace", namespace, filterArgs, h.extraArgs)
 }
 
 func (h Helm) DeleteRelease(namespace string, release string) {
@@ -83,6 +295,58 @@ func (h Helm) DeleteRelease(namespace string, release string) {
 	}
 }
 
+// Validate checks that Helm works and that post-renderers passed with the extra arguments are available, either as
+// executables or as Helm plugins.
+func (h Helm) Validate() error {
+	if _, err := h.exec.RunProcessAndCaptureOutput("helm", "version"); err != nil {
+		return errors.Wrap(err, "Error running 'helm version'")
+	}
+
+	var plugins []string
+	for _, postRenderer := range postRenderers(h.extraArgs) {
+		if _, err := osexec.LookPath(postRenderer); err == nil {
+			continue
+		}
+		if plugins == nil {
+			output, err := h.exec.RunProcessAndCaptureOutput("helm", "plugin", "list")
+			if err != nil {
+				return errors.Wrap(err, "Error listing Helm plugins")
+			}
+			plugins = parsePluginNames(output)
+		}
+		if !util.StringSliceContains(plugins, postRenderer) {
+			return fmt.Errorf("post-renderer '%s' is neither an executable nor an installed Helm plugin", postRenderer)
+		}
+	}
+	return nil
+}
+
+// postRenderers returns the post-renderers passed with '--post-renderer'.
+func postRenderers(args []string) []string {
+	var renderers []string
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "--post-renderer=") {
+			renderers = append(renderers, strings.TrimPrefix(arg, "--post-renderer="))
+		} else if arg == "--post-renderer" && i+1 < len(args) {
+			renderers = append(renderers, args[i+1])
+		}
+	}
+	return renderers
+}
+
+// parsePluginNames parses the names of plugins from the output of 'helm plugin list'.
+func parsePluginNames(output string) []string {
+	plugins := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] == "NAME" {
+			continue
+		}
+		plugins = append(plugins, fields[0])
+	}
+	return plugins
+}
+
 func (h Helm) Version() (string, error) {
 	return h.exec.RunProcessAndCaptureOutput("helm", "version", "--short")
 }
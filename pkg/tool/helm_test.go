@@ -0,0 +1,93 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tool
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/helm/chart-testing/v3/pkg/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostRenderers(t *testing.T) {
+	assert.Empty(t, postRenderers([]string{"--timeout", "500s"}))
+	assert.Equal(t, []string{"kustomize-renderer", "./render.sh"},
+		postRenderers([]string{"--post-renderer", "kustomize-renderer", "--timeout", "500s", "--post-renderer=./render.sh"}))
+	assert.Empty(t, postRenderers([]string{"--post-renderer"}))
+}
+
+func TestParsePluginNames(t *testing.T) {
+	output := "NAME   \tVERSION\tDESCRIPTION\ndiff   \t3.1.3  \tPreview helm upgrade changes as a diff\nsecrets\t3.4.0  \tThis plugin provides secrets values encryption"
+	assert.Equal(t, []string{"diff", "secrets"}, parsePluginNames(output))
+	assert.Empty(t, parsePluginNames(""))
+}
+
+func TestInstallValues(t *testing.T) {
+	chart, err := ioutil.TempDir("", "ct_chart")
+	assert.Nil(t, err)
+	defer os.RemoveAll(chart)
+
+	helm := Helm{includeDefaultValues: true}
+	assert.Equal(t, []string{"--values", "ci/test-values.yaml"}, helm.installValues(chart, "ci/test-values.yaml"))
+
+	defaultValuesFile := filepath.Join(chart, "values.yaml")
+	assert.Nil(t, ioutil.WriteFile(defaultValuesFile, []byte("replicas: 1\n"), 0644))
+	assert.Equal(t, []string{"--values", defaultValuesFile, "--values", "ci/test-values.yaml"}, helm.installValues(chart, "ci/test-values.yaml"))
+
+	helm = Helm{}
+	assert.Equal(t, []string{"--values", "ci/test-values.yaml"}, helm.installValues(chart, "ci/test-values.yaml"))
+}
+
+func TestFailedUnitTestSuites(t *testing.T) {
+	output := "### Chart [ foo ] charts/foo\n\n" +
+		" PASS  test configmap\tcharts/foo/tests/configmap_test.yaml\n" +
+		" FAIL  test deployment\tcharts/foo/tests/deployment_test.yaml\n" +
+		"\t- should set replicas\n\n" +
+		"Charts:      1 failed, 0 passed, 1 total\n" +
+		"Test Suites: 1 failed, 1 passed, 2 total\n"
+	assert.Equal(t, []string{"test deployment (charts/foo/tests/deployment_test.yaml)"}, failedUnitTestSuites(output))
+	assert.Empty(t, failedUnitTestSuites("Error: unknown command \"unittest\" for \"helm\""))
+}
+
+func TestInstallArgs(t *testing.T) {
+	assert.Empty(t, NewHelm(exec.NewProcessExecutor(false), HelmOptions{}).installArgs)
+	assert.Equal(t, []string{"--dependency-update", "--wait-for-jobs"}, NewHelm(exec.NewProcessExecutor(false), HelmOptions{DependencyUpdate: true, WaitForJobs: true}).installArgs)
+}
+
+func TestVerifyArgs(t *testing.T) {
+	assert.Empty(t, NewHelm(exec.NewProcessExecutor(false), HelmOptions{Keyring: "keyring.gpg"}).verifyArgs)
+	assert.Equal(t, []string{"--verify"}, NewHelm(exec.NewProcessExecutor(false), HelmOptions{VerifyProvenance: true}).verifyArgs)
+	assert.Equal(t, []string{"--verify", "--keyring", "keyring.gpg"}, NewHelm(exec.NewProcessExecutor(false), HelmOptions{VerifyProvenance: true, Keyring: "keyring.gpg"}).verifyArgs)
+}
+
+func TestUnverifiedDependencies(t *testing.T) {
+	output := "Saving 1 charts\n" +
+		"Downloading foo from repo https://charts.example.com\n" +
+		"Error: could not download https://charts.example.com/foo-1.0.0.tgz: failed to fetch provenance \"https://charts.example.com/foo-1.0.0.tgz.prov\"\n"
+	assert.Equal(t, []string{"https://charts.example.com/foo-1.0.0.tgz (failed to fetch provenance \"https://charts.example.com/foo-1.0.0.tgz.prov\")"}, unverifiedDependencies(output))
+	assert.Equal(t, []string{"https://charts.example.com/foo-1.0.0.tgz (openpgp: signature made by unknown entity)"},
+		unverifiedDependencies("Error: could not download https://charts.example.com/foo-1.0.0.tgz: openpgp: signature made by unknown entity\n"))
+	assert.Empty(t, unverifiedDependencies("Error: could not download https://charts.example.com/foo-1.0.0.tgz: failed to fetch https://charts.example.com/foo-1.0.0.tgz : 404 Not Found\n"))
+	assert.Empty(t, unverifiedDependencies("Error: no repository definition for https://charts.example.com"))
+}
+
+func TestHelmErrorMessage(t *testing.T) {
+	output := "Saving 1 charts\nError: could not download https://charts.example.com/foo-1.0.0.tgz: 401 Unauthorized\n"
+	assert.Equal(t, "could not download https://charts.example.com/foo-1.0.0.tgz: 401 Unauthorized", helmErrorMessage(output))
+	assert.Empty(t, helmErrorMessage("Saving 1 charts\n"))
+}
@@ -0,0 +1,61 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tool
+
+import (
+	"fmt"
+
+	"github.com/helm/chart-testing/v3/pkg/exec"
+)
+
+// Kind manages throwaway kind clusters to install charts into.
+type Kind struct {
+	exec exec.ProcessExecutor
+}
+
+func NewKind(exec exec.ProcessExecutor) Kind {
+	return Kind{
+		exec: exec,
+	}
+}
+
+// CreateCluster creates a kind cluster with the given name and waits for its control plane to become ready. If image
+// is not empty, it is used as the node image. If configFile is not empty, the cluster is configured with it.
+func (k Kind) CreateCluster(name string, image string, configFile string) error {
+	fmt.Printf("Creating kind cluster '%s'...\n", name)
+	return k.exec.RunProcess("kind", createClusterArgs(name, image, configFile))
+}
+
+// DeleteCluster deletes the kind cluster with the given name.
+func (k Kind) DeleteCluster(name string) error {
+	fmt.Printf("Deleting kind cluster '%s'...\n", name)
+	return k.exec.RunProcess("kind", "delete", "cluster", "--name", name)
+}
+
+// KubeContext returns the kube context of the kind cluster with the given name.
+func (k Kind) KubeContext(name string) string {
+	return "kind-" + name
+}
+
+func createClusterArgs(name string, image string, configFile string) []string {
+	args := []string{"create", "cluster", "--name", name, "--wait", "5m"}
+	if image != "" {
+		args = append(args, "--image", image)
+	}
+	if configFile != "" {
+		args = append(args, "--config", configFile)
+	}
+	return args
+}
@@ -0,0 +1,27 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tool
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateClusterArgs(t *testing.T) {
+	assert.Equal(t, []string{"create", "cluster", "--name", "ct-abc123", "--wait", "5m"}, createClusterArgs("ct-abc123", "", ""))
+	assert.Equal(t, []string{"create", "cluster", "--name", "ct-abc123", "--wait", "5m", "--image", "kindest/node:v1.21.1", "--config", "kind.yaml"},
+		createClusterArgs("ct-abc123", "kindest/node:v1.21.1", "kind.yaml"))
+}
@@ -4,45 +4,184 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/helm/chart-testing/v3/pkg/exec"
+	"github.com/helm/chart-testing/v3/pkg/util"
 	"github.com/pkg/errors"
 )
 
 type Kubectl struct {
-	exec exec.ProcessExecutor
+	exec                   exec.ProcessExecutor
+	extraArgs              []string
+	namespaceDeleteTimeout time.Duration
+	waitTimeout            time.Duration
+	waitExcludeSelector    string
 }
 
-func NewKubectl(exec exec.ProcessExecutor) Kubectl {
+const (
+	defaultNamespaceDeleteTimeout = 180 * time.Second
+	defaultWaitTimeout            = 180 * time.Second
+)
+
+// pollInterval is the time to wait between checks while polling for resources to reach a state.
+var pollInterval = 2 * time.Second
+
+// namespaceDefaultResources are the resources Kubernetes creates in every namespace, which are never deleted along
+// with a release.
+var namespaceDefaultResources = []string{"configmap/kube-root-ca.crt", "serviceaccount/default"}
+
+// NamespaceLabel is the label of the namespaces created by ct, so that namespaces left behind by killed runs can be
+// found and pruned.
+const NamespaceLabel = "app.kubernetes.io/managed-by=chart-testing"
+
+// NewKubectl creates a new Kubectl. DeleteNamespace waits for namespaces to terminate for namespaceDeleteTimeout
+// before force-deleting them. WaitForResourcesDeleted, WaitForAutoscaling, and WaitForCondition wait for resources for
+// waitTimeout. If either timeout is zero, a default of 180 seconds applies. WaitForDeployments does not wait for
+// deployments matching waitExcludeSelector, unless it is empty.
+func NewKubectl(exec exec.ProcessExecutor, extraArgs []string, namespaceDeleteTimeout time.Duration, waitTimeout time.Duration, waitExcludeSelector string) Kubectl {
+	if namespaceDeleteTimeout == 0 {
+		namespaceDeleteTimeout = defaultNamespaceDeleteTimeout
+	}
+	if waitTimeout == 0 {
+		waitTimeout = defaultWaitTimeout
+	}
 	return Kubectl{
-		exec: exec,
+		exec:                   exec,
+		extraArgs:              extraArgs,
+		namespaceDeleteTimeout: namespaceDeleteTimeout,
+		waitTimeout:            waitTimeout,
+		waitExcludeSelector:    waitExcludeSelector,
 	}
 }
 
-// CreateNamespace creates a new namespace with the given name.
+// CreateDockerSecret creates a Docker registry secret with the given name in the namespace from the Docker config
+// JSON file dockerConfig.
+func (k Kubectl) CreateDockerSecret(namespace string, name string, dockerConfig string) error {
+	fmt.Printf("Creating image pull secret '%s' in namespace '%s'...\n", name, namespace)
+	return k.exec.RunProcess("kubectl", "create", "secret", "docker-registry", name, "--namespace", namespace,
+		fmt.Sprintf("--from-file=.dockerconfigjson=%s", dockerConfig), k.extraArgs)
+}
+
+// CreateServiceAccount creates a service account with the given name in the namespace.
+func (k Kubectl) CreateServiceAccount(namespace string, name string) error {
+	fmt.Printf("Creating service account '%s' in namespace '%s'...\n", name, namespace)
+	return k.exec.RunProcess("kubectl", "create", "serviceaccount", name, "--namespace", namespace, k.extraArgs)
+}
+
+// BindClusterRole binds the cluster role to the service account within the namespace using a role binding named
+// after the service account.
+func (k Kubectl) BindClusterRole(namespace string, serviceAccount string, clusterRole string) error {
+	fmt.Printf("Binding cluster role '%s' to service account '%s' in namespace '%s'...\n", clusterRole, serviceAccount, namespace)
+	return k.exec.RunProcess("kubectl", "create", "rolebinding", serviceAccount, "--namespace", namespace,
+		"--clusterrole", clusterRole, "--serviceaccount", fmt.Sprintf("%s:%s", namespace, serviceAccount), k.extraArgs)
+}
+
+// CreateNamespace creates a new namespace with the given name. The namespace is labeled with NamespaceLabel in the
+// same request, so that it cannot exist without the label, which would prevent it from being pruned.
 func (k Kubectl) CreateNamespace(namespace string) error {
 	fmt.Printf("Creating namespace '%s'...\n", namespace)
-	return k.exec.RunProcess("kubectl", "create", "namespace", namespace)
+	labelSlice := strings.SplitN(NamespaceLabel, "=", 2)
+	manifest, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata": map[string]interface{}{
+			"name":   namespace,
+			"labels": map[string]string{labelSlice[0]: labelSlice[1]},
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "Error creating namespace manifest")
+	}
+
+	file, err := ioutil.TempFile("", "ct-namespace-*.json")
+	if err != nil {
+		return errors.Wrap(err, "Error creating namespace manifest")
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(manifest)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrap(err, "Error writing namespace manifest")
+	}
+
+	return k.exec.RunProcess("kubectl", "create", "--filename", file.Name(), k.extraArgs)
+}
+
+// CheckResourceExists checks whether the resource, formatted as 'kind/name', exists in the namespace. Cluster-scoped
+// resources are found regardless of the namespace.
+func (k Kubectl) CheckResourceExists(namespace string, resource string) (bool, error) {
+	output, err := k.exec.RunProcessAndCaptureOutput("kubectl", "get", resource, "--namespace", namespace,
+		"--ignore-not-found", "--output=name", k.extraArgs)
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(output) != "", nil
+}
+
+// GetNamespaces returns the namespaces matching the selector, mapped to their creation time.
+func (k Kubectl) GetNamespaces(selector string) (map[string]time.Time, error) {
+	output, err := k.exec.RunProcessAndCaptureOutput("kubectl", "get", "namespaces", "--selector", selector, "--output",
+		`jsonpath={range .items[*]}{.metadata.name}{" "}{.metadata.creationTimestamp}{"\n"}{end}`, k.extraArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces := map[string]time.Time{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		created, err := time.Parse(time.RFC3339, fields[1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error parsing creation time of namespace '%s'", fields[0])
+		}
+		namespaces[fields[0]] = created
+	}
+	return namespaces, nil
+}
+
+// GetReleases returns the names of the Helm releases in the namespace, which Helm stores in secrets labeled with
+// 'owner=helm'.
+func (k Kubectl) GetReleases(namespace string) ([]string, error) {
+	output, err := k.exec.RunProcessAndCaptureOutput("kubectl", "get", "secrets", "--namespace", namespace, "--selector",
+		"owner=helm", "--output", "jsonpath={.items[*].metadata.labels.name}", k.extraArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []string
+	for _, release := range strings.Fields(output) {
+		if !util.StringSliceContains(releases, release) {
+			releases = append(releases, release)
+		}
+	}
+	return releases, nil
 }
 
-// DeleteNamespace deletes the specified namespace. If the namespace does not terminate within 120s, pods running in the
-// namespace and, eventually, the namespace itself are force-deleted.
+// DeleteNamespace deletes the specified namespace. If the namespace does not terminate within the namespace delete
+// timeout passed to NewKubectl, pods running in the namespace and, eventually, the namespace itself are force-deleted.
 func (k Kubectl) DeleteNamespace(namespace string) {
 	fmt.Printf("Deleting namespace '%s'...\n", namespace)
-	timeoutSec := "180s"
-	if err := k.exec.RunProcess("kubectl", "delete", "namespace", namespace, "--timeout", timeoutSec); err != nil {
-		fmt.Printf("Namespace '%s' did not terminate after %s.\n", namespace, timeoutSec)
+	timeout := k.namespaceDeleteTimeout.String()
+	if err := k.exec.RunProcess("kubectl", "delete", "namespace", namespace, "--timeout", timeout, k.extraArgs); err != nil {
+		fmt.Printf("Error deleting namespace '%s': %s\n", namespace, err)
 	}
 
 	if k.getNamespace(namespace) {
-		fmt.Printf("Namespace '%s' did not terminate after %s.\n", namespace, timeoutSec)
+		fmt.Printf("Namespace '%s' did not terminate after %s.\n", namespace, timeout)
 
 		fmt.Println("Force-deleting everything...")
-		if err := k.exec.RunProcess("kubectl", "delete", "all", "--namespace", namespace, "--all", "--force", "--grace-period=0"); err != nil {
+		if err := k.exec.RunProcess("kubectl", "delete", "all", "--namespace", namespace, "--all", "--force", "--grace-period=0", k.extraArgs); err != nil {
 			fmt.Printf("Error deleting everything in the namespace %v: %v", namespace, err)
 		}
 
@@ -50,6 +189,7 @@ func (k Kubectl) DeleteNamespace(namespace string) {
 		time.Sleep(5 * time.Second)
 
 		if k.getNamespace(namespace) {
+			fmt.Printf("WARNING: Removing finalizers from namespace '%s'. Resources they protect may be left behind.\n", namespace)
 			if err := k.forceNamespaceDeletion(namespace); err != nil {
 				fmt.Println("Error force deleting namespace:", err)
 			}
@@ -59,7 +199,7 @@ func (k Kubectl) DeleteNamespace(namespace string) {
 
 func (k Kubectl) forceNamespaceDeletion(namespace string) error {
 	// Getting the namespace json to remove the finalizer
-	cmdOutput, err := k.exec.RunProcessAndCaptureOutput("kubectl", "get", "namespace", namespace, "--output=json")
+	cmdOutput, err := k.exec.RunProcessAndCaptureOutput("kubectl", "get", "namespace", namespace, "--output=json", k.extraArgs)
 	if err != nil {
 		fmt.Println("Error getting namespace json:", err)
 		return err
@@ -102,7 +242,7 @@ func (k Kubectl) forceNamespaceDeletion(namespace string) error {
 		return nil
 	}
 
-	err = k.exec.RunWithProxy(fun)
+	err = k.exec.RunWithProxy(fun, k.extraArgs...)
 	if err != nil {
 		return errors.Wrapf(err, "Cannot force-delete namespace '%s'", namespace)
 	}
@@ -111,13 +251,13 @@ func (k Kubectl) forceNamespaceDeletion(namespace string) error {
 	time.Sleep(5 * time.Second)
 
 	// Check again
-	if _, err := k.exec.RunProcessAndCaptureOutput("kubectl", "get", "namespace", namespace); err != nil {
+	if _, err := k.exec.RunProcessAndCaptureOutput("kubectl", "get", "namespace", namespace, k.extraArgs); err != nil {
 		fmt.Printf("Namespace '%s' terminated.\n", namespace)
 		return nil
 	}
 
 	fmt.Printf("Force-deleting namespace '%s'...\n", namespace)
-	if err := k.exec.RunProcess("kubectl", "delete", "namespace", namespace, "--force", "--grace-period=0", "--ignore-not-found=true"); err != nil {
+	if err := k.exec.RunProcess("kubectl", "delete", "namespace", namespace, "--force", "--grace-period=0", "--ignore-not-found=true", k.extraArgs); err != nil {
 		fmt.Println("Error deleting namespace:", err)
 		return err
 	}
@@ -127,15 +267,26 @@ func (k Kubectl) forceNamespaceDeletion(namespace string) error {
 
 func (k Kubectl) WaitForDeployments(namespace string, selector string) error {
 	output, err := k.exec.RunProcessAndCaptureOutput(
-		"kubectl", "get", "deployments", "--namespace", namespace, "--selector", selector, "--output", "jsonpath={.items[*].metadata.name}")
+		"kubectl", "get", "deployments", "--namespace", namespace, "--selector", selector, "--output", "jsonpath={.items[*].metadata.name}", k.extraArgs)
 	if err != nil {
 		return err
 	}
 
-	deployments := strings.Fields(output)
+	var excludedOutput string
+	if k.waitExcludeSelector != "" {
+		excludedOutput, err = k.exec.RunProcessAndCaptureOutput("kubectl", "get", "deployments", "--namespace", namespace,
+			"--selector", k.waitExcludeSelector, "--output", "jsonpath={.items[*].metadata.name}", k.extraArgs)
+		if err != nil {
+			return err
+		}
+	}
+
+	deployments, excluded := deploymentsToWaitFor(output, excludedOutput)
+	for _, deployment := range excluded {
+		fmt.Printf("Not waiting for deployment '%s' matching '%s'\n", deployment, k.waitExcludeSelector)
+	}
 	for _, deployment := range deployments {
-		deployment = strings.Trim(deployment, "'")
-		err := k.exec.RunProcess("kubectl", "rollout", "status", "deployment", deployment, "--namespace", namespace)
+		err := k.exec.RunProcess("kubectl", "rollout", "status", "deployment", deployment, "--namespace", namespace, k.extraArgs)
 		if err != nil {
 			return err
 		}
@@ -146,7 +297,7 @@ func (k Kubectl) WaitForDeployments(namespace string, selector string) error {
 		// Just after rollout, pods from the previous deployment revision may still be in a
 		// terminating state.
 		unavailable, err := k.exec.RunProcessAndCaptureOutput("kubectl", "get", "deployment", deployment, "--namespace", namespace, "--output",
-			`jsonpath={.status.unavailableReplicas}`)
+			`jsonpath={.status.unavailableReplicas}`, k.extraArgs)
 		if err != nil {
 			return err
 		}
@@ -158,8 +309,200 @@ func (k Kubectl) WaitForDeployments(namespace string, selector string) error {
 	return nil
 }
 
+// deploymentsToWaitFor splits the deployments listed in the output of 'kubectl get deployments' into those to wait for
+// and those excluded, i.e. also listed in excludedOutput.
+func deploymentsToWaitFor(output string, excludedOutput string) ([]string, []string) {
+	excludedDeployments := map[string]bool{}
+	for _, deployment := range strings.Fields(excludedOutput) {
+		excludedDeployments[strings.Trim(deployment, "'")] = true
+	}
+
+	var deployments, excluded []string
+	for _, deployment := range strings.Fields(output) {
+		deployment = strings.Trim(deployment, "'")
+		if excludedDeployments[deployment] {
+			excluded = append(excluded, deployment)
+		} else {
+			deployments = append(deployments, deployment)
+		}
+	}
+	return deployments, excluded
+}
+
+// ListNamespacedResources returns the resources matching the selector in the namespace, formatted as 'type/name', of
+// all resource types which can be listed and deleted, except events. If selector is empty, all of them are returned.
+func (k Kubectl) ListNamespacedResources(namespace string, selector string) ([]string, error) {
+	types, err := k.namespacedResourceTypes()
+	if err != nil {
+		return nil, err
+	}
+	return k.getNamespacedResources(types, namespace, selector)
+}
+
+// WaitForResourcesDeleted waits until no resources matching the selector are left in the namespace, of all resource
+// types which can be listed and deleted, except events. Resources listed in ignored, e.g. those which existed before
+// a release was installed, and the resources Kubernetes creates in every namespace are not waited for. If selector is
+// empty, all resources in the namespace are waited for.
+func (k Kubectl) WaitForResourcesDeleted(namespace string, selector string, ignored []string) error {
+	fmt.Printf("Waiting for resources in namespace '%s' to be deleted...\n", namespace)
+	types, err := k.namespacedResourceTypes()
+	if err != nil {
+		return err
+	}
+
+	var remaining []string
+	deleted, err := k.poll(func() (bool, error) {
+		resources, err := k.getNamespacedResources(types, namespace, selector)
+		if err != nil {
+			return false, err
+		}
+		remaining = remainingResources(resources, ignored)
+		return len(remaining) == 0, nil
+	})
+	if err != nil || deleted {
+		return err
+	}
+
+	return fmt.Errorf("resources in namespace '%s' were not deleted after %s: %s", namespace, k.waitTimeout,
+		strings.Join(remaining, ", "))
+}
+
+// namespacedResourceTypes returns the namespaced resource types which can be listed and deleted, except events.
+func (k Kubectl) namespacedResourceTypes() ([]string, error) {
+	resourceTypes, err := k.listResourceTypes("--namespaced=true", "--verbs=list,delete")
+	if err != nil {
+		return nil, errors.Wrap(err, "Error listing namespaced resource types")
+	}
+	var types []string
+	for _, resourceType := range resourceTypes {
+		if resourceType != "events" && resourceType != "events.events.k8s.io" {
+			types = append(types, resourceType)
+		}
+	}
+	return types, nil
+}
+
+// getNamespacedResources returns the resources of the given types matching the selector in the namespace, formatted
+// as 'type/name'.
+func (k Kubectl) getNamespacedResources(types []string, namespace string, selector string) ([]string, error) {
+	if len(types) == 0 {
+		return nil, nil
+	}
+	var selectorArgs []string
+	if selector != "" {
+		selectorArgs = []string{"--selector", selector}
+	}
+	output, err := k.exec.RunProcessAndCaptureOutput("kubectl", "get", strings.Join(types, ","),
+		"--namespace", namespace, selectorArgs, "--output=name", "--ignore-not-found", k.extraArgs)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(output), nil
+}
+
+// remainingResources returns the resources except those listed in ignored and those Kubernetes creates in every
+// namespace.
+func remainingResources(resources []string, ignored []string) []string {
+	var remaining []string
+	for _, resource := range resources {
+		if !util.StringSliceContains(namespaceDefaultResources, resource) && !util.StringSliceContains(ignored, resource) {
+			remaining = append(remaining, resource)
+		}
+	}
+	return remaining
+}
+
+// poll calls done every pollInterval until it returns true or an error, or the wait timeout has passed. It returns
+// whether done returned true before the timeout.
+func (k Kubectl) poll(done func() (bool, error)) (bool, error) {
+	for start := time.Now(); time.Since(start) < k.waitTimeout; time.Sleep(pollInterval) {
+		ok, err := done()
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// WaitForAutoscaling waits until the horizontal pod autoscalers matching the selector in the namespace run the
+// desired number of replicas, which is at least their minimum, and the pod disruption budgets have as many healthy
+// pods as they require. If selector is empty, all of them in the namespace are waited for.
+func (k Kubectl) WaitForAutoscaling(namespace string, selector string) error {
+	fmt.Printf("Waiting for autoscaling resources in namespace '%s' to become ready...\n", namespace)
+	var selectorArgs []string
+	if selector != "" {
+		selectorArgs = []string{"--selector", selector}
+	}
+
+	var notReady []string
+	ready, err := k.poll(func() (bool, error) {
+		output, err := k.exec.RunProcessAndCaptureOutput("kubectl", "get", "horizontalpodautoscalers,poddisruptionbudgets",
+			"--namespace", namespace, selectorArgs, "--output=json", k.extraArgs)
+		if err != nil {
+			return false, err
+		}
+		if notReady, err = notReadyAutoscalingResources(output); err != nil {
+			return false, err
+		}
+		return len(notReady) == 0, nil
+	})
+	if err != nil || ready {
+		return err
+	}
+
+	return fmt.Errorf("autoscaling resources in namespace '%s' were not ready after %s: %s", namespace, k.waitTimeout,
+		strings.Join(notReady, ", "))
+}
+
+// notReadyAutoscalingResources returns the horizontal pod autoscalers and pod disruption budgets in the JSON output of
+// 'kubectl get' which are not ready, along with their status.
+func notReadyAutoscalingResources(output string) ([]string, error) {
+	var list struct {
+		Items []struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Spec struct {
+				MinReplicas *int `json:"minReplicas"`
+			} `json:"spec"`
+			Status struct {
+				CurrentReplicas int `json:"currentReplicas"`
+				DesiredReplicas int `json:"desiredReplicas"`
+				CurrentHealthy  int `json:"currentHealthy"`
+				DesiredHealthy  int `json:"desiredHealthy"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(output), &list); err != nil {
+		return nil, errors.Wrap(err, "Error parsing autoscaling resources")
+	}
+
+	var notReady []string
+	for _, item := range list.Items {
+		status := item.Status
+		switch item.Kind {
+		case "HorizontalPodAutoscaler":
+			minReplicas := 1
+			if item.Spec.MinReplicas != nil {
+				minReplicas = *item.Spec.MinReplicas
+			}
+			if status.CurrentReplicas != status.DesiredReplicas || status.CurrentReplicas < minReplicas {
+				notReady = append(notReady, fmt.Sprintf("horizontalpodautoscaler/%s (%d of %d desired replicas, minimum %d)",
+					item.Metadata.Name, status.CurrentReplicas, status.DesiredReplicas, minReplicas))
+			}
+		case "PodDisruptionBudget":
+			if status.CurrentHealthy < status.DesiredHealthy {
+				notReady = append(notReady, fmt.Sprintf("poddisruptionbudget/%s (%d of %d desired healthy pods)",
+					item.Metadata.Name, status.CurrentHealthy, status.DesiredHealthy))
+			}
+		}
+	}
+	return notReady, nil
+}
+
 func (k Kubectl) GetPodsforDeployment(namespace string, deployment string) ([]string, error) {
-	jsonString, _ := k.exec.RunProcessAndCaptureOutput("kubectl", "get", "deployment", deployment, "--namespace", namespace, "--output=json")
+	jsonString, _ := k.exec.RunProcessAndCaptureOutput("kubectl", "get", "deployment", deployment, "--namespace", namespace, "--output=json", k.extraArgs)
 	var deploymentMap map[string]interface{}
 	err := json.Unmarshal([]byte(jsonString), &deploymentMap)
 	if err != nil {
@@ -183,23 +526,55 @@ func (k Kubectl) GetPodsforDeployment(namespace string, deployment string) ([]st
 func (k Kubectl) GetPods(args ...string) ([]string, error) {
 	kubectlArgs := []string{"get", "pods"}
 	kubectlArgs = append(kubectlArgs, args...)
-	pods, err := k.exec.RunProcessAndCaptureOutput("kubectl", kubectlArgs)
+	pods, err := k.exec.RunProcessAndCaptureOutput("kubectl", kubectlArgs, k.extraArgs)
 	if err != nil {
 		return nil, err
 	}
 	return strings.Fields(pods), nil
 }
 
-func (k Kubectl) GetEvents(namespace string) error {
-	return k.exec.RunProcess("kubectl", "get", "events", "--output", "wide", "--namespace", namespace)
+// GetEvents returns the events in the namespace. The output is returned also if getting them fails.
+func (k Kubectl) GetEvents(namespace string) (string, error) {
+	return k.exec.RunProcessAndCaptureCombinedOutput("kubectl", "get", "events", "--output", "wide", "--namespace", namespace, k.extraArgs)
 }
 
-func (k Kubectl) DescribePod(namespace string, pod string) error {
-	return k.exec.RunProcess("kubectl", "describe", "pod", pod, "--namespace", namespace)
+// GetAll returns all resources in the namespace. The output is returned also if getting them fails.
+func (k Kubectl) GetAll(namespace string) (string, error) {
+	return k.exec.RunProcessAndCaptureCombinedOutput("kubectl", "get", "all", "--output", "wide", "--namespace", namespace, k.extraArgs)
 }
 
-func (k Kubectl) Logs(namespace string, pod string, container string) error {
-	return k.exec.RunProcess("kubectl", "logs", pod, "--namespace", namespace, "--container", container)
+// GetNonReadyPods returns the names of all pods in the namespace whose 'Ready' condition is not 'True'.
+func (k Kubectl) GetNonReadyPods(namespace string) ([]string, error) {
+	output, err := k.exec.RunProcessAndCaptureOutput("kubectl", "get", "pods", "--namespace", namespace, "--output",
+		`jsonpath={range .items[*]}{.metadata.name}{" "}{.status.conditions[?(@.type=="Ready")].status}{"\n"}{end}`, k.extraArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	var pods []string
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 || fields[1] != "True" {
+			pods = append(pods, fields[0])
+		}
+	}
+	return pods, nil
+}
+
+func (k Kubectl) DescribePod(namespace string, pod string) (string, error) {
+	return k.exec.RunProcessAndCaptureCombinedOutput("kubectl", "describe", "pod", pod, "--namespace", namespace, k.extraArgs)
+}
+
+// Logs returns the logs of the container, limited to the last tail lines if tail is greater than 0.
+func (k Kubectl) Logs(namespace string, pod string, container string, tail int) (string, error) {
+	var tailArgs []string
+	if tail > 0 {
+		tailArgs = []string{"--tail", strconv.Itoa(tail)}
+	}
+	return k.exec.RunProcessAndCaptureCombinedOutput("kubectl", "logs", pod, "--namespace", namespace, "--container", container, tailArgs, k.extraArgs)
 }
 
 func (k Kubectl) GetInitContainers(namespace string, pod string) ([]string, error) {
@@ -210,8 +585,115 @@ func (k Kubectl) GetContainers(namespace string, pod string) ([]string, error) {
 	return k.GetPods(pod, "--no-headers", "--namespace", namespace, "--output", "jsonpath={.spec.containers[*].name}")
 }
 
+// GetFailedContainers returns the names of the init containers and containers of the pod which failed, i.e. which
+// are neither ready nor completed successfully, or which have been restarted.
+func (k Kubectl) GetFailedContainers(namespace string, pod string) ([]string, error) {
+	output, err := k.exec.RunProcessAndCaptureOutput("kubectl", "get", "pod", pod, "--namespace", namespace, "--output=json", k.extraArgs)
+	if err != nil {
+		return nil, err
+	}
+	return failedContainers(output)
+}
+
+// failedContainers returns the init containers and containers in the JSON output of 'kubectl get pod' which failed.
+func failedContainers(output string) ([]string, error) {
+	type containerStatus struct {
+		Name         string `json:"name"`
+		Ready        bool   `json:"ready"`
+		RestartCount int    `json:"restartCount"`
+		State        struct {
+			Terminated *struct {
+				ExitCode int `json:"exitCode"`
+			} `json:"terminated"`
+		} `json:"state"`
+	}
+	var pod struct {
+		Status struct {
+			InitContainerStatuses []containerStatus `json:"initContainerStatuses"`
+			ContainerStatuses     []containerStatus `json:"containerStatuses"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal([]byte(output), &pod); err != nil {
+		return nil, errors.Wrap(err, "Error parsing pod")
+	}
+
+	var failed []string
+	for _, status := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+		succeeded := status.State.Terminated != nil && status.State.Terminated.ExitCode == 0
+		if (!status.Ready && !succeeded) || status.RestartCount > 0 {
+			failed = append(failed, status.Name)
+		}
+	}
+	return failed, nil
+}
+
+// DeleteClusterResources deletes all cluster-scoped resources matching the selector, of all resource types which can
+// be listed and deleted.
+func (k Kubectl) DeleteClusterResources(selector string) error {
+	resourceTypes, err := k.listResourceTypes("--namespaced=false", "--verbs=list,delete")
+	if err != nil {
+		return errors.Wrap(err, "Error listing cluster-scoped resource types")
+	}
+	if len(resourceTypes) == 0 {
+		return nil
+	}
+
+	fmt.Printf("Deleting cluster-scoped resources matching '%s'...\n", selector)
+	return k.exec.RunProcess("kubectl", "delete", strings.Join(resourceTypes, ","), "--selector", selector,
+		"--ignore-not-found", k.extraArgs)
+}
+
+// ListResourcesBySelector lists all resources matching the selector in all namespaces as well as cluster-scoped ones,
+// of all resource types which can be listed, each formatted as 'type/name'.
+func (k Kubectl) ListResourcesBySelector(selector string) ([]string, error) {
+	resourceTypes, err := k.listResourceTypes("--verbs=list")
+	if err != nil {
+		return nil, errors.Wrap(err, "Error listing resource types")
+	}
+	if len(resourceTypes) == 0 {
+		return nil, nil
+	}
+
+	output, err := k.exec.RunProcessAndCaptureOutput("kubectl", "get", strings.Join(resourceTypes, ","), "--all-namespaces",
+		"--selector", selector, "--output=name", "--ignore-not-found", k.extraArgs)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error listing resources matching '%s'", selector)
+	}
+	return strings.Fields(output), nil
+}
+
+// listResourceTypes returns the names of the resource types supported by the cluster, filtered with the given
+// 'kubectl api-resources' flags.
+func (k Kubectl) listResourceTypes(flags ...string) ([]string, error) {
+	output, err := k.exec.RunProcessAndCaptureOutput("kubectl", "api-resources", flags, "--output=name", k.extraArgs)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(output), nil
+}
+
+func (k Kubectl) ApplyManifests(files []string) error {
+	return k.exec.RunProcess("kubectl", "apply", filenameArgs(files), k.extraArgs)
+}
+
+// WaitForCondition waits up to the wait timeout for the resources in the files to meet the condition, e.g.
+// 'Established' for CRDs.
+func (k Kubectl) WaitForCondition(condition string, files []string) error {
+	return k.exec.RunProcess("kubectl", "wait", "--for", "condition="+condition, "--timeout", k.waitTimeout.String(),
+		filenameArgs(files), k.extraArgs)
+}
+
+// filenameArgs returns a '--filename' argument for each of the files.
+func filenameArgs(files []string) []string {
+	var args []string
+	for _, file := range files {
+		args = append(args, "--filename", file)
+	}
+	return args
+}
+
 func (k Kubectl) getNamespace(namespace string) bool {
-	if _, err := k.exec.RunProcessAndCaptureOutput("kubectl", "get", "namespace", namespace); err != nil {
+	if _, err := k.exec.RunProcessAndCaptureOutput("kubectl", "get", "namespace", namespace, k.extraArgs); err != nil {
 		fmt.Printf("Namespace '%s' terminated.\n", namespace)
 		return false
 	}
@@ -0,0 +1,165 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tool
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helm/chart-testing/v3/pkg/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotReadyAutoscalingResources(t *testing.T) {
+	output := `{"items": [
+		{"kind": "HorizontalPodAutoscaler", "metadata": {"name": "ready"}, "spec": {"minReplicas": 2}, "status": {"currentReplicas": 2, "desiredReplicas": 2}},
+		{"kind": "HorizontalPodAutoscaler", "metadata": {"name": "scaling"}, "spec": {"minReplicas": 2}, "status": {"currentReplicas": 2, "desiredReplicas": 3}},
+		{"kind": "HorizontalPodAutoscaler", "metadata": {"name": "new"}, "spec": {}, "status": {}},
+		{"kind": "PodDisruptionBudget", "metadata": {"name": "satisfied"}, "status": {"currentHealthy": 2, "desiredHealthy": 1}},
+		{"kind": "PodDisruptionBudget", "metadata": {"name": "unsatisfied"}, "status": {"currentHealthy": 0, "desiredHealthy": 1}}
+	]}`
+
+	notReady, err := notReadyAutoscalingResources(output)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"horizontalpodautoscaler/scaling (2 of 3 desired replicas, minimum 2)",
+		"horizontalpodautoscaler/new (0 of 0 desired replicas, minimum 1)",
+		"poddisruptionbudget/unsatisfied (0 of 1 desired healthy pods)",
+	}, notReady)
+
+	_, err = notReadyAutoscalingResources("error")
+	assert.NotNil(t, err)
+}
+
+func TestFailedContainers(t *testing.T) {
+	output := `{"status": {
+		"initContainerStatuses": [
+			{"name": "migrate", "ready": true, "restartCount": 0, "state": {"terminated": {"exitCode": 0}}},
+			{"name": "init-failed", "ready": false, "restartCount": 0, "state": {"terminated": {"exitCode": 1}}}
+		],
+		"containerStatuses": [
+			{"name": "main", "ready": false, "restartCount": 0, "state": {"waiting": {"reason": "CrashLoopBackOff"}}},
+			{"name": "sidecar", "ready": true, "restartCount": 0, "state": {"running": {}}},
+			{"name": "restarted", "ready": true, "restartCount": 2, "state": {"running": {}}},
+			{"name": "completed", "ready": false, "restartCount": 0, "state": {"terminated": {"exitCode": 0}}}
+		]
+	}}`
+
+	failed, err := failedContainers(output)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"init-failed", "main", "restarted"}, failed)
+
+	_, err = failedContainers("error")
+	assert.NotNil(t, err)
+}
+
+func TestDeploymentsToWaitFor(t *testing.T) {
+	deployments, excluded := deploymentsToWaitFor("'web' 'worker' 'test-server'", "'test-server'")
+	assert.Equal(t, []string{"web", "worker"}, deployments)
+	assert.Equal(t, []string{"test-server"}, excluded)
+
+	deployments, excluded = deploymentsToWaitFor("web worker", "")
+	assert.Equal(t, []string{"web", "worker"}, deployments)
+	assert.Empty(t, excluded)
+}
+
+// fakeKubectlScript lists a PVC until it has been listed the given number of times, besides an image pull secret and
+// the resources Kubernetes creates in every namespace. It records the arguments of 'kubectl get' in 'get-args' and
+// the manifest passed to 'kubectl create' in 'created'.
+const fakeKubectlScript = `#!/bin/sh
+dir=$(dirname "$0")
+case "$1" in
+api-resources)
+	printf 'configmaps\nevents\npersistentvolumeclaims\npods\nsecrets\n'
+	;;
+get)
+	echo "$@" > "$dir/get-args"
+	count=$(($(cat "$dir/count" 2>/dev/null || echo 0) + 1))
+	echo "$count" > "$dir/count"
+	if [ "$count" -le "$PVC_LISTED" ]; then
+		echo persistentvolumeclaim/data
+	fi
+	printf 'configmap/kube-root-ca.crt\nsecret/regcred\nserviceaccount/default\n'
+	;;
+create)
+	cat "$3" > "$dir/created"
+	;;
+esac
+`
+
+func TestWaitForResourcesDeleted(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake kubectl is a shell script")
+	}
+
+	dir := t.TempDir()
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "kubectl"), []byte(fakeKubectlScript), 0755))
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	defer os.Unsetenv("PVC_LISTED")
+	defer func(interval time.Duration) { pollInterval = interval }(pollInterval)
+	pollInterval = time.Millisecond
+
+	kubectl := NewKubectl(exec.NewProcessExecutor(false), nil, 0, 10*time.Second, "")
+
+	os.Setenv("PVC_LISTED", "2")
+	assert.Nil(t, kubectl.WaitForResourcesDeleted("foo", "app.kubernetes.io/instance=bar", []string{"secret/regcred"}))
+	count, err := ioutil.ReadFile(filepath.Join(dir, "count"))
+	assert.Nil(t, err)
+	assert.Equal(t, "3", strings.TrimSpace(string(count)))
+	args, err := ioutil.ReadFile(filepath.Join(dir, "get-args"))
+	assert.Nil(t, err)
+	assert.Equal(t, "get configmaps,persistentvolumeclaims,pods,secrets --namespace foo --selector app.kubernetes.io/instance=bar --output=name --ignore-not-found",
+		strings.TrimSpace(string(args)))
+
+	kubectl = NewKubectl(exec.NewProcessExecutor(false), nil, 0, 50*time.Millisecond, "")
+	os.Setenv("PVC_LISTED", "1000000")
+	err = kubectl.WaitForResourcesDeleted("foo", "", nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "were not deleted after 50ms: persistentvolumeclaim/data, secret/regcred")
+
+	resources, err := kubectl.ListNamespacedResources("foo", "")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"persistentvolumeclaim/data", "configmap/kube-root-ca.crt", "secret/regcred", "serviceaccount/default"}, resources)
+	args, err = ioutil.ReadFile(filepath.Join(dir, "get-args"))
+	assert.Nil(t, err)
+	assert.Equal(t, "get configmaps,persistentvolumeclaims,pods,secrets --namespace foo --output=name --ignore-not-found",
+		strings.TrimSpace(string(args)))
+}
+
+func TestCreateNamespace(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake kubectl is a shell script")
+	}
+
+	dir := t.TempDir()
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "kubectl"), []byte(fakeKubectlScript), 0755))
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+
+	kubectl := NewKubectl(exec.NewProcessExecutor(false), nil, 0, 0, "")
+	assert.Nil(t, kubectl.CreateNamespace("foo"))
+	created, err := ioutil.ReadFile(filepath.Join(dir, "created"))
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": "foo", "labels": {"app.kubernetes.io/managed-by": "chart-testing"}}}`,
+		string(created))
+}
@@ -14,7 +14,15 @@
 
 package tool
 
-import "github.com/helm/chart-testing/v3/pkg/exec"
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/helm/chart-testing/v3/pkg/exec"
+	"github.com/pkg/errors"
+)
 
 type Linter struct {
 	exec exec.ProcessExecutor
@@ -33,3 +41,43 @@ func (l Linter) YamlLint(yamlFile string, configFile string) error {
 func (l Linter) Yamale(yamlFile string, schemaFile string) error {
 	return l.exec.RunProcess("yamale", "--schema", schemaFile, yamlFile)
 }
+
+// Conftest runs `conftest test` against the rendered manifests. If policies are violated, the returned error lists the
+// violations, each formatted as 'namespace - message'.
+func (l Linter) Conftest(manifest string, policyDir string) error {
+	manifestFile, err := ioutil.TempFile("", "ct_manifest_*.yaml")
+	if err != nil {
+		return errors.Wrap(err, "Error creating manifest file")
+	}
+	defer os.Remove(manifestFile.Name())
+	if _, err := manifestFile.WriteString(manifest); err != nil {
+		manifestFile.Close()
+		return errors.Wrap(err, "Error writing manifest file")
+	}
+	if err := manifestFile.Close(); err != nil {
+		return errors.Wrap(err, "Error writing manifest file")
+	}
+
+	output, err := l.exec.RunProcessAndCaptureCombinedOutput("conftest", "test", "--no-color", "--policy", policyDir,
+		manifestFile.Name())
+	if err == nil {
+		return nil
+	}
+	if violations := conftestViolations(output, manifestFile.Name()); len(violations) > 0 {
+		return fmt.Errorf("%d policy violation(s): %s", len(violations), strings.Join(violations, "; "))
+	}
+	return errors.Wrapf(err, "Error running conftest: %s", output)
+}
+
+// conftestViolations returns the failures reported in the output of `conftest test`, e.g.
+// 'FAIL - manifest.yaml - main - message', without the status and the file name.
+func conftestViolations(output string, file string) []string {
+	var violations []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, "FAIL - ") {
+			violation := strings.TrimPrefix(line, "FAIL - ")
+			violations = append(violations, strings.TrimPrefix(violation, file+" - "))
+		}
+	}
+	return violations
+}
@@ -0,0 +1,32 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tool
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConftestViolations(t *testing.T) {
+	output := `FAIL - /tmp/ct_manifest_1.yaml - main - Containers must not run as root
+WARN - /tmp/ct_manifest_1.yaml - main - Deployments should have resource limits
+FAIL - /tmp/ct_manifest_1.yaml - security - Images must not use the latest tag
+
+3 tests, 0 passed, 1 warning, 2 failures, 0 exceptions`
+	assert.Equal(t, []string{"main - Containers must not run as root", "security - Images must not use the latest tag"},
+		conftestViolations(output, "/tmp/ct_manifest_1.yaml"))
+	assert.Empty(t, conftestViolations("Error: running test: load: loading policies: no policies found", "/tmp/ct_manifest_1.yaml"))
+}
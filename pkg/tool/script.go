@@ -0,0 +1,31 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tool
+
+import "github.com/helm/chart-testing/v3/pkg/exec"
+
+type ScriptRunner struct {
+	exec exec.ProcessExecutor
+}
+
+func NewScriptRunner(exec exec.ProcessExecutor) ScriptRunner {
+	return ScriptRunner{
+		exec: exec,
+	}
+}
+
+func (r ScriptRunner) Run(script string, env []string) error {
+	return r.exec.RunProcessWithEnv(env, "sh", script)
+}
@@ -39,11 +39,24 @@ type Maintainer struct {
 	Email string `yaml:"email"`
 }
 
+type Dependency struct {
+	Name       string `yaml:"name"`
+	Version    string `yaml:"version"`
+	Repository string `yaml:"repository"`
+	Alias      string `yaml:"alias"`
+}
+
 type ChartYaml struct {
-	Name        string `yaml:"name"`
-	Version     string `yaml:"version"`
-	Deprecated  bool   `yaml:"deprecated"`
-	Maintainers []Maintainer
+	ApiVersion   string `yaml:"apiVersion"`
+	Name         string `yaml:"name"`
+	Version      string `yaml:"version"`
+	AppVersion   string `yaml:"appVersion"`
+	Description  string `yaml:"description"`
+	Type         string `yaml:"type"`
+	Deprecated   bool   `yaml:"deprecated"`
+	Maintainers  []Maintainer
+	Dependencies []Dependency      `yaml:"dependencies"`
+	Annotations  map[string]string `yaml:"annotations"`
 }
 
 func Flatten(items []interface{}) ([]string, error) {
@@ -123,15 +136,22 @@ func (l DirectoryLister) ListChildDirs(parentDir string, test func(dir string) b
 	return dirs, nil
 }
 
+// NormalizePath converts p into a clean, slash-separated path. Backslashes are treated as separators regardless of
+// the operating system, so paths from Windows runners are handled the same way as paths from other systems.
+func NormalizePath(p string) string {
+	return path.Clean(strings.ReplaceAll(p, `\`, "/"))
+}
+
 type ChartUtils struct{}
 
 func (u ChartUtils) LookupChartDir(chartDirs []string, dir string) (string, error) {
+	dir = NormalizePath(dir)
 	for _, chartDir := range chartDirs {
+		chartDir = NormalizePath(chartDir) // also removes any trailing slash from the dir
 		currentDir := dir
 		for {
 			chartYaml := path.Join(currentDir, "Chart.yaml")
 			parent := path.Dir(path.Dir(chartYaml))
-			chartDir = strings.TrimRight(chartDir, "/") // remove any trailing slash from the dir
 
 			// check directory has a Chart.yaml and that it is in a
 			// direct subdirectory of a configured charts directory
@@ -154,7 +174,7 @@ func (u ChartUtils) LookupChartDir(chartDirs []string, dir string) (string, erro
 // and return a newly allocated ChartYaml object. If no Chart.yaml is present
 // or there is an error unmarshaling the file contents, an error will be returned.
 func ReadChartYaml(dir string) (*ChartYaml, error) {
-	yamlBytes, err := ioutil.ReadFile(path.Join(dir, "Chart.yaml"))
+	yamlBytes, err := ioutil.ReadFile(filepath.Join(dir, "Chart.yaml"))
 	if err != nil {
 		return nil, errors.Wrap(err, "Could not read 'Chart.yaml'")
 	}
@@ -171,6 +191,37 @@ func UnmarshalChartYaml(yamlBytes []byte) (*ChartYaml, error) {
 	return chartYaml, nil
 }
 
+// SplitRemoteChart splits a remote chart formatted as 'repo/chart[:version]' into the chart reference and the version,
+// which is empty if not specified.
+func SplitRemoteChart(remoteChart string) (chartRef string, version string) {
+	if i := strings.LastIndex(remoteChart, ":"); i > strings.LastIndex(remoteChart, "/") {
+		return remoteChart[:i], remoteChart[i+1:]
+	}
+	return remoteChart, ""
+}
+
+// ImageRegistry returns the registry of the specified container image reference, which is 'docker.io' for images
+// without explicit registry.
+func ImageRegistry(image string) string {
+	slashIndex := strings.Index(image, "/")
+	if slashIndex < 0 {
+		return "docker.io"
+	}
+	registry := image[:slashIndex]
+	if !strings.ContainsAny(registry, ".:") && registry != "localhost" {
+		return "docker.io"
+	}
+	return registry
+}
+
+// ValidateChartVersion returns an error if version is not a valid semantic version.
+func ValidateChartVersion(version string) error {
+	if _, err := semver.NewVersion(version); err != nil {
+		return fmt.Errorf("Chart version '%s' is not valid SemVer", version)
+	}
+	return nil
+}
+
 func CompareVersions(left string, right string) (int, error) {
 	leftVersion, err := semver.NewVersion(left)
 	if err != nil {
@@ -183,7 +234,42 @@ func CompareVersions(left string, right string) (int, error) {
 	return leftVersion.Compare(rightVersion), nil
 }
 
+// IsPrereleaseBump checks whether right is a later prerelease of the same version as left according to SemVer
+// precedence, e.g. '1.2.0-rc.2' after '1.2.0-rc.1' or '1.2.0-rc.1' after '1.2.0-beta', as opposed to a release of it
+// (e.g. '1.2.0' after '1.2.0-rc.2') or a later version (e.g. '1.3.0-rc.1' after '1.2.0-rc.2').
+func IsPrereleaseBump(left string, right string) (bool, error) {
+	leftVersion, err := semver.NewVersion(left)
+	if err != nil {
+		return false, errors.Wrap(err, "Error parsing semantic version")
+	}
+	rightVersion, err := semver.NewVersion(right)
+	if err != nil {
+		return false, errors.Wrap(err, "Error parsing semantic version")
+	}
+
+	sameRelease := leftVersion.Major() == rightVersion.Major() && leftVersion.Minor() == rightVersion.Minor() &&
+		leftVersion.Patch() == rightVersion.Patch()
+	return sameRelease && rightVersion.Prerelease() != "" && leftVersion.Compare(rightVersion) < 0, nil
+}
+
 func BreakingChangeAllowed(left string, right string) (bool, error) {
+	return BreakingChangeAllowedByRule(left, right, "major")
+}
+
+// BreakingChangeAllowedByRule checks whether the version bump from left to right allows a breaking change according
+// to rule: 'major' allows breaking changes with major version bumps, or minor version bumps below 1.0.0, as specified
+// by SemVer, 'minor' with major or minor version bumps, and 'none' never. An empty rule is treated as 'major'.
+func BreakingChangeAllowedByRule(left string, right string, rule string) (bool, error) {
+	if rule == "" {
+		rule = "major"
+	}
+	if rule == "none" {
+		return false, nil
+	}
+	if rule != "major" && rule != "minor" {
+		return false, fmt.Errorf("invalid version bump rule '%s'", rule)
+	}
+
 	leftVersion, err := semver.NewVersion(left)
 	if err != nil {
 		return false, errors.Wrap(err, "Error parsing semantic version")
@@ -194,7 +280,7 @@ func BreakingChangeAllowed(left string, right string) (bool, error) {
 	}
 
 	constraintOp := "^"
-	if leftVersion.Major() == 0 {
+	if leftVersion.Major() == 0 || rule == "minor" {
 		constraintOp = "~"
 	}
 	c, err := semver.NewConstraint(fmt.Sprintf("%s %s", constraintOp, leftVersion.String()))
@@ -210,12 +296,32 @@ func BreakingChangeAllowed(left string, right string) (bool, error) {
 	return !minor, err
 }
 
+// delimiterWidth is the width of lines printed by PrintDelimiterLine. Zero means the width is determined automatically.
+var delimiterWidth int
+
+// SetDelimiterWidth sets the width of lines printed by PrintDelimiterLine. If width is zero, lines are 120 characters
+// wide if stdout is a terminal and 80 characters wide otherwise, e.g. when piped to a file or a CI log.
+func SetDelimiterWidth(width int) {
+	delimiterWidth = width
+}
+
 func PrintDelimiterLine(delimiterChar string) {
-	delim := make([]string, 120)
-	for i := 0; i < 120; i++ {
-		delim[i] = delimiterChar
+	fmt.Println(DelimiterLine(delimiterChar))
+}
+
+// DelimiterLine returns a delimiter line of the configured width, as printed by PrintDelimiterLine.
+func DelimiterLine(delimiterChar string) string {
+	return strings.Repeat(delimiterChar, getDelimiterWidth())
+}
+
+func getDelimiterWidth() int {
+	if delimiterWidth > 0 {
+		return delimiterWidth
+	}
+	if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		return 120
 	}
-	fmt.Println(strings.Join(delim, ""))
+	return 80
 }
 
 func SanitizeName(s string, maxLength int) string {
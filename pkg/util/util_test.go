@@ -33,7 +33,7 @@ func TestFlatten(t *testing.T) {
 	}
 
 	for index, testData := range testDataSlice {
-		t.Run(string(index), func(t *testing.T) {
+		t.Run(fmt.Sprint(index), func(t *testing.T) {
 			actual, err := Flatten(testData.input)
 			assert.Equal(t, testData.expected, actual)
 			if testData.expected != nil {
@@ -58,16 +58,97 @@ func TestCompareVersions(t *testing.T) {
 		{"1", "2", -1},
 		{"3", "3", 0},
 		{"3-alpha", "3-beta", -1},
+		{"1.2.0-rc.1", "1.2.0-rc.2", -1},
+		{"1.2.0-rc.2", "1.2.0-rc.10", -1},
+		{"1.2.0-rc", "1.2.0-rc.1", -1},
+		{"1.2.0-rc.1", "1.2.0", -1},
+		{"1.2.0", "1.2.0-rc.1", 1},
+		{"1.2.0-rc.1+build.2", "1.2.0-rc.1+build.1", 0},
 	}
 
 	for index, testData := range testDataSlice {
-		t.Run(string(index), func(t *testing.T) {
+		t.Run(fmt.Sprint(index), func(t *testing.T) {
 			actual, _ := CompareVersions(testData.oldVersion, testData.newVersion)
 			assert.Equal(t, testData.expected, actual)
 		})
 	}
 }
 
+func TestIsPrereleaseBump(t *testing.T) {
+	var testDataSlice = []struct {
+		oldVersion string
+		newVersion string
+		expected   bool
+	}{
+		{"1.2.0-rc.1", "1.2.0-rc.2", true},
+		{"1.2.0-beta", "1.2.0-rc.1", true},
+		{"1.2.0-rc.2", "1.2.0-rc.10", true},
+		{"1.2.0", "1.2.0-rc.1", false},
+		{"1.2.0-rc.2", "1.2.0-rc.1", false},
+		{"1.2.0-rc.1", "1.2.0-rc.1", false},
+		{"1.2.0-rc.1", "1.2.0", false},
+		{"1.1.0", "1.2.0-rc.1", false},
+		{"1.2.0-rc.2", "1.3.0-rc.1", false},
+	}
+
+	for _, testData := range testDataSlice {
+		t.Run(testData.oldVersion+" => "+testData.newVersion, func(t *testing.T) {
+			actual, err := IsPrereleaseBump(testData.oldVersion, testData.newVersion)
+			assert.Nil(t, err)
+			assert.Equal(t, testData.expected, actual)
+		})
+	}
+
+	_, err := IsPrereleaseBump("1.2.0", "foo")
+	assert.NotNil(t, err)
+}
+
+func TestImageRegistry(t *testing.T) {
+	var testDataSlice = []struct {
+		image    string
+		expected string
+	}{
+		{"nginx", "docker.io"},
+		{"nginx:1.19", "docker.io"},
+		{"bitnami/nginx:1.19", "docker.io"},
+		{"docker.io/bitnami/nginx", "docker.io"},
+		{"quay.io/helmpack/chart-testing:v3.0.0", "quay.io"},
+		{"registry.example.com:5000/foo/bar", "registry.example.com:5000"},
+		{"localhost/foo", "localhost"},
+		{"gcr.io/foo/bar@sha256:abc", "gcr.io"},
+	}
+
+	for index, testData := range testDataSlice {
+		t.Run(fmt.Sprint(index), func(t *testing.T) {
+			assert.Equal(t, testData.expected, ImageRegistry(testData.image))
+		})
+	}
+}
+
+func TestValidateChartVersion(t *testing.T) {
+	var testDataSlice = []struct {
+		version  string
+		expected bool
+	}{
+		{"1.2.3", true},
+		{"1.2.3-beta.1+build", true},
+		{"v1.2", true},
+		{"1.2.x", false},
+		{"one.two", false},
+		{"", false},
+	}
+
+	for index, testData := range testDataSlice {
+		t.Run(fmt.Sprint(index), func(t *testing.T) {
+			err := ValidateChartVersion(testData.version)
+			assert.Equal(t, testData.expected, err == nil)
+			if err != nil {
+				assert.Equal(t, fmt.Sprintf("Chart version '%s' is not valid SemVer", testData.version), err.Error())
+			}
+		})
+	}
+}
+
 func TestSanitizeName(t *testing.T) {
 	var testDataSlice = []struct {
 		input     string
@@ -83,7 +164,7 @@ func TestSanitizeName(t *testing.T) {
 	}
 
 	for index, testData := range testDataSlice {
-		t.Run(string(index), func(t *testing.T) {
+		t.Run(fmt.Sprint(index), func(t *testing.T) {
 			actual := SanitizeName(testData.input, testData.maxLength)
 			fmt.Printf("actual: %s,%d, input: %s,%d\n", actual, len(actual), testData.input, testData.maxLength)
 			assert.Equal(t, testData.expected, actual)
@@ -111,9 +192,97 @@ func TestBreakingChangeAllowed(t *testing.T) {
 	}
 
 	for index, testData := range testDataSlice {
-		t.Run(string(index), func(t *testing.T) {
+		t.Run(fmt.Sprint(index), func(t *testing.T) {
 			actual, _ := BreakingChangeAllowed(testData.left, testData.right)
 			assert.Equal(t, testData.breaking, actual, fmt.Sprintf("input: %s,%s\n", testData.left, testData.right))
 		})
 	}
 }
+
+func TestBreakingChangeAllowedByRule(t *testing.T) {
+	var testDataSlice = []struct {
+		left     string
+		right    string
+		rule     string
+		breaking bool
+	}{
+		{"1.2.3", "1.3.0", "major", false},
+		{"1.2.3", "2.0.0", "major", true},
+		{"1.2.3", "1.2.4", "minor", false},
+		{"1.2.3", "1.3.0", "minor", true},
+		{"1.2.3", "2.0.0", "minor", true},
+		{"0.1.0", "0.2.0", "minor", true},
+		{"1.2.3", "2.0.0", "none", false},
+		{"0.1.0", "0.2.0", "none", false},
+		{"1.2.3", "2.0.0", "patch", false}, // invalid rule
+		{"1.2.3", "1.3.0", "", false},
+		{"1.2.3", "2.0.0", "", true},
+		{"0.1.0", "0.2.0", "", true},
+	}
+
+	for index, testData := range testDataSlice {
+		t.Run(fmt.Sprint(index), func(t *testing.T) {
+			actual, _ := BreakingChangeAllowedByRule(testData.left, testData.right, testData.rule)
+			assert.Equal(t, testData.breaking, actual, fmt.Sprintf("input: %s,%s,%s\n", testData.left, testData.right, testData.rule))
+		})
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	var testDataSlice = []struct {
+		input    string
+		expected string
+	}{
+		{"charts/foo", "charts/foo"},
+		{"charts/foo/", "charts/foo"},
+		{`charts\foo`, "charts/foo"},
+		{`charts\foo\templates\deployment.yaml`, "charts/foo/templates/deployment.yaml"},
+		{`.\charts\foo\`, "charts/foo"},
+		{".", "."},
+	}
+
+	for index, testData := range testDataSlice {
+		t.Run(fmt.Sprint(index), func(t *testing.T) {
+			actual := NormalizePath(testData.input)
+			assert.Equal(t, testData.expected, actual)
+		})
+	}
+}
+
+func TestSplitRemoteChart(t *testing.T) {
+	var testDataSlice = []struct {
+		input           string
+		expectedChart   string
+		expectedVersion string
+	}{
+		{"stable/nginx", "stable/nginx", ""},
+		{"stable/nginx:1.2.3", "stable/nginx", "1.2.3"},
+		{"stable/nginx:", "stable/nginx", ""},
+		{"oci://localhost:5000/charts/nginx", "oci://localhost:5000/charts/nginx", ""},
+		{"oci://localhost:5000/charts/nginx:1.2.3", "oci://localhost:5000/charts/nginx", "1.2.3"},
+	}
+
+	for index, testData := range testDataSlice {
+		t.Run(fmt.Sprint(index), func(t *testing.T) {
+			chart, version := SplitRemoteChart(testData.input)
+			assert.Equal(t, testData.expectedChart, chart)
+			assert.Equal(t, testData.expectedVersion, version)
+		})
+	}
+}
+
+func TestLookupChartDirWithBackslashes(t *testing.T) {
+	actual, err := ChartUtils{}.LookupChartDir([]string{`..\chart\test_charts\`}, `..\chart\test_charts\bar\bar_sub\templates`)
+	assert.Nil(t, err)
+	assert.Equal(t, "../chart/test_charts/bar", actual)
+}
+
+func TestGetDelimiterWidth(t *testing.T) {
+	defer SetDelimiterWidth(0)
+
+	SetDelimiterWidth(42)
+	assert.Equal(t, 42, getDelimiterWidth())
+
+	SetDelimiterWidth(0)
+	assert.Contains(t, []int{80, 120}, getDelimiterWidth())
+}